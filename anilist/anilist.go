@@ -1,58 +1,199 @@
 package anilist
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"strings"
-
-	"github.com/aqatl/mal/oauth2"
-	"github.com/pkg/errors"
 )
 
-const ApiEndpoint = "https://graphql.anilist.co"
 const ALDomain = "https://anilist.co"
 
-var InvalidToken = errors.New("Invalid token")
-
 // TODO downloading only given list like watching/completed
-func QueryUserLists(userId int, scoreFormat ScoreFormat, token oauth2.OAuthToken) ([]MediaListGroup, error) {
+func (c *Client) QueryUserLists(userId int, mediaType MediaType, scoreFormat ScoreFormat) (
+	[]MediaListGroup, error,
+) {
 	vars := make(map[string]interface{})
 	vars["userID"] = userId
+	vars["type"] = mediaType
 	vars["scoreFormat"] = scoreFormat
 
 	resp := struct {
 		MediaListCollection `json:"MediaListCollection"`
 	}{MediaListCollection{}}
-	if err := gqlErrorsHandler(graphQLRequestParsed(queryUserAnimeList, vars, token, &resp)); err != nil {
+	if err := gqlErrorsHandler(c.graphQLRequestParsed(queryUserMediaList, vars, &resp)); err != nil {
 		return nil, err
 	}
 	return resp.Lists, nil
 }
 
-func QueryAuthenticatedUser(user *User, token oauth2.OAuthToken) error {
+// Queries the public list of the user with given name. Scores are converted to scoreFormat
+func (c *Client) QueryUserListsByName(userName string, mediaType MediaType, scoreFormat ScoreFormat) (
+	[]MediaListGroup, error,
+) {
+	vars := make(map[string]interface{})
+	vars["userName"] = userName
+	vars["type"] = mediaType
+	vars["scoreFormat"] = scoreFormat
+
+	resp := struct {
+		MediaListCollection `json:"MediaListCollection"`
+	}{MediaListCollection{}}
+	if err := gqlErrorsHandler(c.graphQLRequestParsed(queryUserMediaListByName, vars, &resp)); err != nil {
+		return nil, err
+	}
+	return resp.Lists, nil
+}
+
+// Queries entries updated after given unix time, most recently updated first
+func (c *Client) QueryUserListChanges(userId int, mediaType MediaType, scoreFormat ScoreFormat, since int) (
+	[]MediaListEntry, error,
+) {
+	vars := make(map[string]interface{})
+	vars["userID"] = userId
+	vars["type"] = mediaType
+	vars["scoreFormat"] = scoreFormat
+	vars["perPage"] = 50
+
+	changes := make([]MediaListEntry, 0)
+	for page := 1; ; page++ {
+		vars["page"] = page
+		data := &struct {
+			Page struct {
+				PageInfo struct {
+					HasNextPage bool `json:"hasNextPage"`
+				} `json:"pageInfo"`
+				MediaList []MediaListEntry `json:"mediaList"`
+			} `json:"Page"`
+		}{}
+		err := gqlErrorsHandler(c.graphQLRequestParsed(queryUserMediaListChanges, vars, data))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range data.Page.MediaList {
+			if entry.UpdatedAt <= since {
+				return changes, nil
+			}
+			changes = append(changes, entry)
+		}
+		if !data.Page.PageInfo.HasNextPage {
+			return changes, nil
+		}
+	}
+}
+
+// Queries list ids (MediaListEntry.ListId) of all entries on the list
+func (c *Client) QueryUserListIds(userId int, mediaType MediaType) ([]int, error) {
+	vars := make(map[string]interface{})
+	vars["userID"] = userId
+	vars["type"] = mediaType
+
+	data := &struct {
+		MediaListCollection struct {
+			Lists []struct {
+				Entries []struct {
+					Id int `json:"id"`
+				} `json:"entries"`
+			} `json:"lists"`
+		} `json:"MediaListCollection"`
+	}{}
+	err := gqlErrorsHandler(c.graphQLRequestParsed(queryUserMediaListIds, vars, data))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0)
+	for _, list := range data.MediaListCollection.Lists {
+		for _, entry := range list.Entries {
+			ids = append(ids, entry.Id)
+		}
+	}
+	return ids, nil
+}
+
+// Looks up media by their MyAnimeList ids. Ids unknown to AniList are left out of the result
+func (c *Client) QueryMediaByMalIds(malIds []int, mediaType MediaType) ([]MediaDeficient, error) {
+	vars := make(map[string]interface{})
+	vars["idMal"] = malIds
+	vars["type"] = mediaType
+	vars["perPage"] = 50
+
+	media := make([]MediaDeficient, 0, len(malIds))
+	for page := 1; ; page++ {
+		vars["page"] = page
+		data := &struct {
+			Page struct {
+				PageInfo struct {
+					HasNextPage bool `json:"hasNextPage"`
+				} `json:"pageInfo"`
+				Media []MediaDeficient `json:"media"`
+			} `json:"Page"`
+		}{}
+		err := gqlErrorsHandler(c.graphQLRequestParsed(queryMediaByMalIds, vars, data))
+		if err != nil {
+			return nil, err
+		}
+		media = append(media, data.Page.Media...)
+		if !data.Page.PageInfo.HasNextPage {
+			return media, nil
+		}
+	}
+}
+
+func (c *Client) QueryAuthenticatedUser(user *User) error {
 	viewer := &struct {
 		*User `json:"Viewer"`
 	}{user}
-	return gqlErrorsHandler(graphQLRequestParsed(queryAuthenticatedUser, nil, token, viewer))
+	return gqlErrorsHandler(c.graphQLRequestParsed(queryAuthenticatedUser, nil, viewer))
 }
 
-func SaveMediaListEntry(entry *MediaListEntry, token oauth2.OAuthToken) error {
+func (c *Client) SaveMediaListEntry(entry *MediaListEntry) error {
 	vars := make(map[string]interface{})
-	vars["listId"] = entry.ListId
+	if entry.ListId != 0 {
+		vars["listId"] = entry.ListId
+	}
 	vars["mediaId"] = entry.Id
 	vars["status"] = string(entry.Status)
 	vars["progress"] = entry.Progress
+	if MediaType(entry.Type) == Manga {
+		vars["progressVolumes"] = entry.ProgressVolumes
+	}
 	vars["score"] = entry.Score
+	// Entries cached before custom lists or the fields below were fetched don't know
+	// their values, sending empty ones would clear them on AniList
+	if entry.CustomLists != nil {
+		vars["customLists"] = entry.CustomListNames()
+	}
+	if len(entry.AdvancedScores) > 0 {
+		advancedScores := make([]float32, len(entry.AdvancedScores))
+		for i, score := range entry.AdvancedScores {
+			advancedScores[i] = score.Score
+		}
+		vars["advancedScores"] = advancedScores
+	}
+	vars["repeat"] = entry.Repeat
+	if entry.StartedAt != nil && entry.CompletedAt != nil {
+		vars["startedAt"] = entry.StartedAt.input()
+		vars["completedAt"] = entry.CompletedAt.input()
+		vars["notes"] = entry.Notes
+		vars["private"] = entry.Private
+		vars["hiddenFromStatusLists"] = entry.HiddenFromStatusLists
+	}
 	entryData := &struct {
 		*MediaListEntry `json:"SaveMediaListEntry"`
 	}{entry}
-	return gqlErrorsHandler(graphQLRequestParsed(saveMediaListEntry, vars, token, entryData))
+	return gqlErrorsHandler(c.graphQLRequestParsed(saveMediaListEntry, vars, entryData))
+}
+
+// Queries the current state of a list entry. Only list fields are fetched, media stays empty.
+func (c *Client) QueryMediaListEntry(listId int) (MediaListEntry, error) {
+	vars := make(map[string]interface{})
+	vars["id"] = listId
+	data := &struct {
+		MediaListEntry `json:"MediaList"`
+	}{}
+	err := gqlErrorsHandler(c.graphQLRequestParsed(queryMediaListEntry, vars, data))
+	return data.MediaListEntry, err
 }
 
-func AddMediaListEntry(id int, status MediaListStatus, token oauth2.OAuthToken) (
+func (c *Client) AddMediaListEntry(id int, status MediaListStatus) (
 	MediaListEntry, error,
 ) {
 	vars := make(map[string]interface{})
@@ -61,32 +202,95 @@ func AddMediaListEntry(id int, status MediaListStatus, token oauth2.OAuthToken)
 	entryData := &struct {
 		MediaListEntry `json:"SaveMediaListEntry"`
 	}{}
-	err := gqlErrorsHandler(graphQLRequestParsed(addMediaListEntry, vars, token, entryData))
+	err := gqlErrorsHandler(c.graphQLRequestParsed(addMediaListEntry, vars, entryData))
 	return entryData.MediaListEntry, err
 }
 
-func QueryAiringSchedule(mediaId, episode int, token oauth2.OAuthToken) (AiringSchedule, error) {
+func (c *Client) QueryAiringSchedule(mediaId, episode int) (AiringSchedule, error) {
 	vars := make(map[string]interface{})
 	vars["mediaId"] = mediaId
 	vars["episode"] = episode
 	data := &struct {
 		AiringSchedule `json:"AiringSchedule"`
 	}{AiringSchedule{}}
-	err := gqlErrorsHandler(graphQLRequestParsed(queryAiringSchedule, vars, token, data))
+	err := gqlErrorsHandler(c.graphQLRequestParsed(queryAiringSchedule, vars, data))
 	return data.AiringSchedule, err
 }
 
-func QueryAiringNotification(markRead bool, token oauth2.OAuthToken) (AiringNotification, error) {
+// Queries episodes of all given media airing between from and to (unix times), soonest first
+func (c *Client) QueryAiringSchedules(mediaIds []int, from, to int) ([]AiringSchedule, error) {
+	schedules := make([]AiringSchedule, 0)
+	if len(mediaIds) == 0 {
+		return schedules, nil
+	}
+
+	vars := make(map[string]interface{})
+	vars["mediaIds"] = mediaIds
+	vars["from"] = from
+	vars["to"] = to
+	vars["perPage"] = 50
+	for page := 1; ; page++ {
+		vars["page"] = page
+		data := &struct {
+			Page struct {
+				PageInfo struct {
+					HasNextPage bool `json:"hasNextPage"`
+				} `json:"pageInfo"`
+				AiringSchedules []AiringSchedule `json:"airingSchedules"`
+			} `json:"Page"`
+		}{}
+		if err := gqlErrorsHandler(c.graphQLRequestParsed(queryAiringSchedules, vars, data)); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, data.Page.AiringSchedules...)
+		if !data.Page.PageInfo.HasNextPage || len(data.Page.AiringSchedules) == 0 {
+			return schedules, nil
+		}
+	}
+}
+
+// Queries airing state of given media. Only id, status, episodes, duration
+// and nextAiringEpisode of the returned media are filled
+func (c *Client) QueryNextAiringEpisodes(mediaIds []int) ([]MediaFull, error) {
+	media := make([]MediaFull, 0, len(mediaIds))
+	if len(mediaIds) == 0 {
+		return media, nil
+	}
+	vars := make(map[string]interface{})
+	vars["ids"] = mediaIds
+	vars["perPage"] = 50
+
+	for page := 1; ; page++ {
+		vars["page"] = page
+		data := &struct {
+			Page struct {
+				PageInfo struct {
+					HasNextPage bool `json:"hasNextPage"`
+				} `json:"pageInfo"`
+				Media []MediaFull `json:"media"`
+			} `json:"Page"`
+		}{}
+		if err := gqlErrorsHandler(c.graphQLRequestParsed(queryNextAiringEpisodes, vars, data)); err != nil {
+			return nil, err
+		}
+		media = append(media, data.Page.Media...)
+		if !data.Page.PageInfo.HasNextPage || len(data.Page.Media) == 0 {
+			return media, nil
+		}
+	}
+}
+
+func (c *Client) QueryAiringNotification(markRead bool) (AiringNotification, error) {
 	vars := make(map[string]interface{})
 	vars["resetNotificationCount"] = markRead
 	data := new(struct {
 		AiringNotification `json:"Notification"`
 	})
-	err := gqlErrorsHandler(graphQLRequestParsed(queryAiringNotification, vars, token, data))
+	err := gqlErrorsHandler(c.graphQLRequestParsed(queryAiringNotification, vars, data))
 	return data.AiringNotification, err
 }
 
-func QueryAiringNotifications(page, perPage int, markRead bool, token oauth2.OAuthToken) (
+func (c *Client) QueryAiringNotifications(page, perPage int, markRead bool) (
 	[]AiringNotification, error,
 ) {
 	vars := make(map[string]interface{})
@@ -98,11 +302,45 @@ func QueryAiringNotifications(page, perPage int, markRead bool, token oauth2.OAu
 			Notifications []AiringNotification `json:"notifications"`
 		} `json:"Page"`
 	})
-	err := gqlErrorsHandler(graphQLRequestParsed(queryAiringNotifications, vars, token, data))
+	err := gqlErrorsHandler(c.graphQLRequestParsed(queryAiringNotifications, vars, data))
 	return data.Page.Notifications, err
 }
 
-func DeleteMediaListEntry(entry *MediaListEntry, token oauth2.OAuthToken) error {
+// Queries a page of notifications of given types, all of them if types are empty.
+// Returns also whether there are more pages.
+func (c *Client) QueryNotifications(page, perPage int, types []NotificationType, markRead bool) (
+	[]Notification, bool, error,
+) {
+	vars := make(map[string]interface{})
+	vars["page"] = page
+	vars["perPage"] = perPage
+	if len(types) > 0 {
+		vars["types"] = types
+	}
+	vars["resetNotificationCount"] = markRead
+	data := new(struct {
+		Page struct {
+			PageInfo struct {
+				HasNextPage bool `json:"hasNextPage"`
+			} `json:"pageInfo"`
+			Notifications []Notification `json:"notifications"`
+		} `json:"Page"`
+	})
+	err := gqlErrorsHandler(c.graphQLRequestParsed(queryNotifications, vars, data))
+	return data.Page.Notifications, data.Page.PageInfo.HasNextPage, err
+}
+
+// Marks all notifications as read
+func (c *Client) ResetNotificationCount() error {
+	data := new(struct {
+		Page struct {
+			Notifications []Notification `json:"notifications"`
+		} `json:"Page"`
+	})
+	return gqlErrorsHandler(c.graphQLRequestParsed(resetNotificationCount, nil, data))
+}
+
+func (c *Client) DeleteMediaListEntry(entry *MediaListEntry) error {
 	vars := make(map[string]interface{})
 	vars["id"] = entry.ListId
 	data := new(struct {
@@ -110,7 +348,7 @@ func DeleteMediaListEntry(entry *MediaListEntry, token oauth2.OAuthToken) error
 			Deleted bool `json:"deleted"`
 		} `json:"DeleteMediaListEntry"`
 	})
-	err := gqlErrorsHandler(graphQLRequestParsed(deleteMediaListEntry, vars, token, data))
+	err := gqlErrorsHandler(c.graphQLRequestParsed(deleteMediaListEntry, vars, data))
 	if err != nil {
 		return err
 	}
@@ -120,119 +358,167 @@ func DeleteMediaListEntry(entry *MediaListEntry, token oauth2.OAuthToken) error
 	return nil
 }
 
-func Search(query string, page, perPage int, mtype MediaType, token oauth2.OAuthToken) ([]MediaFull, error) {
-	vars := make(map[string]interface{})
-	vars["page"] = page
-	vars["perPage"] = perPage
-	vars["search"] = query
-	vars["type"] = mtype
+func (c *Client) Search(query string, page, perPage int, mtype MediaType) ([]MediaFull, error) {
+	media, _, err := c.SearchFiltered(query, SearchFilters{}, page, perPage, mtype)
+	return media, err
+}
 
-	data := new(struct {
-		Page struct {
-			Media []MediaFull `json:"media"`
-		} `json:"Page"`
-	})
-	err := gqlErrorsHandler(graphQLRequestParsed(queryMedia, vars, token, data))
-	return data.Page.Media, err
+// Optional arguments of media search; empty fields don't limit the results
+type SearchFilters struct {
+	Genres []string
+	Tags   []string
+	// WINTER, SPRING, SUMMER or FALL
+	Season string
+	Year   int
+	// e.g. TV, MOVIE, MANGA
+	Format string
+	// e.g. RELEASING, FINISHED
+	Status string
+	// Inclusive bounds of average score (0-100), 0 for open ends
+	MinScore int
+	MaxScore int
+	// Nil for both adult and non-adult media
+	IsAdult *bool
+	// e.g. POPULARITY_DESC
+	Sort string
 }
 
-func gqlErrorsHandler(gqlErrs []GqlError, err error) error {
-	if err != nil {
-		return err
+func (f SearchFilters) variables(vars map[string]interface{}) {
+	if len(f.Genres) > 0 {
+		vars["genres"] = f.Genres
 	}
-	for _, gqlErr := range gqlErrs {
-		if gqlErr.Message == "Invalid token" {
-			return InvalidToken
-		}
+	if len(f.Tags) > 0 {
+		vars["tags"] = f.Tags
 	}
-	if len(gqlErrs) > 0 {
-		locations := strings.Builder{}
-		for _, loc := range gqlErrs[0].Locations {
-			locations.WriteString(fmt.Sprintf("Line %d column %d\n", loc.Line, loc.Column))
-		}
-		return fmt.Errorf("GraphQl Error (%d): %s\n%s",
-			gqlErrs[0].Status, gqlErrs[0].Message, locations.String())
+	if f.Season != "" {
+		vars["season"] = f.Season
 	}
-	return nil
-}
-
-func printGqlErrs(gqlErrs []GqlError) {
-	for _, gqlErr := range gqlErrs {
-		fmt.Printf("GraphQl Error (%d): %s\n", gqlErr.Status, gqlErr.Message)
-		for _, loc := range gqlErr.Locations {
-			fmt.Printf("Line %d column %d\n", loc.Line, loc.Column)
-		}
+	if f.Season != "" && f.Year > 0 {
+		// Media of a season may start in the year before it, e.g. in December before winter
+		vars["seasonYear"] = f.Year
+	} else if f.Year > 0 {
+		// Fuzzy dates as integers, e.g. 20190000 for 2019
+		vars["startDateGreater"] = f.Year * 10000
+		vars["startDateLesser"] = (f.Year + 1) * 10000
 	}
-}
-
-func graphQLRequestParsed(query string, vars map[string]interface{}, t oauth2.OAuthToken,
-	x interface{}) ([]GqlError, error) {
-	resp, err := graphQLRequest(query, vars, t)
-	if resp != nil {
-		defer resp.Body.Close()
+	if f.Format != "" {
+		vars["format"] = f.Format
 	}
-	if err != nil {
-		return nil, err
+	if f.Status != "" {
+		vars["status"] = f.Status
 	}
-	type responseData struct {
-		Data   interface{}
-		Errors []GqlError
+	// AniList compares scores exclusively
+	if f.MinScore > 0 {
+		vars["scoreGreater"] = f.MinScore - 1
 	}
-	respData := &responseData{Data: x}
-
-	if err := json.NewDecoder(resp.Body).Decode(respData); err != nil {
-		return nil, err
+	if f.MaxScore > 0 {
+		vars["scoreLesser"] = f.MaxScore + 1
 	}
-	if len(respData.Errors) > 0 {
-		// TODO include all error fields
-		// TODO better error handling -> maybe typedef error array as QueryErrors?
-		return respData.Errors, nil
+	if f.IsAdult != nil {
+		vars["isAdult"] = *f.IsAdult
+	}
+	if f.Sort != "" {
+		vars["sort"] = []string{f.Sort}
 	}
-	return nil, nil
 }
 
-func graphQLRequestString(query string, vars map[string]interface{}, t oauth2.OAuthToken) (
-	string, error,
+// Searches media matching the query and filters. Returns also whether there are more pages.
+func (c *Client) SearchFiltered(query string, filters SearchFilters, page, perPage int, mtype MediaType) (
+	[]MediaFull, bool, error,
 ) {
-	resp, err := graphQLRequest(query, vars, t)
-	if resp != nil {
-		defer resp.Body.Close()
-	}
-	if err != nil {
-		return "", err
+	vars := make(map[string]interface{})
+	vars["page"] = page
+	vars["perPage"] = perPage
+	if query != "" {
+		vars["search"] = query
 	}
-	data, err := ioutil.ReadAll(resp.Body)
-	return string(data), err
+	vars["type"] = mtype
+	filters.variables(vars)
+
+	data := new(struct {
+		Page struct {
+			PageInfo struct {
+				HasNextPage bool `json:"hasNextPage"`
+			} `json:"pageInfo"`
+			Media []MediaFull `json:"media"`
+		} `json:"Page"`
+	})
+	err := gqlErrorsHandler(c.graphQLRequestParsed(queryMedia, vars, data))
+	return data.Page.Media, data.Page.PageInfo.HasNextPage, err
 }
 
-func graphQLRequest(query string, vars map[string]interface{}, t oauth2.OAuthToken) (
-	*http.Response, error,
-) {
-	reqBody := bytes.Buffer{}
-	err := json.NewEncoder(&reqBody).Encode(struct {
-		Query     string                 `json:"query"`
-		Variables map[string]interface{} `json:"variables"`
-	}{query, vars})
-	if err != nil {
-		return nil, err
+// Genres that can be used in search filters
+func (c *Client) QueryGenres() ([]string, error) {
+	data := new(struct {
+		Genres []string `json:"GenreCollection"`
+	})
+	err := gqlErrorsHandler(c.graphQLRequestParsed(queryGenres, nil, data))
+	return data.Genres, err
+}
+
+// Queries all anime of given season (WINTER, SPRING, SUMMER or FALL), most popular first
+func (c *Client) QuerySeason(season string, year int) ([]MediaFull, error) {
+	vars := make(map[string]interface{})
+	vars["season"] = season
+	vars["seasonYear"] = year
+	vars["perPage"] = 50
+
+	media := make([]MediaFull, 0)
+	for page := 1; ; page++ {
+		vars["page"] = page
+		data := &struct {
+			Page struct {
+				PageInfo struct {
+					HasNextPage bool `json:"hasNextPage"`
+				} `json:"pageInfo"`
+				Media []MediaFull `json:"media"`
+			} `json:"Page"`
+		}{}
+		if err := gqlErrorsHandler(c.graphQLRequestParsed(querySeason, vars, data)); err != nil {
+			return nil, err
+		}
+		media = append(media, data.Page.Media...)
+		if !data.Page.PageInfo.HasNextPage || len(data.Page.Media) == 0 {
+			return media, nil
+		}
 	}
+}
 
-	req, err := http.NewRequest(http.MethodPost, ApiEndpoint, &reqBody)
-	if err != nil {
-		return nil, err
+// Queries full details of given media
+func (c *Client) QueryMediaByIds(mediaIds []int) ([]MediaFull, error) {
+	media := make([]MediaFull, 0, len(mediaIds))
+	if len(mediaIds) == 0 {
+		return media, nil
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "Bearer "+t.Token)
+	vars := make(map[string]interface{})
+	vars["ids"] = mediaIds
+	vars["perPage"] = 50
 
-	return http.DefaultClient.Do(req)
+	for page := 1; ; page++ {
+		vars["page"] = page
+		data := &struct {
+			Page struct {
+				PageInfo struct {
+					HasNextPage bool `json:"hasNextPage"`
+				} `json:"pageInfo"`
+				Media []MediaFull `json:"media"`
+			} `json:"Page"`
+		}{}
+		if err := gqlErrorsHandler(c.graphQLRequestParsed(queryMediaByIds, vars, data)); err != nil {
+			return nil, err
+		}
+		media = append(media, data.Page.Media...)
+		if !data.Page.PageInfo.HasNextPage || len(data.Page.Media) == 0 {
+			return media, nil
+		}
+	}
 }
 
 func ParseStatus(status string) MediaListStatus {
 	switch strings.ToLower(status) {
-	case "watching", "current":
+	case "watching", "reading", "current":
 		return Current
-	case "planning", "plantowatch":
+	case "planning", "plantowatch", "plantoread":
 		return Planning
 	case "completed":
 		return Completed
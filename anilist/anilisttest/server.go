@@ -0,0 +1,237 @@
+// Package anilisttest provides a stand-in AniList GraphQL server replaying recorded responses.
+package anilisttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Fixture is a recorded response for requests querying the Field root field
+// (e.g. "MediaListCollection" or "SaveMediaListEntry").
+// If Variables are set, only requests with the same values of those variables match.
+type Fixture struct {
+	Field     string                 `json:"field"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+	Status    int                    `json:"status,omitempty"`
+	Response  json.RawMessage        `json:"response"`
+}
+
+type Request struct {
+	Field     string
+	Query     string
+	Variables map[string]interface{}
+	Header    http.Header
+}
+
+// Server matches incoming requests against fixtures in order. Requests with no matching
+// fixture are forwarded to Upstream (and recorded) if it's set, or answered with a GraphQL error.
+type Server struct {
+	*httptest.Server
+
+	Upstream string
+
+	mu       sync.Mutex
+	fixtures []Fixture
+	requests []Request
+}
+
+func NewServer(fixtures ...Fixture) *Server {
+	s := &Server{fixtures: normalize(fixtures)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Starts a server with fixtures loaded from a json file
+func NewServerFromFile(path string) (*Server, error) {
+	fixtures, err := LoadFixtures(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewServer(fixtures...), nil
+}
+
+func LoadFixtures(path string) ([]Fixture, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fixtures := make([]Fixture, 0)
+	err = json.NewDecoder(f).Decode(&fixtures)
+	return fixtures, err
+}
+
+// Saves all fixtures, including the recorded ones
+func (s *Server) SaveFixtures(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.MarshalIndent(s.fixtures, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Adds fixtures taking precedence over the existing ones
+func (s *Server) Prepend(fixtures ...Fixture) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fixtures = append(normalize(fixtures), s.fixtures...)
+}
+
+// Round-trips fixture variables through json, so they compare equal to decoded request variables
+func normalize(fixtures []Fixture) []Fixture {
+	for i := range fixtures {
+		if fixtures[i].Variables == nil {
+			continue
+		}
+		data, err := json.Marshal(fixtures[i].Variables)
+		if err != nil {
+			continue
+		}
+		vars := make(map[string]interface{})
+		if json.Unmarshal(data, &vars) == nil {
+			fixtures[i].Variables = vars
+		}
+	}
+	return fixtures
+}
+
+// Returns all requests received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Returns requests received so far querying given root field
+func (s *Server) RequestsFor(field string) []Request {
+	requests := make([]Request, 0)
+	for _, r := range s.Requests() {
+		if r.Field == field {
+			requests = append(requests, r)
+		}
+	}
+	return requests
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	gqlReq := struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}{}
+	if err := json.Unmarshal(body, &gqlReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := Request{
+		Field:     RootField(gqlReq.Query),
+		Query:     gqlReq.Query,
+		Variables: gqlReq.Variables,
+		Header:    r.Header.Clone(),
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	fixture, ok := s.match(&req)
+	upstream := s.Upstream
+	s.mu.Unlock()
+
+	if !ok && upstream != "" {
+		fixture, err = s.record(&req, body, r.Header)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		ok = true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"data":null,"errors":[{"message":"no fixture for %s","status":404}]}`,
+			req.Field)
+		return
+	}
+	if fixture.Status != 0 {
+		w.WriteHeader(fixture.Status)
+	}
+	w.Write(fixture.Response)
+}
+
+func (s *Server) match(req *Request) (Fixture, bool) {
+	for _, f := range s.fixtures {
+		if f.Field != req.Field {
+			continue
+		}
+		matches := true
+		for k, v := range f.Variables {
+			if !reflect.DeepEqual(req.Variables[k], v) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return f, true
+		}
+	}
+	return Fixture{}, false
+}
+
+func (s *Server) record(req *Request, body []byte, header http.Header) (Fixture, error) {
+	upReq, err := http.NewRequest(http.MethodPost, s.Upstream, bytes.NewReader(body))
+	if err != nil {
+		return Fixture{}, err
+	}
+	upReq.Header = header.Clone()
+	resp, err := http.DefaultClient.Do(upReq)
+	if err != nil {
+		return Fixture{}, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Fixture{}, err
+	}
+
+	fixture := Fixture{
+		Field:     req.Field,
+		Variables: req.Variables,
+		Response:  respBody,
+	}
+	if resp.StatusCode != http.StatusOK {
+		fixture.Status = resp.StatusCode
+	}
+	s.mu.Lock()
+	s.fixtures = append(s.fixtures, fixture)
+	s.mu.Unlock()
+	return fixture, nil
+}
+
+// Returns name of the first root field selected by the query
+func RootField(query string) string {
+	start := strings.Index(query, "{")
+	if start == -1 {
+		return ""
+	}
+	rest := strings.TrimSpace(query[start+1:])
+	end := strings.IndexAny(rest, " \t\n\r({")
+	if end == -1 {
+		return rest
+	}
+	return rest[:end]
+}
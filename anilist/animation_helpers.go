@@ -1,53 +1,127 @@
 package anilist
 
 import (
-	"github.com/aqatl/mal/oauth2"
 	"github.com/aqatl/cliwait"
 )
 
-func QueryUserListsWaitAnimation(userId int, scoreFormat ScoreFormat, token oauth2.OAuthToken) ([]MediaListGroup, error) {
+func (c *Client) QueryUserListsWaitAnimation(userId int, mediaType MediaType, scoreFormat ScoreFormat) (
+	[]MediaListGroup, error,
+) {
 	var mlg []MediaListGroup
 	var err error
 	cliwait.DoFuncWithWaitAnimation("Queyring user list", func() {
-		mlg, err = QueryUserLists(userId, scoreFormat, token)
+		mlg, err = c.QueryUserLists(userId, mediaType, scoreFormat)
 	})
 	return mlg, err
 }
 
-func QueryAuthenticatedUserWaitAnimation(user *User, token oauth2.OAuthToken) error {
+func (c *Client) QueryUserListsByNameWaitAnimation(userName string, mediaType MediaType, scoreFormat ScoreFormat) (
+	[]MediaListGroup, error,
+) {
+	var mlg []MediaListGroup
+	var err error
+	cliwait.DoFuncWithWaitAnimation("Querying list of "+userName, func() {
+		mlg, err = c.QueryUserListsByName(userName, mediaType, scoreFormat)
+	})
+	return mlg, err
+}
+
+func (c *Client) QueryUserListChangesWaitAnimation(
+	userId int, mediaType MediaType, scoreFormat ScoreFormat, since int,
+) (
+	changes []MediaListEntry, ids []int, err error,
+) {
+	cliwait.DoFuncWithWaitAnimation("Querying list changes", func() {
+		changes, err = c.QueryUserListChanges(userId, mediaType, scoreFormat, since)
+		if err == nil {
+			ids, err = c.QueryUserListIds(userId, mediaType)
+		}
+	})
+	return
+}
+
+func (c *Client) QueryAuthenticatedUserWaitAnimation(user *User) error {
 	var err error
 	cliwait.DoFuncWithWaitAnimation("Saving entry", func() {
-		err = QueryAuthenticatedUser(user, token)
+		err = c.QueryAuthenticatedUser(user)
 	})
 	return err
 }
 
-func SaveMediaListEntryWaitAnimation(entry *MediaListEntry, token oauth2.OAuthToken) error {
+func (c *Client) SaveMediaListEntryWaitAnimation(entry *MediaListEntry) error {
 	var err error
 	cliwait.DoFuncWithWaitAnimation("Saving entry", func() {
-		err = SaveMediaListEntry(entry, token)
+		err = c.SaveMediaListEntry(entry)
 	})
 	return err
 }
 
-func QueryAiringScheduleWaitAnimation(mediaId, episode int, token oauth2.OAuthToken) (
+func (c *Client) QueryAiringScheduleWaitAnimation(mediaId, episode int) (
 	AiringSchedule, error,
 ) {
 	var as AiringSchedule
 	var err error
 	cliwait.DoFuncWithWaitAnimation("Querying airing schedule", func() {
-		as, err = QueryAiringSchedule(mediaId, episode, token)
+		as, err = c.QueryAiringSchedule(mediaId, episode)
 	})
 	return as, err
 }
 
-func QueryAiringNotificationsWaitAnimation(page, perPage int, markRead bool, token oauth2.OAuthToken) (
+func (c *Client) QueryAiringNotificationsWaitAnimation(page, perPage int, markRead bool) (
 	[]AiringNotification, error,
 ) {
 	var n []AiringNotification
 	var err error
 	cliwait.DoFuncWithWaitAnimation("Querying notification", func() {
-		n, err = QueryAiringNotifications(page, perPage, markRead, token)
+		n, err = c.QueryAiringNotifications(page, perPage, markRead)
 	})
 	return n, err
 }
+
+func (c *Client) QueryNotificationsWaitAnimation(page, perPage int, types []NotificationType, markRead bool) (
+	[]Notification, bool, error,
+) {
+	var n []Notification
+	var hasNextPage bool
+	var err error
+	cliwait.DoFuncWithWaitAnimation("Querying notifications", func() {
+		n, hasNextPage, err = c.QueryNotifications(page, perPage, types, markRead)
+	})
+	return n, hasNextPage, err
+}
+
+func (c *Client) QuerySeasonWaitAnimation(season string, year int) ([]MediaFull, error) {
+	var media []MediaFull
+	var err error
+	cliwait.DoFuncWithWaitAnimation("Querying season", func() {
+		media, err = c.QuerySeason(season, year)
+	})
+	return media, err
+}
+
+func (c *Client) QueryAiringSchedulesWaitAnimation(mediaIds []int, from, to int) ([]AiringSchedule, error) {
+	var schedules []AiringSchedule
+	var err error
+	cliwait.DoFuncWithWaitAnimation("Querying airing schedules", func() {
+		schedules, err = c.QueryAiringSchedules(mediaIds, from, to)
+	})
+	return schedules, err
+}
+
+func (c *Client) QueryNextAiringEpisodesWaitAnimation(mediaIds []int) ([]MediaFull, error) {
+	var media []MediaFull
+	var err error
+	cliwait.DoFuncWithWaitAnimation("Querying airing episodes", func() {
+		media, err = c.QueryNextAiringEpisodes(mediaIds)
+	})
+	return media, err
+}
+
+func (c *Client) QueryMediaByIdsWaitAnimation(mediaIds []int) ([]MediaFull, error) {
+	var media []MediaFull
+	var err error
+	cliwait.DoFuncWithWaitAnimation("Querying media", func() {
+		media, err = c.QueryMediaByIds(mediaIds)
+	})
+	return media, err
+}
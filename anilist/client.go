@@ -0,0 +1,231 @@
+package anilist
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aqatl/mal/oauth2"
+	"github.com/pkg/errors"
+)
+
+const ApiEndpoint = "https://graphql.anilist.co"
+const DefaultUserAgent = "mal (github.com/aqatl/mal)"
+
+// Client sends GraphQL requests to AniList (or any compatible endpoint) on behalf of the token owner
+type Client struct {
+	Endpoint   string
+	HttpClient *http.Client
+	Token      oauth2.OAuthToken
+	UserAgent  string
+	// Timeout of a single request, including reading the response body. 0 means no timeout
+	Timeout time.Duration
+
+	// How many times a rate limited (HTTP 429) or temporarily failed request is retried
+	MaxRetries int
+	// Longest wait before a retry the client accepts. Defaults to 2 minutes
+	MaxRetryWait time.Duration
+
+	mu                 sync.Mutex
+	rateLimitRemaining int
+	rateLimitReset     time.Time
+	sleep              func(time.Duration)
+}
+
+func NewClient(token oauth2.OAuthToken) *Client {
+	return &Client{
+		Endpoint:   ApiEndpoint,
+		Token:      token,
+		UserAgent:  DefaultUserAgent,
+		Timeout:    30 * time.Second,
+		MaxRetries: 3,
+
+		rateLimitRemaining: -1,
+	}
+}
+
+// Value of the X-RateLimit-Remaining header of the last response, -1 if unknown
+func (c *Client) RateLimitRemaining() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rateLimitRemaining
+}
+
+func (c *Client) httpClient() *http.Client {
+	client := http.DefaultClient
+	if c.HttpClient != nil {
+		client = c.HttpClient
+	}
+	if c.Timeout == 0 {
+		return client
+	}
+	withTimeout := *client
+	withTimeout.Timeout = c.Timeout
+	return &withTimeout
+}
+
+func (c *Client) graphQLRequestParsed(query string, vars map[string]interface{}, x interface{}) (
+	*QueryErrors, error,
+) {
+	resp, err := c.graphQLRequest(query, vars)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	type responseData struct {
+		Data   interface{}
+		Errors []GqlError
+	}
+	respData := &responseData{Data: x}
+
+	if err := json.NewDecoder(resp.Body).Decode(respData); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.Errorf("AniList responded with %s", resp.Status)
+		}
+		return nil, err
+	}
+	if len(respData.Errors) > 0 || resp.StatusCode == http.StatusTooManyRequests {
+		return &QueryErrors{StatusCode: resp.StatusCode, Errors: respData.Errors}, nil
+	}
+	return nil, nil
+}
+
+func (c *Client) graphQLRequestString(query string, vars map[string]interface{}) (string, error) {
+	resp, err := c.graphQLRequest(query, vars)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	return string(data), err
+}
+
+// Sends the request, waiting and retrying when AniList is rate limiting us or is temporarily unavailable
+func (c *Client) graphQLRequest(query string, vars map[string]interface{}) (*http.Response, error) {
+	reqBody, err := json.Marshal(struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}{query, vars})
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		c.waitForRateLimitReset()
+
+		resp, err := c.send(reqBody)
+		if err != nil {
+			return nil, err
+		}
+		c.updateRateLimit(resp.Header)
+
+		if attempt >= c.MaxRetries || !retryable(resp.StatusCode) {
+			return resp, nil
+		}
+
+		wait := retryAfter(resp.Header, attempt)
+		if wait > c.maxRetryWait() {
+			return resp, nil
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		c.doSleep(wait)
+	}
+}
+
+func (c *Client) send(reqBody []byte) (*http.Response, error) {
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = ApiEndpoint
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if c.Token.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token.Token)
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	return c.httpClient().Do(req)
+}
+
+func retryable(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Time to wait before the next attempt: Retry-After header if present, exponential backoff otherwise
+func retryAfter(header http.Header, attempt int) time.Duration {
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header.Get("Retry-After")); err == nil {
+		return time.Until(date)
+	}
+	return time.Second << uint(attempt)
+}
+
+// Remembers when the rate limit resets once we run out of requests,
+// so the next request waits instead of getting a 429 response
+func (c *Client) updateRateLimit(header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rateLimitRemaining = remaining
+	if remaining > 0 {
+		c.rateLimitReset = time.Time{}
+		return
+	}
+	if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		c.rateLimitReset = time.Unix(reset, 0)
+	}
+}
+
+func (c *Client) waitForRateLimitReset() {
+	c.mu.Lock()
+	reset := c.rateLimitReset
+	c.rateLimitReset = time.Time{}
+	c.mu.Unlock()
+
+	if wait := time.Until(reset); !reset.IsZero() && wait > 0 && wait <= c.maxRetryWait() {
+		c.doSleep(wait)
+	}
+}
+
+func (c *Client) maxRetryWait() time.Duration {
+	if c.MaxRetryWait == 0 {
+		return 2 * time.Minute
+	}
+	return c.MaxRetryWait
+}
+
+func (c *Client) doSleep(d time.Duration) {
+	if c.sleep != nil {
+		c.sleep(d)
+	} else {
+		time.Sleep(d)
+	}
+}
@@ -0,0 +1,179 @@
+package anilist
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aqatl/mal/oauth2"
+	"github.com/pkg/errors"
+)
+
+func newTestClient(url string, slept *[]time.Duration) *Client {
+	c := NewClient(oauth2.OAuthToken{Token: "token"})
+	c.Endpoint = url
+	c.sleep = func(d time.Duration) {
+		*slept = append(*slept, d)
+	}
+	return c
+}
+
+func TestClientRetriesRateLimitedRequests(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"data":null,"errors":[{"message":"Too Many Requests.","status":429}]}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"AiringSchedule":{"id":1,"episode":3}}}`))
+	}))
+	defer srv.Close()
+
+	var slept []time.Duration
+	c := newTestClient(srv.URL, &slept)
+	schedule, err := c.QueryAiringSchedule(1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schedule.Episode != 3 {
+		t.Error("Expected episode 3, got", schedule.Episode)
+	}
+	if len(slept) != 2 || slept[0] != 7*time.Second {
+		t.Error("Expected two 7s waits, got", slept)
+	}
+
+	requests = -10
+	c.MaxRetries = 1
+	_, err = c.QueryAiringSchedule(1, 3)
+	if !errors.Is(err, RateLimited) {
+		t.Error("Expected RateLimited error, got", err)
+	}
+}
+
+func TestClientWaitsForRateLimitReset(t *testing.T) {
+	reset := time.Now().Add(30 * time.Second).Unix()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		w.Write([]byte(`{"data":{}}`))
+	}))
+	defer srv.Close()
+
+	var slept []time.Duration
+	c := newTestClient(srv.URL, &slept)
+	c.QueryAiringSchedule(1, 1)
+	if len(slept) != 0 || c.RateLimitRemaining() != 0 {
+		t.Fatal("Unexpected state after first request:", slept, c.RateLimitRemaining())
+	}
+	c.QueryAiringSchedule(1, 1)
+	if len(slept) != 1 || slept[0] <= 0 || slept[0] > 30*time.Second {
+		t.Error("Expected a wait until the rate limit reset, got", slept)
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"data":null,"errors":[
+			{"message":"validation","status":400,"locations":[{"line":2,"column":3}],
+				"validation":{"score":["The score must be between 0 and 100."]}},
+			{"message":"Invalid token","status":400}
+		]}`))
+	}))
+	defer srv.Close()
+
+	var slept []time.Duration
+	err := newTestClient(srv.URL, &slept).SaveMediaListEntry(&MediaListEntry{})
+
+	var queryErrs *QueryErrors
+	if !errors.As(err, &queryErrs) {
+		t.Fatalf("Expected *QueryErrors, got %T", err)
+	}
+	if queryErrs.StatusCode != http.StatusBadRequest || len(queryErrs.Errors) != 2 {
+		t.Error("Unexpected errors:", queryErrs)
+	}
+	if !errors.Is(err, InvalidToken) || errors.Is(err, NotFound) {
+		t.Error("errors.Is returned wrong result")
+	}
+	msg := err.Error()
+	for _, part := range []string{"Line 2 column 3", "score: The score must be", "Invalid token"} {
+		if !strings.Contains(msg, part) {
+			t.Errorf("Expected %q in error message %q", part, msg)
+		}
+	}
+}
+
+func TestDeprecatedFunctionsUseDefaultClient(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Write([]byte(`{"data":{"AiringSchedule":{"id":1,"episode":3}}}`))
+	}))
+	defer srv.Close()
+
+	endpoint := DefaultClient.Endpoint
+	DefaultClient.Endpoint = srv.URL
+	defer func() { DefaultClient.Endpoint = endpoint }()
+
+	schedule, err := QueryAiringSchedule(1, 3, oauth2.OAuthToken{Token: "old-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schedule.Episode != 3 || !strings.Contains(auth, "old-token") {
+		t.Error("Unexpected result:", schedule, auth)
+	}
+}
+
+func TestSearchFiltered(t *testing.T) {
+	var vars map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := struct {
+			Variables map[string]interface{} `json:"variables"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		vars = body.Variables
+		w.Write([]byte(`{"data":{"Page":{"pageInfo":{"hasNextPage":true},"media":[{"id":1}]}}}`))
+	}))
+	defer srv.Close()
+
+	var slept []time.Duration
+	adult := false
+	filters := SearchFilters{Genres: []string{"Drama"}, Season: "FALL", Year: 2019, Format: "TV",
+		MinScore: 70, IsAdult: &adult, Sort: "SCORE_DESC"}
+	media, hasNextPage, err := newTestClient(srv.URL, &slept).SearchFiltered("", filters, 2, 50, Anime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(media) != 1 || !hasNextPage {
+		t.Error("Unexpected results:", media, hasNextPage)
+	}
+
+	expected := map[string]interface{}{
+		"page": 2.0, "perPage": 50.0, "type": "ANIME", "genres": []interface{}{"Drama"}, "season": "FALL",
+		"seasonYear": 2019.0, "format": "TV", "scoreGreater": 69.0,
+		"isAdult": false, "sort": []interface{}{"SCORE_DESC"},
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected variables %v, got %v", expected, vars)
+	}
+
+	// Without a season the year filters by start date
+	newTestClient(srv.URL, &slept).SearchFiltered("", SearchFilters{Year: 2019}, 1, 50, Anime)
+	expected = map[string]interface{}{
+		"page": 1.0, "perPage": 50.0, "type": "ANIME",
+		"startDateGreater": 20190000.0, "startDateLesser": 20200000.0,
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected variables %v, got %v", expected, vars)
+	}
+}
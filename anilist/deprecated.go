@@ -0,0 +1,101 @@
+package anilist
+
+import (
+	"github.com/aqatl/mal/oauth2"
+)
+
+// Package level functions kept for code written before Client existed.
+// They send requests with DefaultClient's settings on behalf of the given token.
+
+// DefaultClient holds the settings used by the deprecated package level functions;
+// its Token is ignored, each function uses the token passed to it
+var DefaultClient = NewClient(oauth2.OAuthToken{})
+
+func defaultClientWithToken(token oauth2.OAuthToken) *Client {
+	c := NewClient(token)
+	c.Endpoint = DefaultClient.Endpoint
+	c.HttpClient = DefaultClient.HttpClient
+	c.UserAgent = DefaultClient.UserAgent
+	c.Timeout = DefaultClient.Timeout
+	c.MaxRetries = DefaultClient.MaxRetries
+	c.MaxRetryWait = DefaultClient.MaxRetryWait
+	return c
+}
+
+// Deprecated: use Client.QueryUserLists.
+func QueryUserLists(userId int, scoreFormat ScoreFormat, token oauth2.OAuthToken) ([]MediaListGroup, error) {
+	return defaultClientWithToken(token).QueryUserLists(userId, Anime, scoreFormat)
+}
+
+// Deprecated: use Client.QueryAuthenticatedUser.
+func QueryAuthenticatedUser(user *User, token oauth2.OAuthToken) error {
+	return defaultClientWithToken(token).QueryAuthenticatedUser(user)
+}
+
+// Deprecated: use Client.SaveMediaListEntry.
+func SaveMediaListEntry(entry *MediaListEntry, token oauth2.OAuthToken) error {
+	return defaultClientWithToken(token).SaveMediaListEntry(entry)
+}
+
+// Deprecated: use Client.AddMediaListEntry.
+func AddMediaListEntry(id int, status MediaListStatus, token oauth2.OAuthToken) (
+	MediaListEntry, error,
+) {
+	return defaultClientWithToken(token).AddMediaListEntry(id, status)
+}
+
+// Deprecated: use Client.QueryAiringSchedule.
+func QueryAiringSchedule(mediaId, episode int, token oauth2.OAuthToken) (AiringSchedule, error) {
+	return defaultClientWithToken(token).QueryAiringSchedule(mediaId, episode)
+}
+
+// Deprecated: use Client.QueryAiringNotification.
+func QueryAiringNotification(markRead bool, token oauth2.OAuthToken) (AiringNotification, error) {
+	return defaultClientWithToken(token).QueryAiringNotification(markRead)
+}
+
+// Deprecated: use Client.QueryAiringNotifications.
+func QueryAiringNotifications(page, perPage int, markRead bool, token oauth2.OAuthToken) (
+	[]AiringNotification, error,
+) {
+	return defaultClientWithToken(token).QueryAiringNotifications(page, perPage, markRead)
+}
+
+// Deprecated: use Client.DeleteMediaListEntry.
+func DeleteMediaListEntry(entry *MediaListEntry, token oauth2.OAuthToken) error {
+	return defaultClientWithToken(token).DeleteMediaListEntry(entry)
+}
+
+// Deprecated: use Client.Search.
+func Search(query string, page, perPage int, mtype MediaType, token oauth2.OAuthToken) ([]MediaFull, error) {
+	return defaultClientWithToken(token).Search(query, page, perPage, mtype)
+}
+
+// Deprecated: use Client.QueryUserListsWaitAnimation.
+func QueryUserListsWaitAnimation(userId int, scoreFormat ScoreFormat, token oauth2.OAuthToken) ([]MediaListGroup, error) {
+	return defaultClientWithToken(token).QueryUserListsWaitAnimation(userId, Anime, scoreFormat)
+}
+
+// Deprecated: use Client.QueryAuthenticatedUserWaitAnimation.
+func QueryAuthenticatedUserWaitAnimation(user *User, token oauth2.OAuthToken) error {
+	return defaultClientWithToken(token).QueryAuthenticatedUserWaitAnimation(user)
+}
+
+// Deprecated: use Client.SaveMediaListEntryWaitAnimation.
+func SaveMediaListEntryWaitAnimation(entry *MediaListEntry, token oauth2.OAuthToken) error {
+	return defaultClientWithToken(token).SaveMediaListEntryWaitAnimation(entry)
+}
+
+// Deprecated: use Client.QueryAiringScheduleWaitAnimation.
+func QueryAiringScheduleWaitAnimation(mediaId, episode int, token oauth2.OAuthToken) (
+	AiringSchedule, error,
+) {
+	return defaultClientWithToken(token).QueryAiringScheduleWaitAnimation(mediaId, episode)
+}
+
+// Deprecated: use Client.QueryAiringNotificationsWaitAnimation.
+func QueryAiringNotificationsWaitAnimation(page, perPage int, markRead bool, token oauth2.OAuthToken) (
+	[]AiringNotification, error,
+) {
+	return defaultClientWithToken(token).QueryAiringNotificationsWaitAnimation(page, perPage, markRead)
+}
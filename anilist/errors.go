@@ -0,0 +1,71 @@
+package anilist
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var InvalidToken = errors.New("Invalid token")
+var NotFound = errors.New("Not found")
+var RateLimited = errors.New("Too many requests")
+
+// QueryErrors is returned when AniList responds with one or more GraphQL errors.
+// Use errors.Is with InvalidToken, NotFound or RateLimited to check for the common cases.
+type QueryErrors struct {
+	// HTTP status code of the response
+	StatusCode int
+	Errors     []GqlError
+}
+
+func (e *QueryErrors) Error() string {
+	b := strings.Builder{}
+	for i := range e.Errors {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(e.Errors[i].Error())
+	}
+	if len(e.Errors) == 0 {
+		fmt.Fprintf(&b, "GraphQl Error (%d)", e.StatusCode)
+	}
+	return b.String()
+}
+
+func (e *QueryErrors) Is(target error) bool {
+	for _, gqlErr := range e.Errors {
+		switch {
+		case target == InvalidToken && gqlErr.Message == "Invalid token":
+			return true
+		case target == NotFound && gqlErr.Status == http.StatusNotFound:
+			return true
+		case target == RateLimited && gqlErr.Status == http.StatusTooManyRequests:
+			return true
+		}
+	}
+	return target == RateLimited && e.StatusCode == http.StatusTooManyRequests
+}
+
+func (e GqlError) Error() string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "GraphQl Error (%d): %s", e.Status, e.Message)
+	for _, loc := range e.Locations {
+		fmt.Fprintf(&b, "\nLine %d column %d", loc.Line, loc.Column)
+	}
+	for field, msgs := range e.Validation {
+		fmt.Fprintf(&b, "\n%s: %s", field, strings.Join(msgs, ", "))
+	}
+	return b.String()
+}
+
+func gqlErrorsHandler(queryErrs *QueryErrors, err error) error {
+	if err != nil {
+		return err
+	}
+	if queryErrs != nil {
+		return queryErrs
+	}
+	return nil
+}
@@ -1,33 +1,28 @@
 package anilist
 
-var queryUserAnimeList = `
-query UserList ($userID: Int, $scoreFormat: ScoreFormat) {
-	MediaListCollection (userId: $userID, type: ANIME) {
+var queryUserMediaList = `
+query UserList ($userID: Int, $type: MediaType, $scoreFormat: ScoreFormat) {
+	MediaListCollection (userId: $userID, type: $type) {
 		lists {
 			entries {
-				id
-				status
-				score(format: $scoreFormat)
-				progress
-				repeat
-				updatedAt
-				media {
-					id
-					idMal
-					title {
-						romaji
-						english
-						native
-						userPreferred
-					}
-					type
-					format
-					status
-					season
-					episodes
-					duration
-					synonyms
-				}
+				` + mediaListEntry + `
+			}
+			name
+			isCustomList
+			isSplitCompletedList
+			status
+		}
+	}
+}
+`
+
+// Public list of any user
+var queryUserMediaListByName = `
+query UserList ($userName: String, $type: MediaType, $scoreFormat: ScoreFormat) {
+	MediaListCollection (userName: $userName, type: $type) {
+		lists {
+			entries {
+				` + mediaListEntry + `
 			}
 			name
 			isCustomList
@@ -38,6 +33,63 @@ query UserList ($userID: Int, $scoreFormat: ScoreFormat) {
 }
 `
 
+// Entries of the list updated after given time, most recently updated first
+var queryUserMediaListChanges = `
+query ($userID: Int, $type: MediaType, $scoreFormat: ScoreFormat, $page: Int, $perPage: Int) {
+	Page(page: $page, perPage: $perPage) {
+		pageInfo {
+			hasNextPage
+		}
+		mediaList(userId: $userID, type: $type, sort: UPDATED_TIME_DESC) {
+			` + mediaListEntry + `
+		}
+	}
+}
+`
+
+// Only ids of all entries, used to find out which entries were deleted
+var queryUserMediaListIds = `
+query ($userID: Int, $type: MediaType) {
+	MediaListCollection (userId: $userID, type: $type) {
+		lists {
+			entries {
+				id
+			}
+		}
+	}
+}
+`
+
+var queryMediaByMalIds = `
+query ($idMal: [Int], $type: MediaType, $page: Int, $perPage: Int) {
+	Page(page: $page, perPage: $perPage) {
+		pageInfo {
+			hasNextPage
+		}
+		media(idMal_in: $idMal, type: $type) {
+			id
+			idMal
+			title {
+				romaji
+				english
+				native
+				userPreferred
+			}
+			type
+			format
+			status
+			season
+			seasonYear
+			episodes
+			duration
+			chapters
+			volumes
+			synonyms
+		}
+	}
+}
+`
+
 var queryUserAnimeListFullDetails = `
 query UserList ($userID: Int) {
 	MediaListCollection (userId: $userID, type: ANIME) {
@@ -83,18 +135,57 @@ query {
 		updatedAt
 		mediaListOptions {
 			scoreFormat
+			animeList {
+				advancedScoringEnabled
+				advancedScoring
+			}
+			mangaList {
+				advancedScoringEnabled
+				advancedScoring
+			}
 		}
 	}
 }
 `
 
 var saveMediaListEntry = `
-mutation ($listId: Int, $mediaId: Int, $status: MediaListStatus, $progress: Int, $score: Float) {
-	SaveMediaListEntry (id: $listId, mediaId: $mediaId, status: $status, progress: $progress, score: $score) {
+mutation ($listId: Int, $mediaId: Int, $status: MediaListStatus, $progress: Int, $progressVolumes: Int, $score: Float, $advancedScores: [Float], $repeat: Int, $customLists: [String],
+		$notes: String, $private: Boolean, $hiddenFromStatusLists: Boolean, $startedAt: FuzzyDateInput, $completedAt: FuzzyDateInput) {
+	SaveMediaListEntry (id: $listId, mediaId: $mediaId, status: $status, progress: $progress, progressVolumes: $progressVolumes, score: $score, advancedScores: $advancedScores, repeat: $repeat, customLists: $customLists,
+			notes: $notes, private: $private, hiddenFromStatusLists: $hiddenFromStatusLists, startedAt: $startedAt, completedAt: $completedAt) {
 		id
 		status
 		progress
+		progressVolumes
 		score
+		repeat
+		updatedAt
+		customLists(asArray: true)
+		advancedScores
+		notes
+		private
+		hiddenFromStatusLists
+		startedAt {
+			year
+			month
+			day
+		}
+		completedAt {
+			year
+			month
+			day
+		}
+	}
+}
+`
+
+var queryMediaListEntry = `
+query ($id: Int) {
+	MediaList(id: $id) {
+		id
+		status
+		progress
+		progressVolumes
 		updatedAt
 	}
 }
@@ -107,8 +198,24 @@ mutation ($mediaId: Int, $status: MediaListStatus) {
 		status
 		score(format: POINT_10)
 		progress
+		progressVolumes
 		repeat
 		updatedAt
+		customLists(asArray: true)
+		advancedScores
+		notes
+		private
+		hiddenFromStatusLists
+		startedAt {
+			year
+			month
+			day
+		}
+		completedAt {
+			year
+			month
+			day
+		}
 		media {
 			id
 			idMal
@@ -122,8 +229,11 @@ mutation ($mediaId: Int, $status: MediaListStatus) {
 			format
 			status
 			season
+			seasonYear
 			episodes
 			duration
+			chapters
+			volumes
 			synonyms
 		}
 	}
@@ -142,6 +252,47 @@ query ($mediaId: Int, $episode: Int) {
 }
 `
 
+// Episodes of given media airing between two unix times, soonest first
+var queryAiringSchedules = `
+query ($page: Int, $perPage: Int, $mediaIds: [Int], $from: Int, $to: Int) {
+	Page(page: $page, perPage: $perPage) {
+		pageInfo {
+			hasNextPage
+		}
+		airingSchedules(mediaId_in: $mediaIds, airingAt_greater: $from, airingAt_lesser: $to, sort: TIME) {
+			id
+			airingAt
+			timeUntilAiring
+			episode
+			mediaId
+		}
+	}
+}
+`
+
+// Just enough to tell how many episodes of given media have aired
+var queryNextAiringEpisodes = `
+query ($page: Int, $perPage: Int, $ids: [Int]) {
+	Page(page: $page, perPage: $perPage) {
+		pageInfo {
+			hasNextPage
+		}
+		media(id_in: $ids) {
+			id
+			status
+			episodes
+			duration
+			nextAiringEpisode {
+				id
+				airingAt
+				timeUntilAiring
+				episode
+			}
+		}
+	}
+}
+`
+
 var queryAiringNotification = `
 query ($resetNotificationCount: Boolean) {
 	Notification(type: AIRING, resetNotificationCount: $resetNotificationCount) {
@@ -188,6 +339,223 @@ query ($page: Int, $perPage: Int, $resetNotificationCount: Boolean) {
 }
 `
 
+var queryNotifications = `
+query ($page: Int, $perPage: Int, $types: [NotificationType], $resetNotificationCount: Boolean) {
+	Page(page: $page, perPage: $perPage) {
+		pageInfo {
+			hasNextPage
+		}
+		notifications(type_in: $types, resetNotificationCount: $resetNotificationCount) {
+			... on AiringNotification {
+				id
+				type
+				createdAt
+				episode
+				contexts
+				media {
+					...NotificationMedia
+				}
+			}
+			... on FollowingNotification {
+				id
+				type
+				createdAt
+				context
+				user {
+					name
+				}
+			}
+			... on ActivityMessageNotification {
+				id
+				type
+				createdAt
+				context
+				activityId
+				user {
+					name
+				}
+			}
+			... on ActivityMentionNotification {
+				id
+				type
+				createdAt
+				context
+				activityId
+				user {
+					name
+				}
+			}
+			... on ActivityReplyNotification {
+				id
+				type
+				createdAt
+				context
+				activityId
+				user {
+					name
+				}
+			}
+			... on ActivityReplySubscribedNotification {
+				id
+				type
+				createdAt
+				context
+				activityId
+				user {
+					name
+				}
+			}
+			... on ActivityLikeNotification {
+				id
+				type
+				createdAt
+				context
+				activityId
+				user {
+					name
+				}
+			}
+			... on ActivityReplyLikeNotification {
+				id
+				type
+				createdAt
+				context
+				activityId
+				user {
+					name
+				}
+			}
+			... on ThreadCommentMentionNotification {
+				id
+				type
+				createdAt
+				context
+				user {
+					name
+				}
+				thread {
+					id
+					title
+				}
+			}
+			... on ThreadCommentReplyNotification {
+				id
+				type
+				createdAt
+				context
+				user {
+					name
+				}
+				thread {
+					id
+					title
+				}
+			}
+			... on ThreadCommentSubscribedNotification {
+				id
+				type
+				createdAt
+				context
+				user {
+					name
+				}
+				thread {
+					id
+					title
+				}
+			}
+			... on ThreadCommentLikeNotification {
+				id
+				type
+				createdAt
+				context
+				user {
+					name
+				}
+				thread {
+					id
+					title
+				}
+			}
+			... on ThreadLikeNotification {
+				id
+				type
+				createdAt
+				context
+				user {
+					name
+				}
+				thread {
+					id
+					title
+				}
+			}
+			... on RelatedMediaAdditionNotification {
+				id
+				type
+				createdAt
+				context
+				media {
+					...NotificationMedia
+				}
+			}
+			... on MediaDataChangeNotification {
+				id
+				type
+				createdAt
+				context
+				reason
+				media {
+					...NotificationMedia
+				}
+			}
+			... on MediaMergeNotification {
+				id
+				type
+				createdAt
+				context
+				reason
+				deletedMediaTitles
+				media {
+					...NotificationMedia
+				}
+			}
+			... on MediaDeletionNotification {
+				id
+				type
+				createdAt
+				context
+				reason
+				deletedMediaTitle
+			}
+		}
+	}
+}
+
+fragment NotificationMedia on Media {
+	id
+	type
+	title {
+		romaji
+		english
+		native
+		userPreferred
+	}
+}
+`
+
+var resetNotificationCount = `
+query {
+	Page(page: 1, perPage: 1) {
+		notifications(resetNotificationCount: true) {
+			... on AiringNotification {
+				id
+			}
+		}
+	}
+}
+`
+
 var deleteMediaListEntry = `
 mutation ($id: Int) {
 	DeleteMediaListEntry(id: $id) {
@@ -196,6 +564,51 @@ mutation ($id: Int) {
 }
 `
 
+var mediaListEntry = `
+id
+status
+score(format: $scoreFormat)
+progress
+progressVolumes
+repeat
+updatedAt
+customLists(asArray: true)
+advancedScores
+notes
+private
+hiddenFromStatusLists
+startedAt {
+	year
+	month
+	day
+}
+completedAt {
+	year
+	month
+	day
+}
+media {
+	id
+	idMal
+	title {
+		romaji
+		english
+		native
+		userPreferred
+	}
+	type
+	format
+	status
+	season
+	seasonYear
+	episodes
+	duration
+	chapters
+	volumes
+	synonyms
+}
+`
+
 var mediaFull = `
 id
 idMal
@@ -259,12 +672,26 @@ nextAiringEpisode {
 	episode
 }
 siteUrl
+studios(isMain: true) {
+	nodes {
+		id
+		name
+	}
+}
 `
 
 var queryMedia = `
-query ($page: Int, $perPage: Int, $search: String, $type: MediaType) {
+query ($page: Int, $perPage: Int, $search: String, $type: MediaType, $genres: [String], $tags: [String],
+	$season: MediaSeason, $seasonYear: Int, $startDateGreater: FuzzyDateInt, $startDateLesser: FuzzyDateInt, $format: MediaFormat,
+	$status: MediaStatus, $scoreGreater: Int, $scoreLesser: Int, $isAdult: Boolean, $sort: [MediaSort]) {
 	Page(page: $page, perPage: $perPage) {
-		media(search: $search, type: $type) {
+		pageInfo {
+			hasNextPage
+		}
+		media(search: $search, type: $type, genre_in: $genres, tag_in: $tags, season: $season,
+			seasonYear: $seasonYear, startDate_greater: $startDateGreater, startDate_lesser: $startDateLesser, format: $format,
+			status: $status, averageScore_greater: $scoreGreater, averageScore_lesser: $scoreLesser,
+			isAdult: $isAdult, sort: $sort) {
 			` + mediaFull + `		
 		}
 	}
@@ -275,3 +702,46 @@ fragment FuzzyDateFields on FuzzyDate {
 	day
 }
 `
+
+var queryGenres = `
+query {
+	GenreCollection
+}
+`
+
+// Anime of given season, most popular first
+var querySeason = `
+query ($page: Int, $perPage: Int, $season: MediaSeason, $seasonYear: Int) {
+	Page(page: $page, perPage: $perPage) {
+		pageInfo {
+			hasNextPage
+		}
+		media(season: $season, seasonYear: $seasonYear, type: ANIME, sort: POPULARITY_DESC) {
+			` + mediaFull + `
+		}
+	}
+}
+fragment FuzzyDateFields on FuzzyDate {
+	year
+	month
+	day
+}
+`
+
+var queryMediaByIds = `
+query ($page: Int, $perPage: Int, $ids: [Int]) {
+	Page(page: $page, perPage: $perPage) {
+		pageInfo {
+			hasNextPage
+		}
+		media(id_in: $ids) {
+			` + mediaFull + `
+		}
+	}
+}
+fragment FuzzyDateFields on FuzzyDate {
+	year
+	month
+	day
+}
+`
@@ -1,7 +1,13 @@
 package anilist
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
 	"strings"
+
+	"github.com/pkg/errors"
 )
 
 type User struct {
@@ -23,7 +29,22 @@ type UserStats struct {
 }
 
 type MediaListOptions struct {
-	ScoreFormat ScoreFormat `json:"scoreFormat"`
+	ScoreFormat ScoreFormat          `json:"scoreFormat"`
+	AnimeList   MediaListTypeOptions `json:"animeList"`
+	MangaList   MediaListTypeOptions `json:"mangaList"`
+}
+
+type MediaListTypeOptions struct {
+	AdvancedScoringEnabled bool     `json:"advancedScoringEnabled"`
+	AdvancedScoring        []string `json:"advancedScoring"`
+}
+
+// TypeOptions returns options of the anime or manga list.
+func (options *MediaListOptions) TypeOptions(mediaType MediaType) MediaListTypeOptions {
+	if mediaType == Manga {
+		return options.MangaList
+	}
+	return options.AnimeList
 }
 
 type ScoreFormat string
@@ -49,63 +70,170 @@ type MediaListGroup struct {
 }
 
 type MediaListEntry struct {
-	ListId    int             `json:"id"`
-	Status    MediaListStatus `json:"status"`
-	Score     float32         `json:"score"`
-	Progress  int             `json:"progress"`
-	Repeat    int             `json:"repeat"`
-	UpdatedAt int             `json:"updatedAt"`
+	ListId          int             `json:"id"`
+	Status          MediaListStatus `json:"status"`
+	Score           float32         `json:"score"`
+	Progress        int             `json:"progress"`
+	ProgressVolumes int             `json:"progressVolumes"`
+	Repeat          int             `json:"repeat"`
+	UpdatedAt       int             `json:"updatedAt"`
+	CustomLists     []CustomList    `json:"customLists"`
+	AdvancedScores  AdvancedScores  `json:"advancedScores"`
+
+	// Nil for entries cached before these fields were fetched
+	StartedAt             *FuzzyDate `json:"startedAt"`
+	CompletedAt           *FuzzyDate `json:"completedAt"`
+	Notes                 string     `json:"notes"`
+	Private               bool       `json:"private"`
+	HiddenFromStatusLists bool       `json:"hiddenFromStatusLists"`
 
 	MediaDeficient `json:"media"`
 }
 
+type AdvancedScore struct {
+	Name  string
+	Score float32
+}
+
+// Scores of advanced scoring categories in the order set by the user.
+// AniList sends them as a json object, or as an empty array if there are none
+type AdvancedScores []AdvancedScore
+
+func (scores *AdvancedScores) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		*scores = nil
+		return nil
+	}
+
+	parsed := make(AdvancedScores, 0)
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var score float32
+		if err := dec.Decode(&score); err != nil {
+			return err
+		}
+		parsed = append(parsed, AdvancedScore{Name: token.(string), Score: score})
+	}
+	*scores = parsed
+	return nil
+}
+
+func (scores AdvancedScores) MarshalJSON() ([]byte, error) {
+	if scores == nil {
+		return []byte("null"), nil
+	}
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, score := range scores {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(score.Name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.WriteString(strconv.FormatFloat(float64(score.Score), 'f', -1, 32))
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Membership of an entry in one of the user's custom lists
+type CustomList struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// CustomListNames returns names of custom lists the entry belongs to.
+func (entry *MediaListEntry) CustomListNames() []string {
+	names := make([]string, 0)
+	for _, list := range entry.CustomLists {
+		if list.Enabled {
+			names = append(names, list.Name)
+		}
+	}
+	return names
+}
+
 type MediaDeficient struct {
-	Id       int        `json:"id"`
-	IdMal    int        `json:"idMal"`
-	Title    MediaTitle `json:"title"`
-	Type     string     `json:"type"`
-	Format   string     `json:"format"`
-	Status   string     `json:"status"`
-	Season   string     `json:"season"`
-	Episodes int        `json:"episodes"`
-	Duration int        `json:"duration"`
-	Synonyms []string   `json:"synonyms"`
+	Id         int        `json:"id"`
+	IdMal      int        `json:"idMal"`
+	Title      MediaTitle `json:"title"`
+	Type       string     `json:"type"`
+	Format     string     `json:"format"`
+	Status     string     `json:"status"`
+	Season     string     `json:"season"`
+	SeasonYear int        `json:"seasonYear"`
+	Episodes   int        `json:"episodes"`
+	Duration   int        `json:"duration"`
+	Chapters   int        `json:"chapters"`
+	Volumes    int        `json:"volumes"`
+	Synonyms   []string   `json:"synonyms"`
+}
+
+// Length returns the total number of progress units of the media, that is
+// episodes for anime and chapters for manga.
+func (media *MediaDeficient) Length() int {
+	if MediaType(media.Type) == Manga {
+		return media.Chapters
+	}
+	return media.Episodes
 }
 
 type MediaFull struct {
-	Id                int             `json:"id"`
-	IdMal             int             `json:"idMal"`
-	Title             MediaTitle      `json:"title"`
-	Type              MediaType       `json:"type"`
-	Format            string          `json:"format"`
-	Status            string          `json:"status"`
-	Description       string          `json:"description"`
-	StartDate         FuzzyDate       `json:"startDate"`
-	EndDate           FuzzyDate       `json:"endDate"`
-	Season            string          `json:"season"`
-	Episodes          int             `json:"episodes"`
-	Duration          int             `json:"duration"`
-	Chapters          int             `json:"chapters"`
-	Volumes           int             `json:"volumes"`
-	CountryOfOrigin   string          `json:"countryOfOrigin"`
-	IsLicensed        bool            `json:"isLicensed"`
-	Source            string          `json:"source"`
-	HashTag           string          `json:"hashtag"`
-	Trailer           MediaTrailer    `json:"trailer"`
-	UpdatedAt         int             `json:"updatedAt"`
-	CoverImage        MediaCoverImage `json:"coverImage"`
-	BannerImage       string          `json:"bannerImage"`
-	Genres            []string        `json:"genres"`
-	Synonyms          []string        `json:"synonyms"`
-	AverageScore      int             `json:"averageScore"`
-	MeanScore         int             `json:"meanScore"`
-	Popularity        int             `json:"popularity"`
-	Trending          int             `json:"trending"`
-	Tags              []MediaTag      `json:"tags"`
-	IsFavourite       bool            `json:"isFavourite"`
-	IsAdult           bool            `json:"isAdult"`
-	NextAiringEpisode AiringSchedule  `json:"nextAiringEpisode"`
-	SiteUrl           string          `json:"siteUrl"`
+	Id                int              `json:"id"`
+	IdMal             int              `json:"idMal"`
+	Title             MediaTitle       `json:"title"`
+	Type              MediaType        `json:"type"`
+	Format            string           `json:"format"`
+	Status            string           `json:"status"`
+	Description       string           `json:"description"`
+	StartDate         FuzzyDate        `json:"startDate"`
+	EndDate           FuzzyDate        `json:"endDate"`
+	Season            string           `json:"season"`
+	Episodes          int              `json:"episodes"`
+	Duration          int              `json:"duration"`
+	Chapters          int              `json:"chapters"`
+	Volumes           int              `json:"volumes"`
+	CountryOfOrigin   string           `json:"countryOfOrigin"`
+	IsLicensed        bool             `json:"isLicensed"`
+	Source            string           `json:"source"`
+	HashTag           string           `json:"hashtag"`
+	Trailer           MediaTrailer     `json:"trailer"`
+	UpdatedAt         int              `json:"updatedAt"`
+	CoverImage        MediaCoverImage  `json:"coverImage"`
+	BannerImage       string           `json:"bannerImage"`
+	Genres            []string         `json:"genres"`
+	Synonyms          []string         `json:"synonyms"`
+	AverageScore      int              `json:"averageScore"`
+	MeanScore         int              `json:"meanScore"`
+	Popularity        int              `json:"popularity"`
+	Trending          int              `json:"trending"`
+	Tags              []MediaTag       `json:"tags"`
+	IsFavourite       bool             `json:"isFavourite"`
+	IsAdult           bool             `json:"isAdult"`
+	NextAiringEpisode AiringSchedule   `json:"nextAiringEpisode"`
+	SiteUrl           string           `json:"siteUrl"`
+	Studios           StudioConnection `json:"studios"`
+}
+
+type StudioConnection struct {
+	Nodes []Studio `json:"nodes"`
+}
+
+type Studio struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
 }
 
 type MediaTitle struct {
@@ -119,20 +247,66 @@ type MediaType string
 
 const (
 	Anime = MediaType("ANIME")
-	Mange = MediaType("MANGA")
+	Manga = MediaType("MANGA")
+
+	// Deprecated: use Manga.
+	Mange = Manga
 )
 
+func ParseMediaType(mediaType string) (MediaType, error) {
+	switch strings.ToLower(mediaType) {
+	case "anime":
+		return Anime, nil
+	case "manga":
+		return Manga, nil
+	default:
+		return "", errors.New("invalid media type; possible values: anime|manga")
+	}
+}
+
 type FuzzyDate struct {
 	Year  int `json:"year"`
 	Month int `json:"month"`
 	Day   int `json:"day"`
 }
 
+func (date FuzzyDate) IsZero() bool {
+	return date.Year == 0 && date.Month == 0 && date.Day == 0
+}
+
+// String formats the date as yyyy-mm-dd, leaving out unknown parts.
+func (date FuzzyDate) String() string {
+	switch {
+	case date.Year == 0:
+		return ""
+	case date.Month == 0:
+		return fmt.Sprintf("%04d", date.Year)
+	case date.Day == 0:
+		return fmt.Sprintf("%04d-%02d", date.Year, date.Month)
+	default:
+		return fmt.Sprintf("%04d-%02d-%02d", date.Year, date.Month, date.Day)
+	}
+}
+
+// Unknown parts are sent as nulls, so zero date clears the field
+func (date FuzzyDate) input() map[string]interface{} {
+	input := make(map[string]interface{})
+	for name, value := range map[string]int{"year": date.Year, "month": date.Month, "day": date.Day} {
+		if value != 0 {
+			input[name] = value
+		} else {
+			input[name] = nil
+		}
+	}
+	return input
+}
+
 type AiringSchedule struct {
 	Id              int `json:"id"`
 	AiringAt        int `json:"airingAt"`
 	TimeUntilAiring int `json:"timeUntilAiring"`
 	Episode         int `json:"episode"`
+	MediaId         int `json:"mediaId"`
 }
 
 type MediaTrailer struct {
@@ -160,6 +334,8 @@ type GqlError struct {
 	Message   string     `json:"message"`
 	Status    int        `json:"status"`
 	Locations []Location `json:"locations"`
+	// Invalid arguments, keyed by argument name
+	Validation map[string][]string `json:"validation"`
 }
 
 type Location struct {
@@ -200,3 +376,91 @@ type AiringNotification struct {
 		MediaTitle `json:"title"`
 	} `json:"media"`
 }
+
+type NotificationType string
+
+const (
+	NotificationAiring                  NotificationType = "AIRING"
+	NotificationActivityMessage         NotificationType = "ACTIVITY_MESSAGE"
+	NotificationActivityReply           NotificationType = "ACTIVITY_REPLY"
+	NotificationActivityMention         NotificationType = "ACTIVITY_MENTION"
+	NotificationActivityLike            NotificationType = "ACTIVITY_LIKE"
+	NotificationActivityReplyLike       NotificationType = "ACTIVITY_REPLY_LIKE"
+	NotificationActivityReplySubscribed NotificationType = "ACTIVITY_REPLY_SUBSCRIBED"
+	NotificationFollowing               NotificationType = "FOLLOWING"
+	NotificationThreadCommentMention    NotificationType = "THREAD_COMMENT_MENTION"
+	NotificationThreadCommentReply      NotificationType = "THREAD_COMMENT_REPLY"
+	NotificationThreadSubscribed        NotificationType = "THREAD_SUBSCRIBED"
+	NotificationThreadLike              NotificationType = "THREAD_LIKE"
+	NotificationThreadCommentLike       NotificationType = "THREAD_COMMENT_LIKE"
+	NotificationRelatedMediaAddition    NotificationType = "RELATED_MEDIA_ADDITION"
+	NotificationMediaDataChange         NotificationType = "MEDIA_DATA_CHANGE"
+	NotificationMediaMerge              NotificationType = "MEDIA_MERGE"
+	NotificationMediaDeletion           NotificationType = "MEDIA_DELETION"
+)
+
+// Any member of the NotificationUnion; fields a type doesn't have are left empty
+type Notification struct {
+	Id        int              `json:"id"`
+	Type      NotificationType `json:"type"`
+	CreatedAt int              `json:"createdAt"`
+	// Text around the other fields, e.g. " started following you."
+	Context string `json:"context"`
+	// Airing notifications split the text into parts put between episode and title
+	Contexts           []string `json:"contexts"`
+	Episode            int      `json:"episode"`
+	ActivityId         int      `json:"activityId"`
+	Reason             string   `json:"reason"`
+	DeletedMediaTitle  string   `json:"deletedMediaTitle"`
+	DeletedMediaTitles []string `json:"deletedMediaTitles"`
+	User               struct {
+		Name string `json:"name"`
+	} `json:"user"`
+	Media struct {
+		Id    int        `json:"id"`
+		Type  MediaType  `json:"type"`
+		Title MediaTitle `json:"title"`
+	} `json:"media"`
+	Thread struct {
+		Id    int    `json:"id"`
+		Title string `json:"title"`
+	} `json:"thread"`
+}
+
+// Message as displayed on the site
+func (n Notification) Text() string {
+	title := n.Media.Title.UserPreferred
+	switch n.Type {
+	case NotificationAiring:
+		if len(n.Contexts) == 3 {
+			return n.Contexts[0] + strconv.Itoa(n.Episode) + n.Contexts[1] + title + n.Contexts[2]
+		}
+		return fmt.Sprintf("Episode %d of %s aired.", n.Episode, title)
+	case NotificationRelatedMediaAddition, NotificationMediaDataChange:
+		return title + n.Context
+	case NotificationMediaMerge:
+		return strings.Join(n.DeletedMediaTitles, ", ") + n.Context + title
+	case NotificationMediaDeletion:
+		return n.DeletedMediaTitle + n.Context
+	}
+	text := n.User.Name + n.Context
+	if n.Thread.Title != "" {
+		text += n.Thread.Title
+	}
+	return text
+}
+
+// Url of the site page the notification points to, empty if unknown
+func (n Notification) Url() string {
+	switch {
+	case n.ActivityId != 0:
+		return fmt.Sprintf("%s/activity/%d", ALDomain, n.ActivityId)
+	case n.Thread.Id != 0:
+		return fmt.Sprintf("%s/forum/thread/%d", ALDomain, n.Thread.Id)
+	case n.Media.Id != 0:
+		return fmt.Sprintf("%s/%s/%d", ALDomain, strings.ToLower(string(n.Media.Type)), n.Media.Id)
+	case n.User.Name != "":
+		return ALDomain + "/user/" + n.User.Name
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/aqatl/mal/anilist"
+)
+
+// Parses "story=8 visuals=9" pairs. Categories can be shortened to an unambiguous prefix,
+// scores of categories not mentioned are kept.
+func parseAdvancedScores(
+	args []string,
+	categories []string,
+	current anilist.AdvancedScores,
+	scoreFormat anilist.ScoreFormat,
+) (anilist.AdvancedScores, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no scores given; use e.g. %s=8", strings.ToLower(categories[0]))
+	}
+
+	scores := make(anilist.AdvancedScores, len(categories))
+	for i, category := range categories {
+		scores[i].Name = category
+		for _, score := range current {
+			if score.Name == category {
+				scores[i].Score = score.Score
+			}
+		}
+	}
+
+	for _, arg := range args {
+		pair := strings.SplitN(arg, "=", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("invalid advanced score %q; use category=score", arg)
+		}
+		idx, err := findAdvancedScoringCategory(categories, pair[0])
+		if err != nil {
+			return nil, err
+		}
+		if scores[idx].Score, err = parseScore(pair[1], scoreFormat); err != nil {
+			return nil, fmt.Errorf("%s: %v", categories[idx], err)
+		}
+	}
+	return scores, nil
+}
+
+func findAdvancedScoringCategory(categories []string, name string) (int, error) {
+	match := -1
+	for i, category := range categories {
+		if strings.EqualFold(category, name) {
+			return i, nil
+		}
+		if strings.HasPrefix(strings.ToLower(category), strings.ToLower(name)) {
+			if match != -1 {
+				return 0, fmt.Errorf("ambiguous category %q", name)
+			}
+			match = i
+		}
+	}
+	if match == -1 {
+		return 0, fmt.Errorf("unknown category %q; your categories: %s",
+			name, strings.Join(categories, ", "))
+	}
+	return match, nil
+}
+
+// Average of categories that were scored, the overall score AniList shows for advanced scores
+func averageAdvancedScore(scores anilist.AdvancedScores, scoreFormat anilist.ScoreFormat) float32 {
+	sum, count := float32(0), 0
+	for _, score := range scores {
+		if score.Score > 0 {
+			sum += score.Score
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	avg := float64(sum) / float64(count)
+	if scoreFormat == anilist.Point10Decimal {
+		return float32(math.Round(avg*10) / 10)
+	}
+	return float32(math.Round(avg))
+}
+
+func alSetEntryAdvancedScores(al *AniList, entry *anilist.MediaListEntry, args []string) error {
+	scoreFormat := al.User.MediaListOptions.ScoreFormat
+	if scoreFormat == anilist.Point5 || scoreFormat == anilist.Point3 {
+		return fmt.Errorf("advanced scoring isn't available with %s score format", scoreFormat)
+	}
+	options := al.User.MediaListOptions.TypeOptions(al.MediaType)
+	if !options.AdvancedScoringEnabled || len(options.AdvancedScoring) == 0 {
+		return fmt.Errorf("advanced scoring is disabled in your AniList settings; " +
+			"if you have just enabled it, refresh with `mal -r`")
+	}
+
+	scores, err := parseAdvancedScores(args, options.AdvancedScoring, entry.AdvancedScores, scoreFormat)
+	if err != nil {
+		return err
+	}
+	entry.AdvancedScores = scores
+	entry.Score = averageAdvancedScore(scores, scoreFormat)
+	return alSaveAndPrintEntry(al, entry)
+}
+
+// Formats scored categories, e.g. "Story 8, Visuals 9"
+func formatAdvancedScores(scores anilist.AdvancedScores, scoreFormat anilist.ScoreFormat) string {
+	formatted := make([]string, 0, len(scores))
+	for _, score := range scores {
+		if score.Score > 0 {
+			formatted = append(formatted, score.Name+" "+formatScore(score.Score, scoreFormat))
+		}
+	}
+	return strings.Join(formatted, ", ")
+}
@@ -25,6 +25,10 @@ func AniListApp(app *cli.App) *cli.App {
 			Name:  "r, refresh",
 			Usage: "refreshes cached list",
 		},
+		cli.BoolFlag{
+			Name:  "u, update",
+			Usage: "refreshes cached list, fetching only entries changed since the last refresh",
+		},
 		cli.IntFlag{
 			Name:  "max",
 			Usage: "visible entries threshold",
@@ -38,6 +42,29 @@ func AniListApp(app *cli.App) *cli.App {
 			Usage: "display entries only with given status " +
 				"[watching|planning|completed|repeating|paused|dropped]",
 		},
+		cli.BoolFlag{
+			Name:  "manga",
+			Usage: "use your manga list instead of the configured one",
+		},
+		cli.StringFlag{
+			Name:  "list",
+			Usage: "display entries of given custom list",
+		},
+		cli.StringFlag{
+			Name: "where",
+			Usage: "display only entries matching the expression, " +
+				"e.g. 'score>=8 and format=TV and progress<episodes'",
+		},
+		cli.StringFlag{
+			Name: "sort",
+			Usage: "comma separated sort keys, e.g. 'score,title'; " +
+				"prefix a key with - or + for descending or ascending order",
+		},
+		cli.BoolFlag{
+			Name:  "behind",
+			Usage: "mark entries with aired episodes you haven't watched yet",
+		},
+		profileFlag,
 	}
 
 	app.Commands = []cli.Command{
@@ -47,6 +74,23 @@ func AniListApp(app *cli.App) *cli.App {
 			UsageText: "mal mal",
 			Action:    switchToMal,
 		},
+		cli.Command{
+			Name:      "login",
+			Category:  "Config",
+			Usage:     "Log in to AniList (again)",
+			UsageText: "mal login [--headless]",
+			Action:    alLogin,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "headless",
+					Usage: "don't open the browser, paste the redirect url instead",
+				},
+				cli.BoolFlag{
+					Name:  "browser",
+					Usage: "open the browser even if no display was detected",
+				},
+			},
+		},
 		cli.Command{
 			Name:     "eps",
 			Aliases:  []string{"episodes", "e"},
@@ -56,6 +100,24 @@ func AniListApp(app *cli.App) *cli.App {
 			UsageText: "mal eps <n>",
 			Action:    alSetEntryEpisodes,
 		},
+		cli.Command{
+			Name:     "chapters",
+			Aliases:  []string{"chs", "ch"},
+			Category: "Update",
+			Usage: "Set the read chapters value. " +
+				"If n not specified, the number will be increased by one",
+			UsageText: "mal --manga chapters <n>",
+			Action:    alSetEntryEpisodes,
+		},
+		cli.Command{
+			Name:     "volumes",
+			Aliases:  []string{"vols", "vol"},
+			Category: "Update",
+			Usage: "Set the read volumes value. " +
+				"If n not specified, the number will be increased by one",
+			UsageText: "mal --manga volumes <n>",
+			Action:    alSetEntryVolumes,
+		},
 		cli.Command{
 			Name:      "status",
 			Category:  "Update",
@@ -74,8 +136,58 @@ func AniListApp(app *cli.App) *cli.App {
 			Name:      "score",
 			Category:  "Update",
 			Usage:     "Set your rating for selected entry",
-			UsageText: "mal score <0-10>",
+			UsageText: "mal score <0-10> | mal score --advanced <category=score>...",
 			Action:    alSetEntryScore,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name: "advanced",
+					Usage: "set scores of advanced scoring categories, e.g. story=8 visuals=9; " +
+						"the overall score is their average",
+				},
+			},
+		},
+		cli.Command{
+			Name:      "started",
+			Category:  "Update",
+			Usage:     "Set the date you started the selected entry; today if not specified",
+			UsageText: "mal started [yyyy-mm-dd|yyyy-mm|yyyy|today|none]",
+			Action:    alSetEntryStartDate,
+		},
+		cli.Command{
+			Name:      "finished",
+			Category:  "Update",
+			Usage:     "Set the date you completed the selected entry; today if not specified",
+			UsageText: "mal finished [yyyy-mm-dd|yyyy-mm|yyyy|today|none]",
+			Action:    alSetEntryFinishDate,
+		},
+		cli.Command{
+			Name:      "notes",
+			Category:  "Update",
+			Usage:     "Set notes of the selected entry, or show them if no text is given",
+			UsageText: "mal notes [--clear] [text]",
+			Action:    alSetEntryNotes,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "clear",
+					Usage: "remove the notes",
+				},
+			},
+		},
+		cli.Command{
+			Name:     "repeat",
+			Aliases:  []string{"rewatched", "reread"},
+			Category: "Update",
+			Usage: "Set how many times you rewatched/reread the selected entry. " +
+				"If n not specified, the number will be increased by one",
+			UsageText: "mal repeat <n>",
+			Action:    alSetEntryRepeat,
+		},
+		cli.Command{
+			Name:      "private",
+			Category:  "Update",
+			Usage:     "Hide the selected entry from other users, or make it public again with off",
+			UsageText: "mal private [on|off]",
+			Action:    alSetEntryPrivate,
 		},
 		cli.Command{
 			Name:      "delete",
@@ -85,6 +197,140 @@ func AniListApp(app *cli.App) *cli.App {
 			UsageText: "mal del",
 			Action:    alDeleteEntry,
 		},
+		cli.Command{
+			Name:      "custom-list",
+			Aliases:   []string{"cl"},
+			Category:  "Update",
+			Usage:     "Show your custom lists or add/remove the selected entry to/from them",
+			UsageText: "mal custom-list [add|remove <list name>]",
+			Action:    alCustomLists,
+			Subcommands: []cli.Command{
+				cli.Command{
+					Name:      "add",
+					Usage:     "Add selected entry to a custom list",
+					UsageText: "mal custom-list add <list name>",
+					Action:    alCustomListAdd,
+				},
+				cli.Command{
+					Name:      "remove",
+					Aliases:   []string{"rm"},
+					Usage:     "Remove selected entry from a custom list",
+					UsageText: "mal custom-list remove <list name>",
+					Action:    alCustomListRemove,
+				},
+			},
+		},
+		cli.Command{
+			Name:      "sync",
+			Category:  "Update",
+			Usage:     "Push changes made while AniList was unreachable",
+			UsageText: "mal sync",
+			Action:    alSync,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "force",
+					Usage: "overwrite entries modified on AniList since the change was made",
+				},
+				cli.BoolFlag{
+					Name:  "discard",
+					Usage: "drop all pending changes",
+				},
+			},
+		},
+		cli.Command{
+			Name:     "batch",
+			Category: "Update",
+			Usage: "Apply an operation to all entries matching the selectors. " +
+				"Scores are compared in your score format",
+			UsageText: "mal batch [selectors...] [--set-status <status>] [--set-score <score>] " +
+				"[--inc-progress <n>] [--delete] [--yes]",
+			Action: alBatch,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "status",
+					Usage: "select entries with given status",
+				},
+				cli.StringFlag{
+					Name:  "title",
+					Usage: "select entries with any title matching given regex (case insensitive)",
+				},
+				cli.StringFlag{
+					Name:  "format",
+					Usage: "select entries with one of given formats, e.g. TV,OVA,MOVIE",
+				},
+				cli.StringFlag{
+					Name:  "season",
+					Usage: "select entries from given season, e.g. \"fall 2020\", fall or 2020",
+				},
+				cli.StringFlag{
+					Name:  "score",
+					Usage: "select entries with score in given range, e.g. 7, 7-9, 7- or 0-5",
+				},
+				cli.StringFlag{
+					Name:  "ids",
+					Usage: "select entries with given comma separated media ids",
+				},
+				cli.StringFlag{
+					Name:  "set-status",
+					Usage: "change status [watching|planning|completed|dropped|paused|repeating]",
+				},
+				cli.StringFlag{
+					Name:  "set-score",
+					Usage: "change score",
+				},
+				cli.IntFlag{
+					Name:  "inc-progress",
+					Usage: "increase progress by n (decrease if negative)",
+				},
+				cli.BoolFlag{
+					Name:  "delete",
+					Usage: "delete entries from the list",
+				},
+				cli.BoolFlag{
+					Name:  "yes, y",
+					Usage: "don't ask for confirmation",
+				},
+			},
+		},
+		cli.Command{
+			Name:     "import",
+			Category: "Update",
+			Usage: "Apply entries from a MyAnimeList export (or a file created with mal export) " +
+				"to your list",
+			UsageText: "mal import [--format mal-xml|csv|json] [--dry-run] <file>",
+			Action:    alImport,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "format",
+					Usage: "file format [mal-xml|csv|json]; guessed from the file extension if not given",
+				},
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "only show what would change",
+				},
+			},
+		},
+		cli.Command{
+			Name:      "export",
+			Category:  "Action",
+			Usage:     "Export your list to a file (or stdout)",
+			UsageText: "mal export [--format mal-xml|csv|json] [--where <expression>] [--sort <keys>] [file]",
+			Action:    alExport,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "format",
+					Usage: "file format [mal-xml|csv|json]; guessed from the file extension if not given",
+				},
+				cli.StringFlag{
+					Name:  "where",
+					Usage: "export only entries matching the expression, e.g. 'status=completed'",
+				},
+				cli.StringFlag{
+					Name:  "sort",
+					Usage: "comma separated sort keys, e.g. 'score,title'",
+				},
+			},
+		},
 		cli.Command{
 			Name:      "sel",
 			Aliases:   []string{"select", "s"},
@@ -99,6 +345,13 @@ func AniListApp(app *cli.App) *cli.App {
 				},
 			},
 		},
+		cli.Command{
+			Name:      "tui",
+			Category:  "Action",
+			Usage:     "Open interactive list manager",
+			UsageText: "mal tui",
+			Action:    alListCui,
+		},
 		cli.Command{
 			Name:      "selected",
 			Aliases:   []string{"curr"},
@@ -169,12 +422,114 @@ func AniListApp(app *cli.App) *cli.App {
 			Action:          alSearch,
 			SkipFlagParsing: true,
 		},
+		cli.Command{
+			Name:      "season",
+			Aliases:   []string{"chart"},
+			Category:  "Action",
+			Usage:     "Browse anime of a season and add them to your list; current season by default",
+			UsageText: "mal season [winter|spring|summer|fall] [year]",
+			Action:    alSeason,
+		},
 		cli.Command{
 			Name:      "stats",
 			Category:  "Action",
 			Usage:     "Show your account statistics",
-			UsageText: "mal stats",
+			UsageText: "mal stats [--where <expression>] [--json] [--refresh-metadata]",
 			Action:    alStats,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "where",
+					Usage: "count only entries matching the expression, e.g. 'format=TV'",
+				},
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "print the statistics as json",
+				},
+				cli.BoolFlag{
+					Name:  "refresh-metadata",
+					Usage: "fetch genres, tags and community scores again instead of using cached ones",
+				},
+			},
+		},
+		cli.Command{
+			Name:      "user",
+			Category:  "Action",
+			Usage:     "Show the public list of another user",
+			UsageText: "mal user [--status status] [--where expression] [--sort keys] <name>",
+			Action:    alUser,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "status",
+					Usage: "display entries only with given status",
+				},
+				cli.StringFlag{
+					Name:  "where",
+					Usage: "display only entries matching the expression",
+				},
+				cli.StringFlag{
+					Name:  "sort",
+					Usage: "comma separated sort keys, e.g. 'score,title'",
+				},
+				cli.IntFlag{
+					Name:  "max",
+					Usage: "visible entries threshold",
+				},
+				cli.BoolFlag{
+					Name:  "all, a",
+					Usage: "display all entries",
+				},
+			},
+		},
+		cli.Command{
+			Name:      "compare",
+			Category:  "Action",
+			Usage:     "Compare your list with the list of another user",
+			UsageText: "mal compare [--max n] <name>",
+			Action:    alCompare,
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "max",
+					Usage: "number of score differences and suggestions to show",
+					Value: defaultCompareLength,
+				},
+			},
+		},
+		cli.Command{
+			Name:      "together",
+			Aliases:   []string{"group-watch"},
+			Category:  "Action",
+			Usage:     "Find anime you and other users plan to watch that nobody has seen yet",
+			UsageText: "mal together [--min n] [--max n] <name> [names...]",
+			Action:    alTogether,
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "min",
+					Usage: "minimal number of users planning an anime",
+					Value: 2,
+				},
+				cli.IntFlag{
+					Name:  "max",
+					Usage: "number of anime to show",
+					Value: defaultTogetherLength,
+				},
+			},
+		},
+		cli.Command{
+			Name:      "review",
+			Category:  "Action",
+			Usage:     "Save a summary of what you completed in a year as an html page; current year by default",
+			UsageText: "mal review [--output file] [--covers] [year]",
+			Action:    alReview,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "output, o",
+					Usage: "file to save the page to; review-<year>.html by default",
+				},
+				cli.BoolFlag{
+					Name:  "covers",
+					Usage: "download covers missing from the local cache to show them on the page",
+				},
+			},
 		},
 		cli.Command{
 			Name:      "airing",
@@ -184,6 +539,55 @@ func AniListApp(app *cli.App) *cli.App {
 			UsageText: "mal airing [episode]",
 			Action:    alAiringTime,
 		},
+		cli.Command{
+			Name:      "behind",
+			Category:  "Action",
+			Usage:     "Show how many aired episodes of anime you watch or paused you haven't seen yet",
+			UsageText: "mal behind",
+			Action:    alBehind,
+		},
+		cli.Command{
+			Name:      "calendar",
+			Aliases:   []string{"cal"},
+			Category:  "Action",
+			Usage:     "Show when episodes of anime you watch or plan to watch air in the coming days",
+			UsageText: "mal calendar [--days n] [--tz zone] [--json]",
+			Action:    alCalendar,
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "days",
+					Usage: "number of days to show, starting today",
+					Value: 7,
+				},
+				cli.StringFlag{
+					Name:  "tz",
+					Usage: "time zone to show the times in, e.g. Asia/Tokyo; local by default",
+				},
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "print the episodes as json",
+				},
+			},
+			Subcommands: []cli.Command{
+				cli.Command{
+					Name:      "export",
+					Usage:     "Save the airing schedule as an iCalendar file to import into calendar apps",
+					UsageText: "mal calendar export --ics <file> [--days n]",
+					Action:    alCalendarExport,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "ics",
+							Usage: "file to write the calendar to, - for stdout",
+						},
+						cli.IntFlag{
+							Name:  "days",
+							Usage: "number of days to export, starting today",
+							Value: 7,
+						},
+					},
+				},
+			},
+		},
 		cli.Command{
 			Name:      "music",
 			Category:  "Action",
@@ -227,6 +631,72 @@ func AniListApp(app *cli.App) *cli.App {
 				},
 			},
 		},
+		cli.Command{
+			Name:      "inbox",
+			Category:  "Action",
+			Usage:     "Browse all your notifications",
+			UsageText: "mal inbox [--type airing,follows,activity,likes,forum,media] [--page n] [--mark-read] [--select n] [-i]",
+			Action:    alInbox,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "type",
+					Usage: "comma separated notification types [airing|follows|activity|likes|forum|media]",
+				},
+				cli.IntFlag{
+					Name:  "page",
+					Usage: "page of notifications to display",
+					Value: 1,
+				},
+				cli.IntFlag{
+					Name:  "per-page",
+					Usage: "amount of notifications on a page",
+					Value: defaultInboxPageSize,
+				},
+				cli.BoolFlag{
+					Name:  "mark-read",
+					Usage: "mark all notifications as read",
+				},
+				cli.IntFlag{
+					Name:  "select",
+					Usage: "select the list entry of the n-th notification on the page",
+				},
+				cli.BoolFlag{
+					Name:  "interactive, i",
+					Usage: "open interactive inbox",
+				},
+			},
+		},
+		cli.Command{
+			Name:      "watch-airing",
+			Category:  "Action",
+			Usage:     "Keep checking for airing notifications and run actions for new ones",
+			UsageText: "mal watch-airing [--interval 5m] [--notify] [--exec command] [--webhook url] [--once]",
+			Action:    alWatchAiring,
+			Flags: []cli.Flag{
+				cli.DurationFlag{
+					Name:  "interval",
+					Usage: "time between checks, at least 1m",
+					Value: 5 * time.Minute,
+				},
+				cli.BoolFlag{
+					Name:  "notify",
+					Usage: "show a desktop notification with notify-send",
+				},
+				cli.StringSliceFlag{
+					Name: "exec",
+					Usage: "run a command, arguments can use {{.Title}}, {{.Episode}}, {{.AnimeId}}, " +
+						"{{.Url}}, {{.Message}} and {{.AiredAt}}",
+				},
+				cli.StringSliceFlag{
+					Name:  "webhook",
+					Usage: "POST the notification as json to the url",
+				},
+				cli.BoolFlag{
+					Name:  "once",
+					Usage: "check once and exit, e.g. when run from cron",
+				},
+			},
+		},
 		cli.Command{
 			Name:     "cfg",
 			Aliases:  []string{"config", "configuration"},
@@ -253,6 +723,32 @@ func AniListApp(app *cli.App) *cli.App {
 					UsageText: "mal cfg status [watching|planning|completed|dropped|paused|repeating]",
 					Action:    configChangeAlStatus,
 				},
+				cli.Command{
+					Name:      "media-type",
+					Aliases:   []string{"type"},
+					Usage:     "Type of the list you work with",
+					UsageText: "mal cfg media-type [anime|manga]",
+					Action:    configChangeAlMediaType,
+				},
+				cli.Command{
+					Name:      "auto-refresh",
+					Usage:     "Refresh cached list incrementally when it's older than given interval",
+					UsageText: "mal cfg auto-refresh [interval (e.g. 30m, 6h)|off]",
+					Action:    configChangeAutoRefresh,
+				},
+				cli.Command{
+					Name: "oauth-client",
+					Usage: "Use your own AniList API client (authorization code grant). " +
+						"Its redirect url must be http://localhost:42505/oauth2",
+					UsageText: "mal cfg oauth-client <client id> [client secret]",
+					Action:    configChangeOAuthClient,
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "clear",
+							Usage: "Go back to the default client",
+						},
+					},
+				},
 				cli.Command{
 					Name:      "status-auto-update",
 					Usage:     "Allows entry to be automatically set to completed when number of all episodes is reached or exceeded",
@@ -293,8 +789,10 @@ func AniListApp(app *cli.App) *cli.App {
 				},
 			},
 		},
+		profileCommand,
 	}
 
+	app.Before = selectProfile
 	app.Action = cli.ActionFunc(aniListDefaultAction)
 
 	return app
@@ -306,53 +804,110 @@ func aniListDefaultAction(ctx *cli.Context) error {
 		return err
 	}
 	cfg := LoadConfig()
+	list, err := alDisplayedList(ctx, cfg, al.List)
+	if err != nil {
+		return err
+	}
+	list = list[:alVisibleEntries(ctx, cfg, len(list))]
+
+	behind := make(map[int]int)
+	if ctx.Bool("behind") {
+		if al.MediaType == anilist.Manga {
+			return fmt.Errorf("--behind works only with anime")
+		}
+		entries, err := alQueryBehindEntries(al, list, true)
+		if err != nil {
+			return err
+		}
+		for _, b := range entries {
+			behind[b.Entry.Id] = b.Behind
+		}
+	}
+
+	alPrintListTable(list, cfg, al.MediaType, al.User.MediaListOptions.ScoreFormat,
+		cfg.ALSelected(al.MediaType), behind)
+	return nil
+}
+
+// Entries chosen with --status, --list, --where and --sort flags, or the configured status and sorting
+func alDisplayedList(ctx *cli.Context, cfg *Config, list List) (List, error) {
 	status := cfg.ALStatus
-	if statusFlag := ctx.String("status"); statusFlag != "" {
+	if statusFlag := queryFlag(ctx, "status"); statusFlag != "" {
 		status = anilist.ParseStatus(statusFlag)
+	} else if queryFlag(ctx, "where") != "" || queryFlag(ctx, "list") != "" {
+		// The expression or the custom list decides which entries are shown
+		status = anilist.All
 	}
-	list := alGetList(al, status)
-
-	sort.Slice(list, func(i, j int) bool {
-		return list[i].UpdatedAt > list[j].UpdatedAt
-	})
+	displayed := filterListByStatus(list, status)
+	if name := queryFlag(ctx, "list"); name != "" {
+		name, err := alFindCustomList(list, name)
+		if err != nil {
+			return nil, err
+		}
+		displayed = alGetCustomList(displayed, name)
+	}
+	return alQueryList(ctx, displayed, configSortKeys(cfg.Sorting))
+}
 
-	var visibleEntries int
-	if visibleEntries = ctx.Int("max"); visibleEntries == 0 {
+// Number of entries to show according to --max and --all flags or the config
+func alVisibleEntries(ctx *cli.Context, cfg *Config, listLength int) int {
+	visibleEntries := ctx.Int("max")
+	if visibleEntries == 0 {
+		visibleEntries = ctx.GlobalInt("max")
+	}
+	if visibleEntries == 0 {
 		// `Max` flag not specified, get value from config
 		visibleEntries = cfg.MaxVisibleEntries
 	}
-	if visibleEntries > len(list) || visibleEntries < 0 || ctx.Bool("all") {
-		visibleEntries = len(list)
+	if visibleEntries > listLength || visibleEntries < 0 || ctx.Bool("all") || ctx.GlobalBool("all") {
+		visibleEntries = listLength
 	}
+	return visibleEntries
+}
 
-	numberFieldWidth := int(math.Max(math.Ceil(math.Log10(float64(visibleEntries+1))), 2))
+// Prints the list as a table, the first entry at the bottom. Behind maps media ids
+// to the number of aired episodes not watched yet
+func alPrintListTable(
+	list List,
+	cfg *Config,
+	mediaType anilist.MediaType,
+	scoreFormat anilist.ScoreFormat,
+	selectedID int,
+	behind map[int]int,
+) {
+	progressHeader := "Eps"
+	if mediaType == anilist.Manga {
+		progressHeader = "Chs"
+	}
+
+	numberFieldWidth := int(math.Max(math.Ceil(math.Log10(float64(len(list)+1))), 2))
 	titleWidth := cfg.ListWidth - numberFieldWidth - 8 - 6
 	fmt.Printf("%*s%*.*s%8s%6s\n",
-		numberFieldWidth, "No", titleWidth, titleWidth, "Title", "Eps", "Score")
+		numberFieldWidth, "No", titleWidth, titleWidth, "Title", progressHeader, "Score")
 	fmt.Println(strings.Repeat("=", cfg.ListWidth))
 	var pattern string
-	if al.User.MediaListOptions.ScoreFormat == anilist.Point10Decimal {
-		pattern = "%*d%*.*s%8s%6.1f\n"
+	if scoreFormat == anilist.Point10Decimal {
+		pattern = "%*d%*.*s%8s%6.1f"
 	} else {
-		pattern = "%*d%*.*s%8s%6.f\n"
+		pattern = "%*d%*.*s%8s%6.f"
 	}
+	selected := color.New(color.FgHiYellow)
+	red := color.New(color.FgHiRed).SprintFunc()
 	var entry *anilist.MediaListEntry
-	for i := visibleEntries - 1; i >= 0; i-- {
+	for i := len(list) - 1; i >= 0; i-- {
 		entry = &list[i]
-		if entry.Id == cfg.ALSelectedID {
-			color.HiYellow(pattern, numberFieldWidth, i+1, titleWidth, titleWidth,
-				entry.Title.UserPreferred,
-				fmt.Sprintf("%d/%d", entry.Progress, entry.Episodes),
-				entry.Score)
-		} else {
-			fmt.Printf(pattern, numberFieldWidth, i+1, titleWidth, titleWidth,
-				entry.Title.UserPreferred,
-				fmt.Sprintf("%d/%d", entry.Progress, entry.Episodes),
-				entry.Score)
+		line := fmt.Sprintf(pattern, numberFieldWidth, i+1, titleWidth, titleWidth,
+			entry.Title.UserPreferred,
+			fmt.Sprintf("%d/%d", entry.Progress, entry.Length()),
+			entry.Score)
+		if entry.Id == selectedID {
+			line = selected.Sprint(line)
 		}
+		if n := behind[entry.Id]; n > 0 {
+			line += " " + red(fmt.Sprintf("+%d", n))
+		}
+		fmt.Fprintln(color.Output, line)
 	}
-
-	return nil
 }
 
 func switchToMal(ctx *cli.Context) error {
@@ -390,32 +945,75 @@ func alSetEntryEpisodes(ctx *cli.Context) error {
 
 	alStatusAutoUpdate(cfg, entry)
 
-	if err = anilist.SaveMediaListEntryWaitAnimation(entry, al.Token); err != nil {
+	queued, err := alSaveEntry(al, entry)
+	if err != nil {
 		return err
 	}
-	if err = saveAniListAnimeLists(al); err != nil {
+	if err = saveAniListLists(al); err != nil {
 		return err
 	}
 
-	fmt.Println("Updated successfully")
+	if !queued {
+		fmt.Println("Updated successfully")
+	}
 	alPrintEntryDetailsAfterUpdatedEpisodes(entry, epsBefore, al.User.MediaListOptions.ScoreFormat)
 	return nil
 }
 
+func alSetEntryVolumes(ctx *cli.Context) error {
+	al, entry, _, err := loadAniListFull(ctx)
+	if err != nil {
+		return err
+	}
+	if al.MediaType != anilist.Manga {
+		return fmt.Errorf("volumes are available only for manga (use --manga flag)")
+	}
+
+	if arg := ctx.Args().First(); arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("n must be a non-negative integer")
+		}
+		if n < 0 {
+			return fmt.Errorf("n can't be lower than 0")
+		}
+		entry.ProgressVolumes = n
+	} else {
+		entry.ProgressVolumes++
+	}
+
+	queued, err := alSaveEntry(al, entry)
+	if err != nil {
+		return err
+	}
+	if err = saveAniListLists(al); err != nil {
+		return err
+	}
+
+	if !queued {
+		fmt.Println("Updated successfully")
+	}
+	alPrintEntryDetails(entry, al.User.MediaListOptions.ScoreFormat)
+	return nil
+}
+
 func alStatusAutoUpdate(cfg *Config, entry *anilist.MediaListEntry) {
-	if cfg.StatusAutoUpdateMode == Off || entry.Episodes == 0 {
+	length := entry.Length()
+	if cfg.StatusAutoUpdateMode == Off || length == 0 {
 		return
 	}
 
-	if (cfg.StatusAutoUpdateMode == Normal && entry.Progress >= entry.Episodes) ||
-		(cfg.StatusAutoUpdateMode == AfterThreshold && entry.Progress > entry.Episodes) {
+	if (cfg.StatusAutoUpdateMode == Normal && entry.Progress >= length) ||
+		(cfg.StatusAutoUpdateMode == AfterThreshold && entry.Progress > length) {
 		entry.Status = anilist.Completed
-		entry.Progress = entry.Episodes
+		entry.Progress = length
+		alAutoFillDates(entry)
 		return
 	}
 
-	if entry.Status == anilist.Completed && entry.Progress < entry.Episodes {
+	if entry.Status == anilist.Completed && entry.Progress < length {
 		entry.Status = anilist.Current
+		alAutoFillDates(entry)
 		return
 	}
 }
@@ -433,15 +1031,19 @@ func alSetEntryStatus(ctx *cli.Context) error {
 	}
 
 	entry.Status = status
+	alAutoFillDates(entry)
 
-	if err = anilist.SaveMediaListEntryWaitAnimation(entry, al.Token); err != nil {
+	queued, err := alSaveEntry(al, entry)
+	if err != nil {
 		return err
 	}
-	if err = saveAniListAnimeLists(al); err != nil {
+	if err = saveAniListLists(al); err != nil {
 		return err
 	}
 
-	fmt.Println("Updated successfully")
+	if !queued {
+		fmt.Println("Updated successfully")
+	}
 	alPrintEntryDetails(entry, al.User.MediaListOptions.ScoreFormat)
 	return nil
 }
@@ -455,6 +1057,9 @@ func alSetEntryScore(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	if ctx.Bool("advanced") {
+		return alSetEntryAdvancedScores(al, entry, ctx.Args())
+	}
 
 	score, err := parseScore(ctx.Args().First(), al.User.MediaListOptions.ScoreFormat)
 	if err != nil {
@@ -463,14 +1068,17 @@ func alSetEntryScore(ctx *cli.Context) error {
 
 	entry.Score = score
 
-	if err = anilist.SaveMediaListEntryWaitAnimation(entry, al.Token); err != nil {
+	queued, err := alSaveEntry(al, entry)
+	if err != nil {
 		return err
 	}
-	if err = saveAniListAnimeLists(al); err != nil {
+	if err = saveAniListLists(al); err != nil {
 		return err
 	}
 
-	fmt.Println("Updated successfully")
+	if !queued {
+		fmt.Println("Updated successfully")
+	}
 	alPrintEntryDetails(entry, al.User.MediaListOptions.ScoreFormat)
 	return nil
 }
@@ -520,15 +1128,18 @@ func alDeleteEntry(ctx *cli.Context) error {
 		return err
 	}
 
-	if err := anilist.DeleteMediaListEntry(entry, al.Token); err != nil {
+	queued, err := alRemoveEntry(al, entry)
+	if err != nil {
 		return err
 	}
 
-	fmt.Println("Entry deleted successfully")
+	if !queued {
+		fmt.Println("Entry deleted successfully")
+	}
 	alPrintEntryDetails(entry, al.User.MediaListOptions.ScoreFormat)
 
 	al.List = al.List.DeleteById(entry.ListId)
-	return saveAniListAnimeLists(al)
+	return saveAniListLists(al)
 }
 
 func alSelectEntry(ctx *cli.Context) error {
@@ -584,7 +1195,7 @@ func alSelectRandomEntry(ctx *cli.Context) error {
 }
 
 func alSaveSelection(cfg *Config, entry *anilist.MediaListEntry, scoreFormat anilist.ScoreFormat) {
-	cfg.ALSelectedID = entry.Id
+	cfg.SetALSelected(anilist.MediaType(entry.Type), entry.Id)
 	cfg.Save()
 
 	fmt.Println("Selected entry:")
@@ -607,7 +1218,7 @@ func alNyaaWebsite(ctx *cli.Context) error {
 	}
 	cfg := LoadConfig()
 
-	entry := al.GetMediaListById(cfg.ALSelectedID)
+	entry := al.GetMediaListById(cfg.ALSelected(al.MediaType))
 	if entry == nil {
 		return fmt.Errorf("no entry selected")
 	}
@@ -642,7 +1253,7 @@ func alOpenWebsite(ctx *cli.Context) error {
 
 	cfg := LoadConfig()
 
-	entry := al.GetMediaListById(cfg.ALSelectedID)
+	entry := al.GetMediaListById(cfg.ALSelected(al.MediaType))
 	if entry == nil {
 		return fmt.Errorf("no entry selected")
 	}
@@ -712,20 +1323,48 @@ func alStats(ctx *cli.Context) error {
 		return err
 	}
 
+	var lists [6]List
+	all := make(List, 0)
+	for i, status := range []anilist.MediaListStatus{anilist.Current, anilist.Planning,
+		anilist.Completed, anilist.Repeating, anilist.Paused, anilist.Dropped} {
+		if lists[i], err = alQueryList(ctx, alGetList(al, status), nil); err != nil {
+			return err
+		}
+		all = append(all, lists[i]...)
+	}
+
+	asJson := ctx.Bool("json")
+	// The wait animation would end up in the json
+	metadata, err := loadMediaMetadata(al, all, ctx.Bool("refresh-metadata"), !asJson)
+	if isNetworkError(err) {
+		fmt.Fprintln(color.Error, "AniList is unreachable, genres, tags and community scores are left out")
+	} else if err != nil {
+		return err
+	}
+	report := buildStatsReport(all, al.MediaType, al.User.MediaListOptions.ScoreFormat, metadata)
+	if asJson {
+		return printStatsJson(report)
+	}
+
+	if al.MediaType == anilist.Manga {
+		err = alMangaStats(lists)
+	} else {
+		err = alAnimeStats(lists)
+	}
+	if err != nil {
+		return err
+	}
+	// Charts follow the totals per status
+	alPrintStatsReport(report)
+	return nil
+}
+
+func alAnimeStats(lists [6]List) error {
 	yellow := color.New(color.FgHiYellow).SprintFunc()
 	red := color.New(color.FgHiRed).SprintFunc()
 	cyan := color.New(color.FgHiCyan).SprintFunc()
 	magenta := color.New(color.FgHiMagenta).SprintFunc()
 
-	lists := [6]List{
-		alGetList(al, anilist.Current),
-		alGetList(al, anilist.Planning),
-		alGetList(al, anilist.Completed),
-		alGetList(al, anilist.Repeating),
-		alGetList(al, anilist.Paused),
-		alGetList(al, anilist.Dropped),
-	}
-
 	totalShows := 0
 	totalTimeSpentWatching := 0
 	totalEpisodesWatched := 0
@@ -773,11 +1412,57 @@ func alStats(ctx *cli.Context) error {
 	return nil
 }
 
+func alMangaStats(lists [6]List) error {
+	red := color.New(color.FgHiRed).SprintFunc()
+	cyan := color.New(color.FgHiCyan).SprintFunc()
+	magenta := color.New(color.FgHiMagenta).SprintFunc()
+
+	totalEntries := 0
+	totalChaptersRead := 0
+	totalVolumesRead := 0
+	for _, list := range lists {
+		if len(list) == 0 {
+			continue
+		}
+		totalEntries += len(list)
+		chaptersRead := 0
+		volumesRead := 0
+		for _, entry := range list {
+			chaptersRead += entry.Progress + entry.Chapters*entry.Repeat
+			volumesRead += entry.ProgressVolumes + entry.Volumes*entry.Repeat
+		}
+		totalChaptersRead += chaptersRead
+		totalVolumesRead += volumesRead
+
+		fmt.Fprintf(color.Output,
+			`%s:
+  entries: %s
+  chapters: %s
+  volumes: %s
+`,
+			alStatusString(list[0].Status, anilist.Manga),
+			red(len(list)),
+			magenta(chaptersRead),
+			cyan(volumesRead),
+		)
+	}
+
+	fmt.Println()
+	fmt.Fprintln(color.Output, "Total chapters read:", red(totalChaptersRead))
+	fmt.Fprintln(color.Output, "Total volumes read:", red(totalVolumesRead))
+	fmt.Fprintln(color.Output, "Total entries:", red(totalEntries))
+
+	return nil
+}
+
 func alAiringTime(ctx *cli.Context) error {
 	al, entry, cfg, err := loadAniListFull(ctx)
 	if err != nil {
 		return err
 	}
+	if al.MediaType == anilist.Manga {
+		return fmt.Errorf("airing schedule is available only for anime")
+	}
 
 	var episode int
 	if argsLen := ctx.NArg(); argsLen == 1 {
@@ -796,7 +1481,7 @@ func alAiringTime(ctx *cli.Context) error {
 		}
 	}
 
-	schedule, err := anilist.QueryAiringScheduleWaitAnimation(entry.Id, episode, al.Token)
+	schedule, err := al.Client().QueryAiringScheduleWaitAnimation(entry.Id, episode)
 	if err != nil {
 		return err
 	}
@@ -832,10 +1517,13 @@ func alAiringTime(ctx *cli.Context) error {
 }
 
 func alPrintMusic(ctx *cli.Context) error {
-	_, entry, _, err := loadAniListFull(ctx)
+	al, entry, _, err := loadAniListFull(ctx)
 	if err != nil {
 		return err
 	}
+	if al.MediaType == anilist.Manga {
+		return fmt.Errorf("themes are available only for anime")
+	}
 
 	details, err := mal.FetchDetailsWithAnimation(&mal.Client{}, &mal.Anime{ID: entry.IdMal})
 	if err != nil {
@@ -928,8 +1616,8 @@ func alAiringNotifications(ctx *cli.Context) error {
 		return err
 	}
 
-	notifications, err := anilist.QueryAiringNotificationsWaitAnimation(
-		1, int(ctx.Uint("max")), false, al.Token)
+	notifications, err := al.Client().QueryAiringNotificationsWaitAnimation(
+		1, int(ctx.Uint("max")), false)
 	if err != nil {
 		return err
 	}
@@ -937,14 +1625,18 @@ func alAiringNotifications(ctx *cli.Context) error {
 		return notifications[i].CreatedAt < notifications[j].CreatedAt
 	})
 
-	cyan := color.New(color.FgHiCyan).SprintFunc()
-	red := color.New(color.FgHiRed).SprintFunc()
-	yellow := color.New(color.FgHiYellow).SprintFunc()
 	for _, n := range notifications {
-		t := time.Unix(int64(n.CreatedAt), 0).Format("02-01-2006 15:04")
-		fmt.Fprintf(color.Output, "[%s] Episode %s of %s aired\n",
-			cyan(t), red(n.Episode), yellow(n.Title.UserPreferred))
+		alPrintAiringNotification(n)
 	}
 
 	return nil
 }
+
+func alPrintAiringNotification(n anilist.AiringNotification) {
+	cyan := color.New(color.FgHiCyan).SprintFunc()
+	red := color.New(color.FgHiRed).SprintFunc()
+	yellow := color.New(color.FgHiYellow).SprintFunc()
+	t := time.Unix(int64(n.CreatedAt), 0).Format("02-01-2006 15:04")
+	fmt.Fprintf(color.Output, "[%s] Episode %s of %s aired\n",
+		cyan(t), red(n.Episode), yellow(n.Title.UserPreferred))
+}
@@ -1,11 +1,1191 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/aqatl/mal/anilist"
+	"github.com/aqatl/mal/anilist/anilisttest"
+	"github.com/aqatl/mal/oauth2"
+	"github.com/fatih/color"
+	"github.com/urfave/cli"
 )
 
+// Points data files to a temporary directory and AniList requests to a server
+// replaying testdata/anilist_fixtures.json
+func setUpAniListTest(t *testing.T) *anilisttest.Server {
+	dir, err := ioutil.TempDir("", "mal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	// Profile switching rewrites the data file paths too, so restore all of them
+	paths := append(profileDataFiles(), &AppConfigFile, &ProfilesDir)
+	saved := make([]string, len(paths))
+	for i, p := range paths {
+		saved[i] = *p
+	}
+	t.Cleanup(func() {
+		for i, p := range paths {
+			*p = saved[i]
+		}
+	})
+
+	AppConfigFile = filepath.Join(dir, "appConfig.json")
+	ProfilesDir = filepath.Join(dir, "profiles")
+	MalConfigFile = filepath.Join(dir, "malConfig.json")
+	AniListCredsFile = filepath.Join(dir, "aniListCreds.json")
+	AniListUserFile = filepath.Join(dir, "aniListUser.json")
+	AniListCacheFile = filepath.Join(dir, "aniListCache.json")
+	AniListMangaCacheFile = filepath.Join(dir, "aniListMangaCache.json")
+	AniListPendingOpsFile = filepath.Join(dir, "aniListPendingOps.json")
+	AniListSeenNotificationsFile = filepath.Join(dir, "aniListSeenNotifications.json")
+	AniListMediaMetadataFile = filepath.Join(dir, "aniListMediaMetadata.json")
+	AniListCoversDir = filepath.Join(dir, "covers")
+
+	token := oauth2.OAuthToken{Token: "test-token", ExpireDate: time.Now().Add(time.Hour)}
+	if err := saveOAuthToken(token); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := anilisttest.NewServerFromFile(filepath.Join("testdata", "anilist_fixtures.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	endpoint := aniListEndpoint
+	aniListEndpoint = srv.URL
+	t.Cleanup(func() { aniListEndpoint = endpoint })
+
+	return srv
+}
+
+func runAniListApp(t *testing.T, args ...string) {
+	t.Helper()
+	if err := AniListApp(cli.NewApp()).Run(append([]string{"mal"}, args...)); err != nil {
+		t.Fatalf("mal %v: %v", args, err)
+	}
+}
+
+// Runs the app like runAniListApp and returns what it printed, without colors
+func aniListAppOutput(t *testing.T, args ...string) string {
+	t.Helper()
+	f, err := ioutil.TempFile(filepath.Dir(AniListCacheFile), "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	stdout, output, noColor := os.Stdout, color.Output, color.NoColor
+	os.Stdout, color.Output, color.NoColor = f, f, true
+	defer func() { os.Stdout, color.Output, color.NoColor = stdout, output, noColor }()
+	runAniListApp(t, args...)
+
+	data, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// Returns fields of the last output line containing substr, joined by single spaces
+func outputLine(out, substr string) string {
+	lines := strings.Split(out, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.Contains(lines[i], substr) {
+			return strings.Join(strings.Fields(lines[i]), " ")
+		}
+	}
+	return ""
+}
+
+func loadTestAniListCache(t *testing.T, mediaType anilist.MediaType) List {
+	t.Helper()
+	list := List{}
+	if !LoadJsonFile(alCacheFile(mediaType), &list) {
+		t.Fatal("cache file not found")
+	}
+	return list
+}
+
+func lastRequestFor(t *testing.T, srv *anilisttest.Server, field string) anilisttest.Request {
+	t.Helper()
+	requests := srv.RequestsFor(field)
+	if len(requests) == 0 {
+		t.Fatal("no request for", field)
+	}
+	return requests[len(requests)-1]
+}
+
+func TestAniListUpdateCommands(t *testing.T) {
+	srv := setUpAniListTest(t)
+
+	runAniListApp(t, "--all")
+	runAniListApp(t, "sel", "kaze")
+	if id := LoadConfig().ALSelectedID; id != 1 {
+		t.Fatal("Expected entry 1 to be selected, got", id)
+	}
+
+	runAniListApp(t, "eps")
+	vars := lastRequestFor(t, srv, "SaveMediaListEntry").Variables
+	if vars["progress"] != 5.0 || vars["listId"] != 101.0 {
+		t.Error("Unexpected eps request variables:", vars)
+	}
+	if entry := loadTestAniListCache(t, anilist.Anime).GetMediaListById(1); entry.Progress != 5 {
+		t.Error("Expected cached progress 5, got", entry.Progress)
+	}
+
+	runAniListApp(t, "score", "7")
+	if vars := lastRequestFor(t, srv, "SaveMediaListEntry").Variables; vars["score"] != 7.0 {
+		t.Error("Unexpected score request variables:", vars)
+	}
+
+	runAniListApp(t, "status", "paused")
+	if vars := lastRequestFor(t, srv, "SaveMediaListEntry").Variables; vars["status"] != "PAUSED" {
+		t.Error("Unexpected status request variables:", vars)
+	}
+
+	runAniListApp(t, "del")
+	if vars := lastRequestFor(t, srv, "DeleteMediaListEntry").Variables; vars["id"] != 101.0 {
+		t.Error("Unexpected delete request variables:", vars)
+	}
+	if entry := loadTestAniListCache(t, anilist.Anime).GetMediaListById(1); entry != nil {
+		t.Error("Expected entry to be removed from cache")
+	}
+
+	for _, h := range srv.Requests() {
+		if auth := h.Header.Get("Authorization"); auth != "Bearer test-token" {
+			t.Error("Unexpected Authorization header:", auth)
+		}
+	}
+}
+
+func TestAniListActionCommands(t *testing.T) {
+	srv := setUpAniListTest(t)
+
+	runAniListApp(t, "sel", "kaze")
+	runAniListApp(t, "curr")
+	runAniListApp(t, "stats")
+	runAniListApp(t, "airnot")
+
+	runAniListApp(t, "airing")
+	if vars := lastRequestFor(t, srv, "AiringSchedule").Variables; vars["episode"] != 5.0 {
+		t.Error("Unexpected airing request variables:", vars)
+	}
+}
+
+func TestAniListMangaCommands(t *testing.T) {
+	srv := setUpAniListTest(t)
+
+	runAniListApp(t, "--manga", "sel", "yama")
+	if id := LoadConfig().ALMangaSelectedID; id != 4 {
+		t.Fatal("Expected manga entry 4 to be selected, got", id)
+	}
+
+	runAniListApp(t, "--manga", "volumes", "4")
+	vars := lastRequestFor(t, srv, "SaveMediaListEntry").Variables
+	if vars["progressVolumes"] != 4.0 || vars["progress"] != 30.0 {
+		t.Error("Unexpected volumes request variables:", vars)
+	}
+	runAniListApp(t, "--manga", "stats")
+}
+
+func TestAniListOfflineSync(t *testing.T) {
+	srv := setUpAniListTest(t)
+	runAniListApp(t, "sel", "kaze")
+
+	aniListEndpoint = "http://127.0.0.1:1"
+	runAniListApp(t, "eps", "6")
+	if n := len(srv.RequestsFor("SaveMediaListEntry")); n != 0 {
+		t.Fatal("Expected no save requests while offline, got", n)
+	}
+	if ops := loadPendingOperations(); len(ops) != 1 || ops[0].BaseUpdatedAt != 1600000300 {
+		t.Fatal("Unexpected pending operations:", ops)
+	}
+
+	aniListEndpoint = srv.URL
+	runAniListApp(t, "-r")
+	entry := loadTestAniListCache(t, anilist.Anime).GetMediaListById(LoadConfig().ALSelectedID)
+	if entry == nil || entry.Progress != 6 {
+		t.Fatal("Expected the full refresh to keep the entry with a pending change, got", entry)
+	}
+
+	runAniListApp(t, "sync")
+	if vars := lastRequestFor(t, srv, "SaveMediaListEntry").Variables; vars["progress"] != 6.0 {
+		t.Error("Unexpected sync request variables:", vars)
+	}
+	if ops := loadPendingOperations(); len(ops) != 0 {
+		t.Error("Expected no pending operations after sync, got", len(ops))
+	}
+}
+
+func TestAniListIncrementalRefresh(t *testing.T) {
+	srv := setUpAniListTest(t)
+	runAniListApp(t, "-r")
+	if LoadConfig().LastUpdate.IsZero() {
+		t.Fatal("Expected last update time to be saved")
+	}
+
+	// 101 was updated, 104 added, 102 and 103 deleted on AniList; 103 has a pending change though
+	srv.Prepend(
+		anilisttest.Fixture{
+			Field: "Page",
+			Response: json.RawMessage(`{"data": {"Page": {"pageInfo": {"hasNextPage": true}, "mediaList": [
+				{"id": 104, "status": "CURRENT", "progress": 1, "updatedAt": 4000000001,
+					"media": {"id": 5, "title": {"userPreferred": "Ame no Hi"}, "type": "ANIME", "episodes": 13}},
+				{"id": 101, "status": "CURRENT", "progress": 6, "updatedAt": 4000000000,
+					"media": {"id": 1, "title": {"userPreferred": "Kaze no Uta"}, "type": "ANIME", "episodes": 12}},
+				{"id": 102, "status": "COMPLETED", "progress": 24, "updatedAt": 1600000200,
+					"media": {"id": 2, "title": {"userPreferred": "Hoshi no Umi"}, "type": "ANIME", "episodes": 24}}
+			]}}}`),
+		},
+		anilisttest.Fixture{
+			Field: "MediaListCollection",
+			Response: json.RawMessage(`{"data": {"MediaListCollection": {"lists": [
+				{"entries": [{"id": 101}, {"id": 104}]}
+			]}}}`),
+		},
+	)
+	list := loadTestAniListCache(t, anilist.Anime)
+	if err := savePendingOperations([]PendingOperation{{Kind: SaveOperation, Entry: list[2]}}); err != nil {
+		t.Fatal(err)
+	}
+
+	runAniListApp(t, "-u")
+
+	if n := len(srv.RequestsFor("Page")); n != 1 {
+		t.Error("Expected paging to stop at the first unchanged entry, got", n, "requests")
+	}
+	list = loadTestAniListCache(t, anilist.Anime)
+	ids := make([]int, 0, len(list))
+	for _, entry := range list {
+		ids = append(ids, entry.ListId)
+	}
+	if len(ids) != 3 || ids[0] != 101 || ids[1] != 103 || ids[2] != 104 {
+		t.Fatal("Unexpected entries after refresh:", ids)
+	}
+	if list[0].Progress != 6 {
+		t.Error("Expected updated progress 6, got", list[0].Progress)
+	}
+}
+
+func TestAniListExportImport(t *testing.T) {
+	srv := setUpAniListTest(t)
+	path := filepath.Join(filepath.Dir(AniListCacheFile), "export.xml")
+	runAniListApp(t, "export", path)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xml := string(data)
+	for _, s := range []string{
+		"<user_export_type>1</user_export_type>",
+		"<series_animedb_id>11</series_animedb_id>",
+		"<series_title><![CDATA[Kaze no Uta]]></series_title>",
+		"<my_status>Plan to Watch</my_status>",
+		"<my_score>8</my_score>",
+		"<my_start_date>2020-10-00</my_start_date>",
+	} {
+		if !strings.Contains(xml, s) {
+			t.Errorf("Export doesn't contain %s", s)
+		}
+	}
+
+	xml = strings.Replace(xml, "<my_watched_episodes>4</my_watched_episodes>",
+		"<my_watched_episodes>6</my_watched_episodes>", 1)
+	xml = strings.Replace(xml, "<my_start_date>2020-10-00</my_start_date>\n\t\t<my_finish_date>0000-00-00",
+		"<my_start_date>2020-10-00</my_start_date>\n\t\t<my_finish_date>2020-12-24", 1)
+	xml = strings.Replace(xml, "</myanimelist>", `<anime>
+		<series_animedb_id>15</series_animedb_id>
+		<series_title><![CDATA[Ame no Hi]]></series_title>
+		<my_watched_episodes>0</my_watched_episodes>
+		<my_score>0</my_score>
+		<my_status>Plan to Watch</my_status>
+	</anime></myanimelist>`, 1)
+	if err := ioutil.WriteFile(path, []byte(xml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	srv.Prepend(
+		anilisttest.Fixture{
+			Field:     "Page",
+			Variables: map[string]interface{}{"idMal": []int{15}},
+			Response: json.RawMessage(`{"data": {"Page": {"pageInfo": {"hasNextPage": false}, "media": [
+				{"id": 5, "idMal": 15, "title": {"userPreferred": "Ame no Hi"}, "type": "ANIME", "episodes": 13}
+			]}}}`),
+		},
+		anilisttest.Fixture{
+			Field:     "SaveMediaListEntry",
+			Variables: map[string]interface{}{"mediaId": 5},
+			Response:  json.RawMessage(`{"data": {"SaveMediaListEntry": {"id": 104, "updatedAt": 1700000000}}}`),
+		},
+	)
+
+	runAniListApp(t, "import", "--dry-run", path)
+	if n := len(srv.RequestsFor("SaveMediaListEntry")); n != 0 {
+		t.Fatal("Expected no save requests in dry run, got", n)
+	}
+
+	runAniListApp(t, "import", path)
+	saves := srv.RequestsFor("SaveMediaListEntry")
+	if len(saves) != 2 {
+		t.Fatal("Expected 2 save requests, got", len(saves))
+	}
+	if vars := saves[0].Variables; vars["mediaId"] != 1.0 || vars["listId"] != 101.0 || vars["progress"] != 6.0 {
+		t.Error("Unexpected update request variables:", vars)
+	}
+	// Unchanged start date survives the round trip, the finish date is imported
+	started := map[string]interface{}{"year": 2020.0, "month": 10.0, "day": nil}
+	completed := map[string]interface{}{"year": 2020.0, "month": 12.0, "day": 24.0}
+	if vars := saves[0].Variables; !reflect.DeepEqual(vars["startedAt"], started) ||
+		!reflect.DeepEqual(vars["completedAt"], completed) {
+		t.Error("Unexpected imported dates:", vars["startedAt"], vars["completedAt"])
+	}
+	if vars := saves[1].Variables; vars["mediaId"] != 5.0 || vars["listId"] != nil || vars["status"] != "PLANNING" {
+		t.Error("Unexpected add request variables:", vars)
+	}
+}
+
+func TestAniListImportConvertsScoreFormat(t *testing.T) {
+	srv := setUpAniListTest(t)
+	dir := filepath.Dir(AniListCacheFile)
+
+	csvPath := filepath.Join(dir, "export.csv")
+	runAniListApp(t, "export", csvPath)
+	data, err := ioutil.ReadFile(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), ",8,POINT_10,") {
+		t.Fatal("Export doesn't record the score format:", string(data))
+	}
+	// Same scores on the 100 point scale don't change anything
+	csv := strings.Replace(string(data), ",POINT_10,", ",POINT_100,", -1)
+	csv = strings.Replace(csv, ",8,POINT_100,", ",80,POINT_100,", 1)
+	if err := ioutil.WriteFile(csvPath, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runAniListApp(t, "import", csvPath)
+	if n := len(srv.RequestsFor("SaveMediaListEntry")); n != 0 {
+		t.Fatal("Expected no save requests, got", n)
+	}
+
+	for status, valid := range map[string]bool{"plantowatch": true, "CURRENT": true, "": false, "watchin": false} {
+		_, err := readCsv(strings.NewReader("id,status\n1,"+status+"\n"), anilist.Point10)
+		if valid != (err == nil) {
+			t.Errorf("Unexpected result of reading status %q: %v", status, err)
+		}
+	}
+
+	jsonPath := filepath.Join(dir, "export.json")
+	runAniListApp(t, "export", jsonPath)
+	doc := struct {
+		ScoreFormat anilist.ScoreFormat      `json:"scoreFormat"`
+		Entries     []map[string]interface{} `json:"entries"`
+	}{}
+	if !LoadJsonFile(jsonPath, &doc) {
+		t.Fatal("Can't read the json export")
+	}
+	if doc.ScoreFormat != anilist.Point10 {
+		t.Fatal("Unexpected exported score format:", doc.ScoreFormat)
+	}
+	doc.ScoreFormat = anilist.Point100
+	for _, entry := range doc.Entries {
+		entry["score"] = entry["score"].(float64) * 10
+		if entry["id"] == 101.0 {
+			entry["score"] = 55
+		}
+	}
+	if err := SaveJsonFile(jsonPath, doc); err != nil {
+		t.Fatal(err)
+	}
+	runAniListApp(t, "import", jsonPath)
+	saves := srv.RequestsFor("SaveMediaListEntry")
+	if len(saves) != 1 {
+		t.Fatal("Expected 1 save request, got", len(saves))
+	}
+	if vars := saves[0].Variables; vars["mediaId"] != 1.0 || vars["score"] != 6.0 {
+		t.Error("Unexpected save request variables:", vars)
+	}
+}
+
+func TestLoadOAuthTokenRefreshesWithTokenClient(t *testing.T) {
+	setUpAniListTest(t)
+	var clientId string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		clientId = r.Form.Get("client_id")
+		w.Write([]byte(`{"access_token": "refreshed", "token_type": "Bearer", "expires_in": 3600}`))
+	}))
+	defer srv.Close()
+	tokenUrl := aniListTokenUrl
+	aniListTokenUrl = srv.URL
+	t.Cleanup(func() { aniListTokenUrl = tokenUrl })
+
+	cfg := LoadConfig()
+	cfg.ALClientID = 9
+	cfg.Save()
+	expired := oauth2.OAuthToken{ClientID: 5, Token: "old", RefreshToken: "refresh",
+		ExpireDate: time.Now().Add(-time.Hour)}
+	if err := saveOAuthToken(expired); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := loadOAuthToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Token != "refreshed" || clientId != "5" {
+		t.Errorf("Expected token refreshed with client 5, got %q with client %s", token.Token, clientId)
+	}
+}
+
+func TestAniListBatch(t *testing.T) {
+	srv := setUpAniListTest(t)
+
+	// The fixtures can save only "Kaze no Uta", so "Tsuki no Michi" fails
+	runAniListApp(t, "batch", "--score=0", "--set-score", "5", "--inc-progress", "2", "--yes")
+	if n := len(srv.RequestsFor("SaveMediaListEntry")); n != 2 {
+		t.Fatal("Expected 2 save requests, got", n)
+	}
+	list := loadTestAniListCache(t, anilist.Anime)
+	if e := list.GetMediaListById(1); e.Score != 5 || e.Progress != 6 {
+		t.Errorf("Unexpected updated entry: score %v, progress %d", e.Score, e.Progress)
+	}
+	if e := list.GetMediaListById(3); e.Score != 0 || e.Progress != 0 {
+		t.Errorf("Failed update changed the cached entry: score %v, progress %d", e.Score, e.Progress)
+	}
+	if e := list.GetMediaListById(2); e.Score != 8 {
+		t.Error("Entry not matching the selector was changed")
+	}
+
+	runAniListApp(t, "batch", "--title", "^hoshi", "--format", "tv", "--delete", "--yes")
+	if vars := lastRequestFor(t, srv, "DeleteMediaListEntry").Variables; vars["id"] != 102.0 {
+		t.Error("Unexpected delete request variables:", vars)
+	}
+	if list := loadTestAniListCache(t, anilist.Anime); list.GetMediaListById(2) != nil {
+		t.Error("Deleted entry still in cache")
+	}
+}
+
+func TestAniListCustomLists(t *testing.T) {
+	srv := setUpAniListTest(t)
+
+	runAniListApp(t, "--list", "watch with")
+	list := loadTestAniListCache(t, anilist.Anime)
+	if names := alCustomListNames(list); strings.Join(names, ",") != "Watch with friends,Rewatch candidates" {
+		t.Fatal("Unexpected custom lists:", names)
+	}
+	if custom := alGetCustomList(list, "Watch with friends"); len(custom) != 2 {
+		t.Error("Expected 2 entries in custom list, got", len(custom))
+	}
+	if _, err := alFindCustomList(list, "nope"); err == nil {
+		t.Error("Expected unknown custom list to fail")
+	}
+	ambiguous := List{{CustomLists: []anilist.CustomList{{Name: "Watch later"}, {Name: "Watch with friends"}}}}
+	if _, err := alFindCustomList(ambiguous, "watch"); err == nil ||
+		err.Error() != `ambiguous custom list name "watch" matches: Watch later, Watch with friends` {
+		t.Error("Unexpected error for ambiguous custom list name:", err)
+	}
+
+	runAniListApp(t, "sel", "kaze")
+	runAniListApp(t, "custom-list", "add", "rewatch")
+	vars := lastRequestFor(t, srv, "SaveMediaListEntry").Variables
+	if fmt.Sprint(vars["customLists"]) != "[Watch with friends Rewatch candidates]" {
+		t.Error("Unexpected customLists variable:", vars["customLists"])
+	}
+	runAniListApp(t, "custom-list", "rm", "Watch with friends")
+	vars = lastRequestFor(t, srv, "SaveMediaListEntry").Variables
+	if fmt.Sprint(vars["customLists"]) != "[Rewatch candidates]" {
+		t.Error("Unexpected customLists variable:", vars["customLists"])
+	}
+	entry := loadTestAniListCache(t, anilist.Anime).GetMediaListById(1)
+	if names := entry.CustomListNames(); len(names) != 1 || names[0] != "Rewatch candidates" {
+		t.Error("Unexpected cached custom lists:", names)
+	}
+}
+
+func TestAniListEntryFields(t *testing.T) {
+	srv := setUpAniListTest(t)
+
+	runAniListApp(t, "sel", "kaze")
+	runAniListApp(t, "started", "2020-09-30")
+	vars := lastRequestFor(t, srv, "SaveMediaListEntry").Variables
+	if fmt.Sprint(vars["startedAt"]) != "map[day:30 month:9 year:2020]" ||
+		fmt.Sprint(vars["completedAt"]) != "map[day:<nil> month:<nil> year:<nil>]" || vars["notes"] != "with Tom" {
+		t.Error("Unexpected started request variables:", vars)
+	}
+
+	runAniListApp(t, "notes", "with", "Tom", "and", "Ann")
+	runAniListApp(t, "private")
+	runAniListApp(t, "repeat", "2")
+	vars = lastRequestFor(t, srv, "SaveMediaListEntry").Variables
+	if vars["notes"] != "with Tom and Ann" || vars["private"] != true || vars["repeat"] != 2.0 {
+		t.Error("Unexpected request variables:", vars)
+	}
+
+	runAniListApp(t, "cfg", "status-auto-update", "normal")
+	runAniListApp(t, "eps", "12")
+	entry := loadTestAniListCache(t, anilist.Anime).GetMediaListById(1)
+	if entry.Status != anilist.Completed || *entry.CompletedAt != *todayFuzzyDate() {
+		t.Error("Expected completed entry with today's finish date, got", entry.Status, entry.CompletedAt)
+	}
+	if *entry.StartedAt != (anilist.FuzzyDate{Year: 2020, Month: 9, Day: 30}) {
+		t.Error("Start date shouldn't change, got", entry.StartedAt)
+	}
+
+	for in, expected := range map[string]string{"2021": "2021", "2021-03": "2021-03", "2021-03-04": "2021-03-04", "none": ""} {
+		if date, err := parseFuzzyDate(in); err != nil || date.String() != expected {
+			t.Errorf("parseFuzzyDate(%q) = %v, %v; expected %v", in, date, err, expected)
+		}
+	}
+	if _, err := parseFuzzyDate("2021-13"); err == nil {
+		t.Error("Expected invalid date to fail")
+	}
+}
+
+func TestAniListAdvancedScores(t *testing.T) {
+	srv := setUpAniListTest(t)
+	srv.Prepend(anilisttest.Fixture{
+		Field: "Viewer",
+		Response: json.RawMessage(`{"data": {"Viewer": {"id": 1, "name": "tester", "mediaListOptions": {
+			"scoreFormat": "POINT_10_DECIMAL",
+			"animeList": {"advancedScoringEnabled": true,
+				"advancedScoring": ["Story", "Characters", "Visuals", "Audio", "Enjoyment"]}}}}}`),
+	})
+
+	runAniListApp(t, "sel", "kaze")
+	runAniListApp(t, "score", "--advanced", "story=8", "vis=9.5", "enjoyment=7")
+	vars := lastRequestFor(t, srv, "SaveMediaListEntry").Variables
+	if fmt.Sprint(vars["advancedScores"]) != "[8 0 9.5 0 7]" || vars["score"] != 8.2 {
+		t.Error("Unexpected advanced score request variables:", vars)
+	}
+	entry := loadTestAniListCache(t, anilist.Anime).GetMediaListById(1)
+	if got := formatAdvancedScores(entry.AdvancedScores, anilist.Point10Decimal); got != "Story 8.0, Visuals 9.5, Enjoyment 7.0" {
+		t.Error("Unexpected cached advanced scores:", got)
+	}
+	if entry := loadTestAniListCache(t, anilist.Anime).GetMediaListById(2); entry.AdvancedScores != nil {
+		t.Error("Expected no advanced scores, got", entry.AdvancedScores)
+	}
+
+	categories := []string{"Story", "Characters", "Visuals", "Audio", "Enjoyment"}
+	for _, invalid := range [][]string{{}, {"story"}, {"plot=8"}, {"story=11"}} {
+		if _, err := parseAdvancedScores(invalid, categories, nil, anilist.Point10); err == nil {
+			t.Errorf("Expected %v to fail", invalid)
+		}
+	}
+}
+
+func TestParseSeasonArgs(t *testing.T) {
+	now := time.Date(2021, time.April, 2, 0, 0, 0, 0, time.UTC)
+	cases := map[string]string{"": "SPRING 2021", "fall": "FALL 2021", "2019 winter": "WINTER 2019", "2018": "SPRING 2018"}
+	for in, expected := range cases {
+		season, year, err := parseSeasonArgs(strings.Fields(in), now)
+		if got := fmt.Sprint(season, " ", year); err != nil || got != expected {
+			t.Errorf("parseSeasonArgs(%q) = %v, %v; expected %v", in, got, err, expected)
+		}
+	}
+	if _, _, err := parseSeasonArgs([]string{"monsoon"}, now); err == nil {
+		t.Error("Expected invalid season to fail")
+	}
+	if season, _ := currentSeason(time.Date(2021, time.December, 31, 0, 0, 0, 0, time.UTC)); season != "FALL" {
+		t.Error("Expected December to be in fall, got", season)
+	}
+}
+
+func TestSearchFilterInput(t *testing.T) {
+	sc := &searchCui{}
+	seasons := map[string]string{"": " 0", "autumn 2019": "FALL 2019", "2020": " 2020", "spring": "SPRING 0"}
+	for in, expected := range seasons {
+		err := sc.setSeasonFilter(in)
+		if got := fmt.Sprint(sc.Filters.Season, " ", sc.Filters.Year); err != nil || got != expected {
+			t.Errorf("setSeasonFilter(%q) = %v, %v; expected %v", in, got, err, expected)
+		}
+	}
+	scores := map[string]string{"": "0 0", "70": "70 70", "60-85": "60 85", "75-": "75 0", "-40": "0 40"}
+	for in, expected := range scores {
+		err := sc.setScoreFilter(in)
+		if got := fmt.Sprint(sc.Filters.MinScore, " ", sc.Filters.MaxScore); err != nil || got != expected {
+			t.Errorf("setScoreFilter(%q) = %v, %v; expected %v", in, got, err, expected)
+		}
+		if text := sc.scoreFilterText(); in != "70" && text != in {
+			t.Errorf("Expected score filter %q to be displayed as is, got %q", in, text)
+		}
+	}
+	for _, invalid := range []string{"monsoon", "2019 rainy"} {
+		if err := sc.setSeasonFilter(invalid); err == nil {
+			t.Errorf("Expected season %q to fail", invalid)
+		}
+	}
+	if err := sc.setScoreFilter("50-150"); err == nil {
+		t.Error("Expected score above 100 to fail")
+	}
+}
+
+func TestAniListCalendar(t *testing.T) {
+	srv := setUpAniListTest(t)
+	now := time.Now()
+	srv.Prepend(anilisttest.Fixture{
+		Field:     "Page",
+		Variables: map[string]interface{}{"mediaIds": []int{1, 3}},
+		Response: json.RawMessage(fmt.Sprintf(`{"data": {"Page": {"pageInfo": {"hasNextPage": false}, "airingSchedules": [
+			{"id": 1, "airingAt": %d, "episode": 6, "mediaId": 1},
+			{"id": 2, "airingAt": %d, "episode": 7, "mediaId": 1},
+			{"id": 3, "airingAt": %d, "episode": 1, "mediaId": 3}
+		]}}}`, now.Add(-time.Minute).Unix(), now.Add(7*24*time.Hour).Unix(), now.Add(time.Hour).Unix())),
+	})
+
+	runAniListApp(t, "calendar", "--days", "3", "--tz", "UTC")
+	vars := lastRequestFor(t, srv, "Page").Variables
+	from, to := calendarSpan(now, time.UTC, 3)
+	if vars["from"] != float64(from.Unix()-1) || vars["to"] != float64(to.Unix()) {
+		t.Error("Unexpected calendar request variables:", vars)
+	}
+
+	list := loadTestAniListCache(t, anilist.Anime)
+	schedules := []anilist.AiringSchedule{
+		{AiringAt: int(now.Add(-time.Minute).Unix()), Episode: 5, MediaId: 1},
+		{AiringAt: int(now.Add(-time.Minute).Unix()), Episode: 4, MediaId: 1},
+		{AiringAt: int(now.Add(time.Hour).Unix()), Episode: 6, MediaId: 1},
+		{AiringAt: int(now.Add(-time.Minute).Unix()), Episode: 1, MediaId: 3},
+		{AiringAt: int(now.Unix()), Episode: 1, MediaId: 99},
+	}
+	episodes := alCalendarEpisodes(list, schedules, time.UTC, now)
+	if len(episodes) != 4 {
+		t.Fatal("Expected episodes of unknown media to be skipped, got", len(episodes))
+	}
+	for i, expected := range []bool{true, false, false, false} {
+		if episodes[i].Behind != expected {
+			t.Errorf("Episode %d of %s: expected behind = %v", episodes[i].Episode, episodes[i].Title, expected)
+		}
+	}
+	if _, err := calendarLocation("Mars/Olympus"); err == nil {
+		t.Error("Expected invalid time zone to fail")
+	}
+}
+
+func TestAniListCalendarExport(t *testing.T) {
+	srv := setUpAniListTest(t)
+	airingAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	srv.Prepend(anilisttest.Fixture{
+		Field: "Page",
+		Response: json.RawMessage(fmt.Sprintf(`{"data": {"Page": {"pageInfo": {"hasNextPage": false}, "airingSchedules": [
+			{"id": 1, "airingAt": %d, "episode": 6, "mediaId": 1}
+		]}}}`, airingAt.Unix())),
+	})
+
+	path := filepath.Join(filepath.Dir(AniListCacheFile), "airing.ics")
+	runAniListApp(t, "calendar", "export", "--ics", path)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ics := string(data)
+	for _, s := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:anilist-1-ep6@mal\r\n",
+		"DTSTART:" + airingAt.UTC().Format("20060102T150405Z") + "\r\n",
+		"DTEND:" + airingAt.Add(24*time.Minute).UTC().Format("20060102T150405Z") + "\r\n",
+		"SUMMARY:Kaze no Uta - Episode 6\r\n",
+		"URL:https://anilist.co/anime/1\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(ics, s) {
+			t.Errorf("Expected %q in the calendar:\n%s", s, ics)
+		}
+	}
+
+	folded := foldICalendarLine("DESCRIPTION:" + strings.Repeat("ą", 60))
+	for _, line := range strings.Split(folded, "\r\n") {
+		if len(line) > 75 || !utf8.ValidString(line) {
+			t.Errorf("Invalid folded line %q", line)
+		}
+	}
+	if strings.Replace(folded, "\r\n ", "", -1) != "DESCRIPTION:"+strings.Repeat("ą", 60) {
+		t.Error("Unfolding doesn't give the original line:", folded)
+	}
+}
+
+func TestAniListWatchAiring(t *testing.T) {
+	srv := setUpAniListTest(t)
+	events := make([]airingEvent, 0)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev airingEvent
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			t.Error(err)
+		}
+		events = append(events, ev)
+	}))
+	defer hook.Close()
+	dir := filepath.Dir(AniListCacheFile)
+	args := []string{"watch-airing", "--once", "--webhook", hook.URL,
+		"--exec", "touch " + filepath.Join(dir, "{{.AnimeId}}-ep{{.Episode}}")}
+
+	// Notifications present on the first run are only marked as seen
+	runAniListApp(t, args...)
+	if len(events) != 0 {
+		t.Fatal("Expected no actions on the first run, got", events)
+	}
+
+	srv.Prepend(anilisttest.Fixture{
+		Field:     "Page",
+		Variables: map[string]interface{}{"resetNotificationCount": false},
+		Response: json.RawMessage(`{"data": {"Page": {"notifications": [
+			{"id": 2, "animeId": 1, "episode": 5, "contexts": ["Episode ", " of ", " aired."], "createdAt": 1600600000,
+				"media": {"title": {"userPreferred": "Kaze no Uta"}}},
+			{"id": 1, "animeId": 1, "episode": 4, "contexts": ["Episode ", " of ", " aired."], "createdAt": 1600000000,
+				"media": {"title": {"userPreferred": "Kaze no Uta"}}}
+		]}}}`),
+	})
+	runAniListApp(t, args...)
+	if len(events) != 1 || events[0].Id != 2 || events[0].Episode != 5 || events[0].Title != "Kaze no Uta" ||
+		events[0].Url != "https://anilist.co/anime/1" {
+		t.Fatal("Expected a webhook call for the new notification only, got", events)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "1-ep5")); err != nil {
+		t.Error("Expected the command to run with templated arguments:", err)
+	}
+
+	runAniListApp(t, args...)
+	if len(events) != 1 {
+		t.Error("Expected seen notifications to be skipped, got", events)
+	}
+}
+
+func TestAniListInbox(t *testing.T) {
+	srv := setUpAniListTest(t)
+	srv.Prepend(anilisttest.Fixture{
+		Field:     "Page",
+		Variables: map[string]interface{}{"perPage": defaultInboxPageSize},
+		Response: json.RawMessage(`{"data": {"Page": {"pageInfo": {"hasNextPage": true}, "notifications": [
+			{"id": 3, "type": "FOLLOWING", "context": " started following you.", "createdAt": 1600700000,
+				"user": {"name": "Miyu"}},
+			{"id": 2, "type": "AIRING", "episode": 5, "contexts": ["Episode ", " of ", " aired."],
+				"createdAt": 1600600000, "media": {"id": 1, "type": "ANIME", "title": {"userPreferred": "Kaze no Uta"}}},
+			{"id": 1, "type": "MEDIA_MERGE", "context": " were merged into ", "reason": "Duplicate",
+				"deletedMediaTitles": ["Kaze", "Uta"], "createdAt": 1600500000,
+				"media": {"id": 4, "type": "MANGA", "title": {"userPreferred": "Kaze no Uta"}}}
+		]}}}`),
+	})
+
+	runAniListApp(t, "inbox", "--select", "2")
+	if id := LoadConfig().ALSelectedID; id != 1 {
+		t.Error("Expected the entry of the airing notification to be selected, got", id)
+	}
+	if err := AniListApp(cli.NewApp()).Run([]string{"mal", "inbox", "--select", "1"}); err == nil {
+		t.Error("Expected an error selecting an entry of a follow notification")
+	}
+
+	runAniListApp(t, "inbox", "--type", "follows,media", "--page", "2", "--mark-read")
+	vars := lastRequestFor(t, srv, "Page").Variables
+	if fmt.Sprint(vars["types"]) != "[FOLLOWING RELATED_MEDIA_ADDITION MEDIA_DATA_CHANGE MEDIA_MERGE MEDIA_DELETION]" ||
+		fmt.Sprint(vars["page"]) != "2" || vars["resetNotificationCount"] != true {
+		t.Error("Unexpected notifications query variables:", vars)
+	}
+	if _, err := parseNotificationTypes("airing,spam"); err == nil {
+		t.Error("Expected an error for an invalid notification type")
+	}
+
+	notifications, _, err := newAniListClient(oauth2.OAuthToken{Token: "test-token"}).
+		QueryNotifications(1, defaultInboxPageSize, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"Miyu started following you.",
+		"Episode 5 of Kaze no Uta aired.",
+		"Kaze, Uta were merged into Kaze no Uta",
+	}
+	for i, n := range notifications {
+		if n.Text() != expected[i] {
+			t.Errorf("Expected %q, got %q", expected[i], n.Text())
+		}
+	}
+	if url := notifications[2].Url(); url != "https://anilist.co/manga/4" {
+		t.Error("Unexpected notification url:", url)
+	}
+}
+
+func TestAniListBehind(t *testing.T) {
+	srv := setUpAniListTest(t)
+	srv.Prepend(anilisttest.Fixture{
+		Field:     "Page",
+		Variables: map[string]interface{}{"ids": []int{1}},
+		Response: json.RawMessage(`{"data": {"Page": {"pageInfo": {"hasNextPage": false}, "media": [
+			{"id": 1, "status": "RELEASING", "episodes": 12, "duration": 24,
+				"nextAiringEpisode": {"id": 1, "airingAt": 1700003600, "timeUntilAiring": 3600, "episode": 8}}
+		]}}}`),
+	})
+	runAniListApp(t, "behind")
+	runAniListApp(t, "--behind")
+	if ids := lastRequestFor(t, srv, "Page").Variables["ids"]; fmt.Sprint(ids) != "[1]" {
+		t.Error("Expected airing state of the watched entry to be queried, got", ids)
+	}
+
+	list := loadTestAniListCache(t, anilist.Anime)
+	list[1].Status, list[1].Progress = anilist.Paused, 20
+	media := []anilist.MediaFull{
+		{Id: 1, Status: "RELEASING", NextAiringEpisode: anilist.AiringSchedule{Episode: 8}},
+		{Id: 2, Status: "FINISHED", Episodes: 24, Duration: 23},
+		{Id: 3, Status: "RELEASING"},
+	}
+	behind := alBehindEntries(list, media)
+	if len(behind) != 2 {
+		t.Fatal("Expected 2 entries behind, got", behind)
+	}
+	if behind[0].Entry.Id != 2 || behind[0].Behind != 4 || behind[0].CatchUp != 4*23 {
+		t.Error("Expected the finished show 4 episodes behind first, got", behind[0])
+	}
+	if behind[1].Entry.Id != 1 || behind[1].Behind != 3 || behind[1].CatchUp != 3*24 {
+		t.Error("Expected the airing show 3 episodes behind, got", behind[1])
+	}
+	if formatCatchUpTime(92) != "1h32m" || formatCatchUpTime(45) != "45m" {
+		t.Error("Unexpected catch-up time format")
+	}
+}
+
+func TestAniListCompare(t *testing.T) {
+	srv := setUpAniListTest(t)
+	srv.Prepend(anilisttest.Fixture{
+		Field:     "MediaListCollection",
+		Variables: map[string]interface{}{"userName": "friend"},
+		Response: json.RawMessage(`{"data": {"MediaListCollection": {"lists": [
+			{"name": "Completed", "isCustomList": false, "status": "COMPLETED", "entries": [
+				{"id": 201, "status": "COMPLETED", "score": 6, "progress": 24,
+					"media": {"id": 2, "title": {"userPreferred": "Hoshi no Umi"}, "type": "ANIME", "episodes": 24}},
+				{"id": 202, "status": "COMPLETED", "score": 9, "progress": 1,
+					"media": {"id": 3, "title": {"userPreferred": "Tsuki no Michi"}, "type": "ANIME", "episodes": 1}},
+				{"id": 203, "status": "COMPLETED", "score": 7, "progress": 13,
+					"media": {"id": 4, "title": {"userPreferred": "Yuki no Hana"}, "type": "ANIME", "episodes": 13}}
+			]}
+		]}}}`),
+	})
+	runAniListApp(t, "user", "--status", "completed", "friend")
+	if vars := lastRequestFor(t, srv, "MediaListCollection").Variables; vars["userName"] != "friend" ||
+		vars["scoreFormat"] != "POINT_10" {
+		t.Error("Expected the list of friend in your score format, got", vars)
+	}
+	out := aniListAppOutput(t, "compare", "friend")
+	for substr, expected := range map[string]string{
+		"Shared entries": "Shared entries: 2, scored by both: 1",
+		"Affinity":       "Affinity: not enough entries scored by both of you",
+		"Mean score":     "Mean score difference: -2.0",
+		"Hoshi no Umi":   "Hoshi no Umi 8 6 -2",
+		"Tsuki no Michi": "Tsuki no Michi (scored 9)",
+	} {
+		if line := outputLine(out, substr); line != expected {
+			t.Errorf("Expected %q in the comparison, got %q", expected, line)
+		}
+	}
+
+	if r, ok := pearsonCorrelation([]float64{6, 7, 9}, []float64{5, 6, 8}); !ok || math.Abs(r-1) > 1e-9 {
+		t.Error("Expected perfect correlation, got", r, ok)
+	}
+	if r, ok := pearsonCorrelation([]float64{6, 7, 9}, []float64{9, 8, 6}); !ok || math.Abs(r+1) > 1e-9 {
+		t.Error("Expected perfect negative correlation, got", r, ok)
+	}
+	if _, ok := pearsonCorrelation([]float64{6, 7}, []float64{8, 8}); ok {
+		t.Error("Expected no correlation of constant scores")
+	}
+}
+
+func TestAniListTogether(t *testing.T) {
+	srv := setUpAniListTest(t)
+	listFixture := func(name, entries string) anilisttest.Fixture {
+		return anilisttest.Fixture{
+			Field:     "MediaListCollection",
+			Variables: map[string]interface{}{"userName": name},
+			Response: json.RawMessage(`{"data": {"MediaListCollection": {"lists": [
+				{"name": "List", "isCustomList": false, "entries": [` + entries + `]}]}}}`),
+		}
+	}
+	srv.Prepend(listFixture("alice", `
+		{"id": 301, "status": "PLANNING", "media": {"id": 3, "title": {"userPreferred": "Tsuki no Michi"}, "episodes": 1, "duration": 110}},
+		{"id": 302, "status": "PLANNING", "media": {"id": 4, "title": {"userPreferred": "Yuki no Hana"}, "episodes": 13, "duration": 24}},
+		{"id": 303, "status": "PLANNING", "media": {"id": 1, "title": {"userPreferred": "Kaze no Uta"}, "episodes": 12, "duration": 24}}`))
+	srv.Prepend(listFixture("bob", `
+		{"id": 401, "status": "PLANNING", "media": {"id": 4, "title": {"userPreferred": "Yuki no Hana"}, "episodes": 13, "duration": 24}},
+		{"id": 402, "status": "PLANNING", "media": {"id": 5, "title": {"userPreferred": "Hana no Kage"}, "episodes": 12, "duration": 24}},
+		{"id": 403, "status": "COMPLETED", "media": {"id": 1, "title": {"userPreferred": "Kaze no Uta"}, "episodes": 12, "duration": 24}}`))
+	srv.Prepend(anilisttest.Fixture{
+		Field: "Page",
+		Response: json.RawMessage(`{"data": {"Page": {"pageInfo": {"hasNextPage": false}, "media": [
+			{"id": 3, "averageScore": 70, "episodes": 1, "duration": 110},
+			{"id": 4, "averageScore": 80, "episodes": 13, "duration": 24}
+		]}}}`),
+	})
+	out := aniListAppOutput(t, "together", "alice", "bob", "tester")
+	if line := outputLine(out, "Yuki no Hana"); line != "1 Yuki no Hana 2/3 80% 13 5h12m alice, bob" {
+		t.Error("Expected the better scored Yuki no Hana first, got", line)
+	}
+	if line := outputLine(out, "Tsuki no Michi"); line != "2 Tsuki no Michi 2/3 70% 1 1h50m tester, alice" {
+		t.Error("Expected Tsuki no Michi planned by tester and alice second, got", line)
+	}
+	if strings.Contains(out, "Kaze no Uta") {
+		t.Error("Expected anime completed by bob to be left out")
+	}
+
+	out = aniListAppOutput(t, "together", "--min", "1", "alice", "bob", "tester")
+	if !strings.Contains(out, "Hana no Kage") || strings.Contains(out, "Kaze no Uta") {
+		t.Error("Expected anime planned by one user, except the one completed by bob, got", out)
+	}
+}
+
+func TestAniListStatsReport(t *testing.T) {
+	srv := setUpAniListTest(t)
+	runAniListApp(t, "stats", "--json")
+	runAniListApp(t, "stats")
+	if n := len(srv.RequestsFor("Page")); n != 1 {
+		t.Error("Expected media metadata to be fetched once and cached, got requests:", n)
+	}
+
+	list := loadTestAniListCache(t, anilist.Anime)
+	list[0].Season, list[0].SeasonYear = "FALL", 2020
+	list[1].Season, list[1].SeasonYear = "SPRING", 2020
+	list[1].CompletedAt = &anilist.FuzzyDate{Year: 2021, Month: 3}
+	metadata := map[int]mediaMetadata{
+		1: {Genres: []string{"Drama", "Music"}, AverageScore: 72},
+		2: {Genres: []string{"Drama"}, Tags: []string{"Space"}, AverageScore: 70},
+	}
+	report := buildStatsReport(list, anilist.Anime, anilist.Point10, metadata)
+
+	if len(report.Scores) != 10 || report.Scores[7].Label != "8" || report.Scores[7].Count != 1 {
+		t.Error("Unexpected score histogram:", report.Scores)
+	}
+	if g := report.Genres; len(g) != 2 ||
+		g[0].Label != "Drama" || g[0].Count != 2 || g[0].MeanScore != 8 || g[0].Minutes != 4*24+48*23 ||
+		g[1].Label != "Music" || g[1].Count != 1 || g[1].MeanScore != 0 {
+		t.Error("Unexpected genres:", report.Genres)
+	}
+	if len(report.Seasons) != 2 || report.Seasons[0].Label != "Spring 2020" || report.Seasons[1].Label != "Fall 2020" {
+		t.Error("Expected seasons in chronological order, got", report.Seasons)
+	}
+	if len(report.Months) != 1 || report.Months[0].Label != "2021-03" || report.Months[0].Minutes != 48*23 {
+		t.Error("Unexpected completed per month:", report.Months)
+	}
+	if report.ComparedEntries != 1 || report.MeanScore100 != 80 || report.CommunityMeanScore != 70 {
+		t.Error("Unexpected community score comparison:", report)
+	}
+	if label := scoreBucketLabel(85, anilist.Point100); label != "81-90" {
+		t.Error("Expected score 85 in bucket 81-90, got", label)
+	}
+}
+
+func TestAniListReview(t *testing.T) {
+	setUpAniListTest(t)
+	png := []byte("\x89PNG\r\n\x1a\n cover")
+	covers := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(png)
+	}))
+	defer covers.Close()
+
+	runAniListApp(t, "--all")
+	list := loadTestAniListCache(t, anilist.Anime)
+	metadata := map[int]mediaMetadata{2: {CoverImage: covers.URL + "/2.png"}}
+	if err := cacheCovers(list, metadata); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hoshi no Umi, cached without a completion date, was last updated in 2020
+	path := filepath.Join(filepath.Dir(AniListCacheFile), "review.html")
+	runAniListApp(t, "review", "-o", path, "2020")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(data)
+	for _, s := range []string{
+		"<title>tester's 2020 in review</title>",
+		`<a href="https://anilist.co/anime/2">Hoshi no Umi</a>`,
+		"<b>9.2</b>hours watched",
+		`<img src="data:image/png;base64,`,
+		"<svg",
+		">Adventure</text>",
+	} {
+		if !strings.Contains(page, s) {
+			t.Errorf("Expected %q in the review", s)
+		}
+	}
+
+	list[1].StartedAt = &anilist.FuzzyDate{Year: 2021, Month: 1, Day: 1}
+	list[1].CompletedAt = &anilist.FuzzyDate{Year: 2021, Month: 1, Day: 3}
+	completions := reviewCompletions(list, 2021)
+	review := buildReview(&AniList{MediaType: anilist.Anime}, 2021, completions, nil)
+	if review.Total != 1 || review.Binge == nil || review.Binge.Days != 3 || review.Binge.Episodes != 24 {
+		t.Error("Expected 24 episodes binged in 3 days, got", review.Binge)
+	}
+	if len(reviewCompletions(list, 2020)) != 0 {
+		t.Error("Expected the completion date to take precedence over the update time")
+	}
+}
+
+func TestAniListProfiles(t *testing.T) {
+	setUpAniListTest(t)
+	oldCreds := AniListCredsFile
+
+	appCfg := AppConfig{Mode: AniListMode}
+	if err := migrateToProfiles(&appCfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(oldCreds); !os.IsNotExist(err) {
+		t.Error("credentials left in the data directory after migration")
+	}
+	if err := useProfile(defaultProfile); err != nil {
+		t.Fatal(err)
+	}
+	if AniListCredsFile != filepath.Join(ProfilesDir, defaultProfile, "aniListCreds.json") {
+		t.Errorf("credentials file not in the default profile: %s", AniListCredsFile)
+	}
+	if _, err := loadCachedOAuthToken(); err != nil {
+		t.Errorf("migrated token not loaded: %v", err)
+	}
+
+	runAniListApp(t, "profile", "add", "--use", "work")
+	LoadJsonFile(AppConfigFile, &appCfg)
+	if appCfg.Profile != "work" || appCfg.Mode != AniListMode {
+		t.Errorf("unexpected app config after switching: %+v", appCfg)
+	}
+	if err := AniListApp(cli.NewApp()).Run([]string{"mal", "profile", "rm", "--yes", "work"}); err == nil {
+		t.Error("removed the current profile")
+	}
+	if err := AniListApp(cli.NewApp()).Run([]string{"mal", "profile", "add", "../x"}); err == nil {
+		t.Error("accepted an invalid profile name")
+	}
+
+	runAniListApp(t, "--profile", "work", "profile", "ls")
+	if AniListCredsFile != filepath.Join(ProfilesDir, "work", "aniListCreds.json") {
+		t.Errorf("--profile didn't switch data files: %s", AniListCredsFile)
+	}
+	if err := AniListApp(cli.NewApp()).Run([]string{"mal", "--profile", "missing", "profile", "ls"}); err == nil {
+		t.Error("used a profile that doesn't exist")
+	}
+
+	runAniListApp(t, "profile", "use", defaultProfile)
+	runAniListApp(t, "profile", "rm", "--yes", "work")
+	if profileExists("work") {
+		t.Error("profile not removed")
+	}
+
+	runAniListApp(t, "profile", "add", "--use", "gone")
+	if err := os.RemoveAll(profileDir("gone")); err != nil {
+		t.Fatal(err)
+	}
+	if appCfg, err := loadAppConfig(); err != nil || appCfg.Profile != defaultProfile {
+		t.Errorf("didn't fall back to the default profile: %+v, %v", appCfg, err)
+	}
+	LoadJsonFile(AppConfigFile, &appCfg)
+	if appCfg.Profile != defaultProfile {
+		t.Errorf("fallback profile not saved: %+v", appCfg)
+	}
+}
+
+func TestParseScoreRange(t *testing.T) {
+	cases := map[string][2]float32{"7": {7, 7}, "7-9": {7, 9}, "7-": {7, -1}, "-5": {-1, 5}}
+	for in, expected := range cases {
+		min, max, err := parseScoreRange(in)
+		if err != nil || min != expected[0] || max != expected[1] {
+			t.Errorf("parseScoreRange(%q) = %v, %v, %v; expected %v", in, min, max, err, expected)
+		}
+	}
+	if _, _, err := parseScoreRange("a-b"); err == nil {
+		t.Error("Expected invalid range to fail")
+	}
+}
+
+func TestWhereExpression(t *testing.T) {
+	list := List{
+		{Status: anilist.Current, Score: 8, Progress: 4,
+			MediaDeficient: anilist.MediaDeficient{Id: 1, Title: anilist.MediaTitle{UserPreferred: "Kaze no Uta"},
+				Type: "ANIME", Format: "TV", Episodes: 12}},
+		{Status: anilist.Completed, Score: 9, Progress: 12,
+			MediaDeficient: anilist.MediaDeficient{Id: 2, Title: anilist.MediaTitle{UserPreferred: "Hoshi"},
+				Type: "ANIME", Format: "TV", Episodes: 12}},
+		{Status: anilist.Planning, Score: 0,
+			MediaDeficient: anilist.MediaDeficient{Id: 3, Title: anilist.MediaTitle{UserPreferred: "Ame"},
+				Type: "ANIME", Format: "MOVIE", Episodes: 1}},
+	}
+	cases := map[string][]int{
+		"score>=8 and format=tv and progress<episodes": {1},
+		"status=completed or status=plantowatch":       {2, 3},
+		"not (format=TV) || id == 2":                   {2, 3},
+		"title~'^(kaze|ame)' and !(score>5)":           {3},
+		"title !~ o":                                   {3},
+	}
+	for where, expected := range cases {
+		expr, err := parseWhere(where)
+		if err != nil {
+			t.Errorf("parseWhere(%q): %v", where, err)
+			continue
+		}
+		ids := make([]int, 0)
+		for i := range list {
+			if expr.eval(&list[i]) {
+				ids = append(ids, list[i].Id)
+			}
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(expected) {
+			t.Errorf("%q matched %v, expected %v", where, ids, expected)
+		}
+	}
+	for _, invalid := range []string{"", "score>", "score>=8 and", "(score>8", "1=1", "nope=1 or", "title~'('"} {
+		if _, err := parseWhere(invalid); err == nil {
+			t.Errorf("Expected %q to fail", invalid)
+		}
+	}
+
+	keys, err := parseSortKeys("format,-progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sortList(list, keys)
+	if list[0].Id != 3 || list[1].Id != 2 || list[2].Id != 1 {
+		t.Error("Unexpected order", list[0].Id, list[1].Id, list[2].Id)
+	}
+	sortList(list, configSortKeys(ByTitle))
+	if list[0].Id != 3 || list[1].Id != 2 {
+		t.Error("Expected entries sorted by title")
+	}
+	if _, err := parseSortKeys("score,nope"); err == nil {
+		t.Error("Expected invalid sort key to fail")
+	}
+}
+
+func TestScoreConversion(t *testing.T) {
+	cases := []struct {
+		score       float32
+		scoreFormat anilist.ScoreFormat
+		tenPoint    float32
+	}{
+		{85, anilist.Point100, 8.5},
+		{3, anilist.Point3, 10},
+		{4, anilist.Point5, 8},
+		{7.5, anilist.Point10Decimal, 7.5},
+	}
+	for _, c := range cases {
+		if got := toTenPointScore(c.score, c.scoreFormat); got != c.tenPoint {
+			t.Errorf("toTenPointScore(%v, %s) = %v, expected %v", c.score, c.scoreFormat, got, c.tenPoint)
+		}
+		if got := fromTenPointScore(c.tenPoint, c.scoreFormat); got != c.score {
+			t.Errorf("fromTenPointScore(%v, %s) = %v, expected %v", c.tenPoint, c.scoreFormat, got, c.score)
+		}
+	}
+	if got := fromTenPointScore(1, anilist.Point3); got != 1 {
+		t.Error("Expected nonzero score to stay nonzero, got", got)
+	}
+}
+
 func TestParseScore(t *testing.T) {
 	{
 		_, err := parseScore("0", anilist.Point10)
@@ -116,3 +1296,29 @@ func TestParseScore(t *testing.T) {
 		}
 	}
 }
+
+func TestAlStatusAutoUpdateManga(t *testing.T) {
+	cfg := NewConfig()
+	cfg.StatusAutoUpdateMode = Normal
+
+	entry := &anilist.MediaListEntry{
+		Status:   anilist.Current,
+		Progress: 50,
+		MediaDeficient: anilist.MediaDeficient{
+			Type:     string(anilist.Manga),
+			Chapters: 50,
+		},
+	}
+	alStatusAutoUpdate(cfg, entry)
+	if entry.Status != anilist.Completed {
+		t.Error("Expected completed status, got", entry.Status)
+	}
+
+	entry.Status = anilist.Current
+	entry.Progress = 10
+	entry.Chapters = 0
+	alStatusAutoUpdate(cfg, entry)
+	if entry.Status != anilist.Current {
+		t.Error("Expected status to stay unchanged for unknown chapter count, got", entry.Status)
+	}
+}
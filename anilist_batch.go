@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aqatl/mal/anilist"
+	"github.com/fatih/color"
+	"github.com/urfave/cli"
+)
+
+// Entries matching all of the set criteria are selected
+type batchSelector struct {
+	Status   anilist.MediaListStatus
+	Title    *regexp.Regexp
+	Formats  map[string]bool
+	Season   string
+	Year     int
+	MinScore float32
+	MaxScore float32
+	Ids      map[int]bool
+}
+
+func parseBatchSelector(ctx *cli.Context) (*batchSelector, error) {
+	sel := &batchSelector{MinScore: -1, MaxScore: -1}
+	empty := true
+
+	if status := ctx.String("status"); status != "" {
+		if sel.Status = anilist.ParseStatus(status); sel.Status == anilist.All {
+			return nil, fmt.Errorf("invalid status %q", status)
+		}
+		empty = false
+	}
+	if title := ctx.String("title"); title != "" {
+		re, err := regexp.Compile("(?i)" + title)
+		if err != nil {
+			return nil, fmt.Errorf("invalid title regex: %v", err)
+		}
+		sel.Title = re
+		empty = false
+	}
+	if formats := ctx.String("format"); formats != "" {
+		sel.Formats = make(map[string]bool)
+		for _, format := range strings.Split(formats, ",") {
+			sel.Formats[strings.ToUpper(strings.TrimSpace(format))] = true
+		}
+		empty = false
+	}
+	if season := ctx.String("season"); season != "" {
+		for _, part := range strings.Fields(season) {
+			if year, err := strconv.Atoi(part); err == nil {
+				sel.Year = year
+			} else {
+				sel.Season = strings.ToUpper(part)
+			}
+		}
+		empty = false
+	}
+	if score := ctx.String("score"); score != "" {
+		var err error
+		if sel.MinScore, sel.MaxScore, err = parseScoreRange(score); err != nil {
+			return nil, err
+		}
+		empty = false
+	}
+	if ids := ctx.String("ids"); ids != "" {
+		sel.Ids = make(map[int]bool)
+		for _, id := range strings.Split(ids, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(id))
+			if err != nil {
+				return nil, fmt.Errorf("invalid id %q", id)
+			}
+			sel.Ids[n] = true
+		}
+		empty = false
+	}
+
+	if empty {
+		return nil, fmt.Errorf("no selector given; use at least one of " +
+			"--status, --title, --format, --season, --score, --ids")
+	}
+	return sel, nil
+}
+
+// Parses "7", "7-9", "7-" or "-5". Open ends are returned as -1
+func parseScoreRange(score string) (min, max float32, err error) {
+	bounds := strings.SplitN(score, "-", 2)
+	if len(bounds) == 1 {
+		bounds = append(bounds, bounds[0])
+	}
+	parsed := [2]float32{-1, -1}
+	for i, bound := range bounds {
+		if bound = strings.TrimSpace(bound); bound == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(bound, 32)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid score range %q; use e.g. 7, 7-9, 7- or -5", score)
+		}
+		parsed[i] = float32(f)
+	}
+	return parsed[0], parsed[1], nil
+}
+
+func (sel *batchSelector) matches(entry *anilist.MediaListEntry) bool {
+	if sel.Status != anilist.All && entry.Status != sel.Status {
+		return false
+	}
+	if sel.Title != nil {
+		titles := append([]string{entry.Title.Romaji, entry.Title.English, entry.Title.Native,
+			entry.Title.UserPreferred}, entry.Synonyms...)
+		matched := false
+		for _, title := range titles {
+			if title != "" && sel.Title.MatchString(title) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if sel.Formats != nil && !sel.Formats[entry.Format] {
+		return false
+	}
+	if sel.Season != "" && entry.Season != sel.Season {
+		return false
+	}
+	if sel.Year != 0 && entry.SeasonYear != sel.Year {
+		return false
+	}
+	if sel.MinScore >= 0 && entry.Score < sel.MinScore {
+		return false
+	}
+	if sel.MaxScore >= 0 && entry.Score > sel.MaxScore {
+		return false
+	}
+	if sel.Ids != nil && !sel.Ids[entry.Id] {
+		return false
+	}
+	return true
+}
+
+type batchOperation struct {
+	Status      anilist.MediaListStatus
+	SetScore    bool
+	Score       float32
+	IncProgress int
+	Delete      bool
+}
+
+func parseBatchOperation(ctx *cli.Context, scoreFormat anilist.ScoreFormat) (*batchOperation, error) {
+	op := &batchOperation{Delete: ctx.Bool("delete"), IncProgress: ctx.Int("inc-progress")}
+
+	if status := ctx.String("set-status"); status != "" {
+		if op.Status = anilist.ParseStatus(status); op.Status == anilist.All {
+			return nil, fmt.Errorf("invalid status; possible values: " +
+				"watching|planning|completed|dropped|paused|repeating")
+		}
+	}
+	if ctx.IsSet("set-score") {
+		score, err := parseScore(ctx.String("set-score"), scoreFormat)
+		if err != nil {
+			return nil, err
+		}
+		op.SetScore, op.Score = true, score
+	}
+
+	modifies := op.Status != anilist.All || op.SetScore || op.IncProgress != 0
+	if op.Delete && modifies {
+		return nil, fmt.Errorf("--delete can't be combined with other operations")
+	}
+	if !op.Delete && !modifies {
+		return nil, fmt.Errorf("no operation given; use --set-status, --set-score, --inc-progress or --delete")
+	}
+	return op, nil
+}
+
+func (op *batchOperation) apply(cfg *Config, entry *anilist.MediaListEntry) {
+	if op.IncProgress != 0 {
+		entry.Progress += op.IncProgress
+		if length := entry.Length(); length > 0 && entry.Progress > length {
+			entry.Progress = length
+		}
+		if entry.Progress < 0 {
+			entry.Progress = 0
+		}
+		alStatusAutoUpdate(cfg, entry)
+	}
+	if op.Status != anilist.All {
+		entry.Status = op.Status
+		alAutoFillDates(entry)
+	}
+	if op.SetScore {
+		entry.Score = op.Score
+	}
+}
+
+func (op *batchOperation) String() string {
+	if op.Delete {
+		return color.HiRedString("delete")
+	}
+	changes := make([]string, 0)
+	if op.Status != anilist.All {
+		changes = append(changes, "status -> "+color.HiCyanString(op.Status.String()))
+	}
+	if op.SetScore {
+		changes = append(changes, "score -> "+color.HiCyanString("%v", op.Score))
+	}
+	if op.IncProgress != 0 {
+		changes = append(changes, "progress "+color.HiCyanString("%+d", op.IncProgress))
+	}
+	return strings.Join(changes, ", ")
+}
+
+func alBatch(ctx *cli.Context) error {
+	al, err := loadAniList(ctx)
+	if err != nil {
+		return err
+	}
+	cfg := LoadConfig()
+
+	sel, err := parseBatchSelector(ctx)
+	if err != nil {
+		return err
+	}
+	op, err := parseBatchOperation(ctx, al.User.MediaListOptions.ScoreFormat)
+	if err != nil {
+		return err
+	}
+
+	selected := make([]int, 0)
+	for i := range al.List {
+		if sel.matches(&al.List[i]) {
+			selected = append(selected, al.List[i].ListId)
+		}
+	}
+	if len(selected) == 0 {
+		fmt.Println("No entries match")
+		return nil
+	}
+
+	alPrintBatchTable(al, cfg, selected)
+	fmt.Fprintf(color.Output, "\nOperation: %s\n", op)
+	if !ctx.Bool("yes") && !confirm(fmt.Sprintf("Apply to %d entries?", len(selected))) {
+		fmt.Println("Aborted")
+		return nil
+	}
+
+	yellow := color.New(color.FgHiYellow).SprintFunc()
+	red := color.New(color.FgHiRed).SprintFunc()
+	cyan := color.New(color.FgHiCyan).SprintFunc()
+
+	succeeded, queued := 0, 0
+	failed := make([]string, 0)
+	for _, listId := range selected {
+		entry := alGetEntryByListId(al.List, listId)
+		title := entry.Title.UserPreferred
+
+		var wasQueued bool
+		if op.Delete {
+			wasQueued, err = alRemoveEntry(al, entry)
+			if err == nil {
+				al.List = al.List.DeleteById(listId)
+			}
+		} else {
+			updated := *entry
+			op.apply(cfg, &updated)
+			if wasQueued, err = alSaveEntry(al, &updated); err == nil {
+				*entry = updated
+			}
+		}
+
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", title, err))
+			fmt.Fprintf(color.Output, "%s %s: %v\n", red("x"), yellow(title), err)
+			continue
+		}
+		if wasQueued {
+			queued++
+		} else {
+			succeeded++
+		}
+		fmt.Fprintf(color.Output, "%s %s\n", cyan("v"), yellow(title))
+	}
+
+	if err := saveAniListLists(al); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(color.Output, "\nSucceeded: %s, queued: %s, failed: %s\n",
+		cyan(succeeded), cyan(queued), red(len(failed)))
+	for _, f := range failed {
+		fmt.Fprintln(color.Output, "  "+red(f))
+	}
+	return nil
+}
+
+func alGetEntryByListId(list List, listId int) *anilist.MediaListEntry {
+	for i := range list {
+		if list[i].ListId == listId {
+			return &list[i]
+		}
+	}
+	return nil
+}
+
+func alPrintBatchTable(al *AniList, cfg *Config, listIds []int) {
+	progressHeader := "Eps"
+	if al.MediaType == anilist.Manga {
+		progressHeader = "Chs"
+	}
+	titleWidth := cfg.ListWidth - 8 - 12 - 8 - 6
+	fmt.Printf("%8s  %-*.*s%12s%8s%6s\n", "Id", titleWidth, titleWidth, "Title", "Status",
+		progressHeader, "Score")
+	fmt.Println(strings.Repeat("=", cfg.ListWidth))
+	for _, listId := range listIds {
+		entry := alGetEntryByListId(al.List, listId)
+		fmt.Printf("%8d  %-*.*s%12s%8s%6v\n", entry.Id, titleWidth, titleWidth,
+			entry.Title.UserPreferred,
+			alStatusString(entry.Status, al.MediaType),
+			fmt.Sprintf("%d/%d", entry.Progress, entry.Length()),
+			entry.Score)
+	}
+}
+
+// Asks a yes/no question on stdin, defaulting to no
+func confirm(question string) bool {
+	fmt.Print(question, " [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
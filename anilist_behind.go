@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aqatl/mal/anilist"
+	"github.com/fatih/color"
+	"github.com/hako/durafmt"
+	"github.com/urfave/cli"
+)
+
+var behindStatuses = []anilist.MediaListStatus{anilist.Current, anilist.Paused}
+
+// Entry with aired episodes that haven't been watched yet
+type behindEntry struct {
+	Entry  anilist.MediaListEntry
+	Aired  int
+	Behind int
+	// Time needed to catch up, in minutes
+	CatchUp int
+}
+
+// Number of episodes aired so far. False if it can't be told, e.g. for a show on hiatus
+func airedEpisodes(media anilist.MediaFull) (int, bool) {
+	if next := media.NextAiringEpisode; next.Episode > 0 {
+		return next.Episode - 1, true
+	}
+	switch media.Status {
+	case "FINISHED":
+		return media.Episodes, media.Episodes > 0
+	case "NOT_YET_RELEASED":
+		return 0, true
+	}
+	return 0, false
+}
+
+// Entries of the list that are behind, the biggest backlog first
+func alBehindEntries(list List, media []anilist.MediaFull) []behindEntry {
+	mediaById := make(map[int]anilist.MediaFull, len(media))
+	for _, m := range media {
+		mediaById[m.Id] = m
+	}
+
+	behind := make([]behindEntry, 0)
+	for _, entry := range list {
+		m, ok := mediaById[entry.Id]
+		if !ok {
+			continue
+		}
+		aired, ok := airedEpisodes(m)
+		if !ok || aired <= entry.Progress {
+			continue
+		}
+		duration := m.Duration
+		if duration == 0 {
+			duration = entry.Duration
+		}
+		behind = append(behind, behindEntry{
+			Entry:   entry,
+			Aired:   aired,
+			Behind:  aired - entry.Progress,
+			CatchUp: (aired - entry.Progress) * duration,
+		})
+	}
+	sort.SliceStable(behind, func(i, j int) bool {
+		if behind[i].Behind != behind[j].Behind {
+			return behind[i].Behind > behind[j].Behind
+		}
+		return behind[i].Entry.Title.UserPreferred < behind[j].Entry.Title.UserPreferred
+	})
+	return behind
+}
+
+func alQueryBehindEntries(al *AniList, list List, animation bool) ([]behindEntry, error) {
+	ids := make([]int, len(list))
+	for i, entry := range list {
+		ids[i] = entry.Id
+	}
+	query := al.Client().QueryNextAiringEpisodes
+	if animation {
+		query = al.Client().QueryNextAiringEpisodesWaitAnimation
+	}
+	media, err := query(ids)
+	if err != nil {
+		return nil, err
+	}
+	return alBehindEntries(list, media), nil
+}
+
+func alBehind(ctx *cli.Context) error {
+	al, err := loadAniListOfType(ctx, anilist.Anime)
+	if err != nil {
+		return err
+	}
+	list := make(List, 0)
+	for _, status := range behindStatuses {
+		list = append(list, alGetList(al, status)...)
+	}
+	behind, err := alQueryBehindEntries(al, list, true)
+	if err != nil {
+		return err
+	}
+	if len(behind) == 0 {
+		fmt.Println("You're up to date with everything you watch")
+		return nil
+	}
+
+	cfg := LoadConfig()
+	red := color.New(color.FgHiRed).SprintFunc()
+	cyan := color.New(color.FgHiCyan).SprintFunc()
+	faint := color.New(color.Faint).SprintFunc()
+
+	numberFieldWidth := 3
+	titleWidth := cfg.ListWidth - numberFieldWidth - 8 - 7 - 10
+	fmt.Printf("%*s%*.*s%8s%7s%10s\n",
+		numberFieldWidth, "No", titleWidth, titleWidth, "Title", "Eps", "Behind", "Time")
+	fmt.Println(strings.Repeat("=", cfg.ListWidth))
+
+	episodes, minutes := 0, 0
+	for i, b := range behind {
+		episodes += b.Behind
+		minutes += b.CatchUp
+		line := fmt.Sprintf("%*d%*.*s%8s%s%10s", numberFieldWidth, i+1, titleWidth, titleWidth,
+			b.Entry.Title.UserPreferred,
+			fmt.Sprintf("%d/%d", b.Entry.Progress, b.Aired),
+			red(fmt.Sprintf("%+7d", b.Behind)),
+			formatCatchUpTime(b.CatchUp))
+		if b.Entry.Status == anilist.Paused {
+			line += " " + faint("(paused)")
+		}
+		fmt.Fprintln(color.Output, line)
+	}
+
+	fmt.Println()
+	fmt.Fprintf(color.Output, "%s episodes behind, %s to catch up\n",
+		red(episodes), cyan(durafmt.Parse(time.Duration(minutes)*time.Minute)))
+	return nil
+}
+
+// Short form of catch-up time fitting in a table column, e.g. 3h12m
+func formatCatchUpTime(minutes int) string {
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh%02dm", minutes/60, minutes%60)
+}
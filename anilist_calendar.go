@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aqatl/mal/anilist"
+	"github.com/fatih/color"
+	"github.com/urfave/cli"
+)
+
+// Episode airing within the calendar time span
+type calendarEpisode struct {
+	MediaId    int                     `json:"mediaId"`
+	Title      string                  `json:"title"`
+	Episode    int                     `json:"episode"`
+	Episodes   int                     `json:"episodes,omitempty"`
+	Duration   int                     `json:"duration,omitempty"`
+	AiringAt   time.Time               `json:"airingAt"`
+	ListStatus anilist.MediaListStatus `json:"listStatus"`
+	Progress   int                     `json:"progress"`
+	// The episode has already aired and you haven't watched it yet
+	Behind bool `json:"behind"`
+}
+
+var calendarStatuses = []anilist.MediaListStatus{anilist.Current, anilist.Repeating, anilist.Planning}
+
+func calendarLocation(tz string) (*time.Location, error) {
+	if tz == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q; use e.g. Europe/Warsaw or UTC", tz)
+	}
+	return loc, nil
+}
+
+// Start of the day of now in loc, and the end of the span of given days
+func calendarSpan(now time.Time, loc *time.Location, days int) (time.Time, time.Time) {
+	now = now.In(loc)
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	return from, from.AddDate(0, 0, days)
+}
+
+func alCalendarEpisodes(list List, schedules []anilist.AiringSchedule, loc *time.Location, now time.Time) []calendarEpisode {
+	episodes := make([]calendarEpisode, 0, len(schedules))
+	for _, schedule := range schedules {
+		entry := list.GetMediaListById(schedule.MediaId)
+		if entry == nil {
+			continue
+		}
+		airingAt := time.Unix(int64(schedule.AiringAt), 0).In(loc)
+		isWatching := entry.Status == anilist.Current || entry.Status == anilist.Repeating
+		episodes = append(episodes, calendarEpisode{
+			MediaId:    entry.Id,
+			Title:      entry.Title.UserPreferred,
+			Episode:    schedule.Episode,
+			Episodes:   entry.Episodes,
+			Duration:   entry.Duration,
+			AiringAt:   airingAt,
+			ListStatus: entry.Status,
+			Progress:   entry.Progress,
+			Behind:     isWatching && !airingAt.After(now) && entry.Progress < schedule.Episode,
+		})
+	}
+	return episodes
+}
+
+// Queries episodes of watched and planned anime airing in the days starting today
+func alQueryCalendar(al *AniList, loc *time.Location, days int, animation bool) (
+	[]calendarEpisode, error,
+) {
+	ids := make([]int, 0)
+	for _, status := range calendarStatuses {
+		for _, entry := range alGetList(al, status) {
+			ids = append(ids, entry.Id)
+		}
+	}
+
+	now := time.Now()
+	from, to := calendarSpan(now, loc, days)
+	query := al.Client().QueryAiringSchedules
+	if animation {
+		query = al.Client().QueryAiringSchedulesWaitAnimation
+	}
+	schedules, err := query(ids, int(from.Unix())-1, int(to.Unix()))
+	if err != nil {
+		return nil, err
+	}
+	return alCalendarEpisodes(al.List, schedules, loc, now), nil
+}
+
+func alCalendar(ctx *cli.Context) error {
+	days := ctx.Int("days")
+	if days < 1 {
+		return fmt.Errorf("--days must be at least 1")
+	}
+	loc, err := calendarLocation(ctx.String("tz"))
+	if err != nil {
+		return err
+	}
+	al, err := loadAniListOfType(ctx, anilist.Anime)
+	if err != nil {
+		return err
+	}
+
+	asJson := ctx.Bool("json")
+	// The wait animation would end up in the json
+	episodes, err := alQueryCalendar(al, loc, days, !asJson)
+	if err != nil {
+		return err
+	}
+
+	if asJson {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		return enc.Encode(episodes)
+	}
+	from, _ := calendarSpan(time.Now(), loc, days)
+	alPrintCalendar(episodes, from, days)
+	return nil
+}
+
+func alPrintCalendar(episodes []calendarEpisode, from time.Time, days int) {
+	yellow := color.New(color.FgHiYellow).SprintFunc()
+	red := color.New(color.FgHiRed).SprintFunc()
+	cyan := color.New(color.FgHiCyan).SprintFunc()
+	faint := color.New(color.Faint).SprintFunc()
+
+	behind := 0
+	for day := 0; day < days; day++ {
+		dayStart := from.AddDate(0, 0, day)
+		dayEnd := dayStart.AddDate(0, 0, 1)
+
+		header := dayStart.Format("Monday 02-01")
+		if day == 0 {
+			header += " (today)"
+		}
+		fmt.Fprintln(color.Output, yellow(header))
+		fmt.Fprintln(color.Output, yellow(strings.Repeat("-", len(header))))
+
+		empty := true
+		for _, ep := range episodes {
+			if ep.AiringAt.Before(dayStart) || !ep.AiringAt.Before(dayEnd) {
+				continue
+			}
+			empty = false
+
+			episode := fmt.Sprintf("ep %d", ep.Episode)
+			if ep.Episodes > 0 {
+				episode += fmt.Sprintf("/%d", ep.Episodes)
+			}
+			line := fmt.Sprintf("  %s  %-9s %s", cyan(ep.AiringAt.Format("15:04")), episode, ep.Title)
+			switch {
+			case ep.Behind:
+				behind++
+				line += " " + red(fmt.Sprintf("(behind, watched %d)", ep.Progress))
+			case ep.ListStatus == anilist.Planning:
+				line += " " + faint("(planning)")
+			}
+			fmt.Fprintln(color.Output, line)
+		}
+		if empty {
+			fmt.Fprintln(color.Output, faint("  nothing airing"))
+		}
+		fmt.Println()
+	}
+
+	fmt.Fprintf(color.Output, "Times in %s", cyan(from.Location()))
+	if behind > 0 {
+		fmt.Fprintf(color.Output, ", %s aired episodes to catch up on", red(behind))
+	}
+	fmt.Println()
+}
+
+func alCalendarExport(ctx *cli.Context) error {
+	path := ctx.String("ics")
+	if path == "" {
+		return fmt.Errorf("no output file given; use --ics <file>, or --ics - for stdout")
+	}
+	days := ctx.Int("days")
+	if days < 1 {
+		return fmt.Errorf("--days must be at least 1")
+	}
+	al, err := loadAniListOfType(ctx, anilist.Anime)
+	if err != nil {
+		return err
+	}
+
+	toStdout := path == "-"
+	episodes, err := alQueryCalendar(al, time.Local, days, !toStdout)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if !toStdout {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	if err = writeICalendar(out, episodes, time.Now()); err != nil {
+		return err
+	}
+
+	if !toStdout {
+		fmt.Fprintf(color.Output, "Exported %s episodes to %s\n",
+			color.HiCyanString("%d", len(episodes)), color.HiYellowString("%s", path))
+	}
+	return nil
+}
+
+const (
+	icsDateTimeLayout = "20060102T150405Z"
+	icsMaxLineLength  = 75
+	aniListAnimeUrl   = anilist.ALDomain + "/anime/%d"
+)
+
+var icsTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// Writes episodes as an RFC 5545 calendar. UIDs depend only on the anime and the episode number,
+// so importing a newer export updates events instead of duplicating them
+func writeICalendar(w io.Writer, episodes []calendarEpisode, now time.Time) error {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//aqatl//mal//EN",
+		"CALSCALE:GREGORIAN",
+		"X-WR-CALNAME:Anime airing schedule",
+	}
+	stamp := now.UTC().Format(icsDateTimeLayout)
+	for _, ep := range episodes {
+		url := fmt.Sprintf(aniListAnimeUrl, ep.MediaId)
+		episode := fmt.Sprintf("Episode %d", ep.Episode)
+		if ep.Episodes > 0 {
+			episode += fmt.Sprintf("/%d", ep.Episodes)
+		}
+
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			fmt.Sprintf("UID:anilist-%d-ep%d@mal", ep.MediaId, ep.Episode),
+			"DTSTAMP:"+stamp,
+			"DTSTART:"+ep.AiringAt.UTC().Format(icsDateTimeLayout),
+		)
+		if ep.Duration > 0 {
+			end := ep.AiringAt.Add(time.Duration(ep.Duration) * time.Minute)
+			lines = append(lines, "DTEND:"+end.UTC().Format(icsDateTimeLayout))
+		}
+		lines = append(lines,
+			"SUMMARY:"+icsTextEscaper.Replace(fmt.Sprintf("%s - Episode %d", ep.Title, ep.Episode)),
+			"DESCRIPTION:"+icsTextEscaper.Replace(episode+" of "+ep.Title+"\n"+url),
+			"URL:"+url,
+			"TRANSP:TRANSPARENT",
+			"END:VEVENT",
+		)
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	for _, line := range lines {
+		if _, err := io.WriteString(w, foldICalendarLine(line)+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Splits lines longer than 75 octets, continuation lines start with a space.
+// Multi-byte characters are never split
+func foldICalendarLine(line string) string {
+	var folded strings.Builder
+	limit := icsMaxLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		folded.WriteString(line[:cut])
+		folded.WriteString("\r\n ")
+		line = line[cut:]
+		// The leading space counts towards the limit
+		limit = icsMaxLineLength - 1
+	}
+	folded.WriteString(line)
+	return folded.String()
+}
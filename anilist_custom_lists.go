@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aqatl/mal/anilist"
+	"github.com/fatih/color"
+	"github.com/urfave/cli"
+)
+
+// Names of the user's custom lists. Every entry knows about all of them,
+// so the names are collected from entries of the list.
+func alCustomListNames(list List) []string {
+	names := make([]string, 0)
+	known := make(map[string]bool)
+	for i := range list {
+		for _, customList := range list[i].CustomLists {
+			if !known[customList.Name] {
+				names = append(names, customList.Name)
+				known[customList.Name] = true
+			}
+		}
+	}
+	return names
+}
+
+// Finds a custom list by its name, ignoring case. Unambiguous prefixes are accepted too
+func alFindCustomList(list List, name string) (string, error) {
+	names := alCustomListNames(list)
+	if len(names) == 0 {
+		return "", fmt.Errorf("no custom lists found; if you have any, refresh the list with `mal -r`")
+	}
+
+	matches := make([]string, 0)
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return n, nil
+		}
+		if strings.HasPrefix(strings.ToLower(n), strings.ToLower(name)) {
+			matches = append(matches, n)
+		}
+	}
+	switch {
+	case len(matches) == 1:
+		return matches[0], nil
+	case len(matches) > 1:
+		return "", fmt.Errorf("ambiguous custom list name %q matches: %s", name, strings.Join(matches, ", "))
+	}
+	return "", fmt.Errorf("no custom list named %q; available lists: %s", name, strings.Join(names, ", "))
+}
+
+func alGetCustomList(list List, name string) List {
+	customList := make(List, 0)
+	for i := range list {
+		for _, membership := range list[i].CustomLists {
+			if membership.Name == name && membership.Enabled {
+				customList = append(customList, list[i])
+				break
+			}
+		}
+	}
+	return customList
+}
+
+func alCustomLists(ctx *cli.Context) error {
+	al, err := loadAniList(ctx)
+	if err != nil {
+		return err
+	}
+	cfg := LoadConfig()
+
+	names := alCustomListNames(al.List)
+	if len(names) == 0 {
+		fmt.Println("No custom lists found; if you have any, refresh the list with `mal -r`")
+		return nil
+	}
+
+	selected := al.GetMediaListById(cfg.ALSelected(al.MediaType))
+	inSelected := make(map[string]bool)
+	if selected != nil {
+		for _, name := range selected.CustomListNames() {
+			inSelected[name] = true
+		}
+	}
+
+	for _, name := range names {
+		line := fmt.Sprintf("%s (%d)", name, len(alGetCustomList(al.List, name)))
+		if inSelected[name] {
+			color.HiYellow("* %s", line)
+		} else {
+			fmt.Println("  " + line)
+		}
+	}
+	if selected != nil {
+		fmt.Fprintf(color.Output, "\n%s %s\n", color.HiYellowString("*"),
+			"lists containing "+selected.Title.UserPreferred)
+	}
+	return nil
+}
+
+func alCustomListAdd(ctx *cli.Context) error {
+	return alSetCustomListMembership(ctx, true)
+}
+
+func alCustomListRemove(ctx *cli.Context) error {
+	return alSetCustomListMembership(ctx, false)
+}
+
+func alSetCustomListMembership(ctx *cli.Context, enabled bool) error {
+	name := strings.Join(ctx.Args(), " ")
+	if name == "" {
+		return fmt.Errorf("no custom list name given")
+	}
+	al, entry, _, err := loadAniListFull(ctx)
+	if err != nil {
+		return err
+	}
+	if name, err = alFindCustomList(al.List, name); err != nil {
+		return err
+	}
+	if entry.CustomLists == nil {
+		return fmt.Errorf("custom lists of the entry are unknown; refresh the list with `mal -r`")
+	}
+
+	updated := *entry
+	updated.CustomLists = make([]anilist.CustomList, 0, len(entry.CustomLists)+1)
+	found := false
+	for _, customList := range entry.CustomLists {
+		if customList.Name == name {
+			if customList.Enabled == enabled {
+				fmt.Println("Nothing to change")
+				return nil
+			}
+			customList.Enabled = enabled
+			found = true
+		}
+		updated.CustomLists = append(updated.CustomLists, customList)
+	}
+	if !found {
+		updated.CustomLists = append(updated.CustomLists, anilist.CustomList{Name: name, Enabled: enabled})
+	}
+
+	queued, err := alSaveEntry(al, &updated)
+	if err != nil {
+		return err
+	}
+	*entry = updated
+	if err = saveAniListLists(al); err != nil {
+		return err
+	}
+
+	if !queued {
+		fmt.Println("Updated successfully")
+	}
+	alPrintEntryDetails(entry, al.User.MediaListOptions.ScoreFormat)
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aqatl/mal/anilist"
+	"github.com/urfave/cli"
+)
+
+func todayFuzzyDate() *anilist.FuzzyDate {
+	now := time.Now()
+	return &anilist.FuzzyDate{Year: now.Year(), Month: int(now.Month()), Day: now.Day()}
+}
+
+// Parses yyyy, yyyy-mm, yyyy-mm-dd or "today". "none" gives an empty date
+func parseFuzzyDate(date string) (anilist.FuzzyDate, error) {
+	switch strings.ToLower(date) {
+	case "today":
+		return *todayFuzzyDate(), nil
+	case "none", "clear":
+		return anilist.FuzzyDate{}, nil
+	}
+
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		t, err := time.Parse(layout, date)
+		if err != nil {
+			continue
+		}
+		parsed := anilist.FuzzyDate{Year: t.Year()}
+		if len(layout) > 4 {
+			parsed.Month = int(t.Month())
+		}
+		if len(layout) > 7 {
+			parsed.Day = t.Day()
+		}
+		return parsed, nil
+	}
+	return anilist.FuzzyDate{}, fmt.Errorf("invalid date %q; use yyyy-mm-dd, yyyy-mm, yyyy, today or none", date)
+}
+
+// Fills in the start date of entries that became current and the finish date of completed ones
+func alAutoFillDates(entry *anilist.MediaListEntry) {
+	if entry.StartedAt == nil || entry.CompletedAt == nil {
+		return
+	}
+	switch entry.Status {
+	case anilist.Current:
+		if entry.StartedAt.IsZero() {
+			entry.StartedAt = todayFuzzyDate()
+		}
+	case anilist.Completed:
+		if entry.CompletedAt.IsZero() {
+			entry.CompletedAt = todayFuzzyDate()
+		}
+	}
+}
+
+// Loads the selected entry, making sure it was fetched with dates, notes and privacy
+func loadAniListFullWithFields(ctx *cli.Context) (*AniList, *anilist.MediaListEntry, error) {
+	al, entry, _, err := loadAniListFull(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if entry.StartedAt == nil || entry.CompletedAt == nil {
+		return nil, nil, fmt.Errorf("dates and notes of the entry are unknown; refresh the list with `mal -r`")
+	}
+	return al, entry, nil
+}
+
+func alSaveAndPrintEntry(al *AniList, entry *anilist.MediaListEntry) error {
+	queued, err := alSaveEntry(al, entry)
+	if err != nil {
+		return err
+	}
+	if err = saveAniListLists(al); err != nil {
+		return err
+	}
+
+	if !queued {
+		fmt.Println("Updated successfully")
+	}
+	alPrintEntryDetails(entry, al.User.MediaListOptions.ScoreFormat)
+	return nil
+}
+
+func alSetEntryStartDate(ctx *cli.Context) error {
+	return alSetEntryDate(ctx, func(entry *anilist.MediaListEntry, date *anilist.FuzzyDate) {
+		entry.StartedAt = date
+	})
+}
+
+func alSetEntryFinishDate(ctx *cli.Context) error {
+	return alSetEntryDate(ctx, func(entry *anilist.MediaListEntry, date *anilist.FuzzyDate) {
+		entry.CompletedAt = date
+	})
+}
+
+func alSetEntryDate(ctx *cli.Context, set func(*anilist.MediaListEntry, *anilist.FuzzyDate)) error {
+	arg := ctx.Args().First()
+	if arg == "" {
+		arg = "today"
+	}
+	date, err := parseFuzzyDate(arg)
+	if err != nil {
+		return err
+	}
+
+	al, entry, err := loadAniListFullWithFields(ctx)
+	if err != nil {
+		return err
+	}
+	set(entry, &date)
+	return alSaveAndPrintEntry(al, entry)
+}
+
+func alSetEntryNotes(ctx *cli.Context) error {
+	al, entry, err := loadAniListFullWithFields(ctx)
+	if err != nil {
+		return err
+	}
+
+	notes := strings.Join(ctx.Args(), " ")
+	if notes == "" && !ctx.Bool("clear") {
+		if entry.Notes == "" {
+			fmt.Println("No notes")
+		} else {
+			fmt.Println(entry.Notes)
+		}
+		return nil
+	}
+	entry.Notes = notes
+	return alSaveAndPrintEntry(al, entry)
+}
+
+func alSetEntryRepeat(ctx *cli.Context) error {
+	al, entry, _, err := loadAniListFull(ctx)
+	if err != nil {
+		return err
+	}
+
+	if arg := ctx.Args().First(); arg != "" {
+		repeat, err := strconv.Atoi(arg)
+		if err != nil || repeat < 0 {
+			return fmt.Errorf("invalid repeat count %q", arg)
+		}
+		entry.Repeat = repeat
+	} else {
+		entry.Repeat++
+	}
+	return alSaveAndPrintEntry(al, entry)
+}
+
+func alSetEntryPrivate(ctx *cli.Context) error {
+	var private bool
+	switch arg := strings.ToLower(ctx.Args().First()); arg {
+	case "", "on", "yes", "true":
+		private = true
+	case "off", "no", "false":
+		private = false
+	default:
+		return fmt.Errorf("invalid value %q; use on or off", arg)
+	}
+
+	al, entry, err := loadAniListFullWithFields(ctx)
+	if err != nil {
+		return err
+	}
+	entry.Private = private
+	if err = alSaveAndPrintEntry(al, entry); err != nil {
+		return err
+	}
+	if !entry.Private {
+		fmt.Println("Entry is public")
+	}
+	return nil
+}
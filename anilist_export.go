@@ -0,0 +1,799 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aqatl/mal/anilist"
+	"github.com/fatih/color"
+	"github.com/urfave/cli"
+)
+
+type ExportFormat string
+
+const (
+	MalXmlFormat ExportFormat = "mal-xml"
+	CsvFormat    ExportFormat = "csv"
+	JsonFormat   ExportFormat = "json"
+)
+
+// Returns format given with the --format flag, or guesses it from the file extension
+func exportFormat(ctx *cli.Context, path string) (ExportFormat, error) {
+	format := ctx.String("format")
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			return CsvFormat, nil
+		case ".json":
+			return JsonFormat, nil
+		default:
+			return MalXmlFormat, nil
+		}
+	}
+	switch f := ExportFormat(strings.ToLower(format)); f {
+	case MalXmlFormat, CsvFormat, JsonFormat:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid format %q; available formats: mal-xml, csv, json", format)
+}
+
+func alExport(ctx *cli.Context) error {
+	al, err := loadAniList(ctx)
+	if err != nil {
+		return err
+	}
+	path := ctx.Args().First()
+	format, err := exportFormat(ctx, path)
+	if err != nil {
+		return err
+	}
+	if al.List, err = alQueryList(ctx, al.List, nil); err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch format {
+	case MalXmlFormat:
+		skipped, err := exportMalXml(out, al)
+		if err != nil {
+			return err
+		}
+		if skipped > 0 {
+			fmt.Fprintf(color.Error, "Skipped %s entries not available on MyAnimeList\n",
+				color.HiRedString("%d", skipped))
+		}
+	case CsvFormat:
+		err = exportCsv(out, al.List, al.User.MediaListOptions.ScoreFormat)
+	case JsonFormat:
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "\t")
+		err = enc.Encode(jsonExport{ScoreFormat: al.User.MediaListOptions.ScoreFormat, Entries: al.List})
+	}
+	if err != nil {
+		return err
+	}
+
+	if path != "" {
+		fmt.Fprintf(color.Output, "Exported %s entries to %s\n",
+			color.HiCyanString("%d", len(al.List)), color.HiYellowString("%s", path))
+	}
+	return nil
+}
+
+// Document format of the MyAnimeList list export, accepted by MAL and AniList importers
+type malExport struct {
+	XMLName xml.Name         `xml:"myanimelist"`
+	MyInfo  malExportInfo    `xml:"myinfo"`
+	Anime   []malExportEntry `xml:"anime"`
+	Manga   []malExportEntry `xml:"manga"`
+}
+
+type malExportInfo struct {
+	UserName string `xml:"user_name"`
+	// 1 for anime list, 2 for manga list
+	ExportType int `xml:"user_export_type"`
+}
+
+type malCData struct {
+	Value string `xml:",cdata"`
+}
+
+// Anime and manga entries share most of the fields, the ones specific to one type
+// are omitted for the other
+type malExportEntry struct {
+	AnimeId       int       `xml:"series_animedb_id,omitempty"`
+	AnimeTitle    *malCData `xml:"series_title,omitempty"`
+	AnimeType     string    `xml:"series_type,omitempty"`
+	AnimeEpisodes *int      `xml:"series_episodes,omitempty"`
+
+	MangaId       int       `xml:"manga_mangadb_id,omitempty"`
+	MangaTitle    *malCData `xml:"manga_title,omitempty"`
+	MangaVolumes  *int      `xml:"manga_volumes,omitempty"`
+	MangaChapters *int      `xml:"manga_chapters,omitempty"`
+
+	MyId              int    `xml:"my_id"`
+	MyWatchedEpisodes *int   `xml:"my_watched_episodes,omitempty"`
+	MyReadVolumes     *int   `xml:"my_read_volumes,omitempty"`
+	MyReadChapters    *int   `xml:"my_read_chapters,omitempty"`
+	MyStartDate       string `xml:"my_start_date"`
+	MyFinishDate      string `xml:"my_finish_date"`
+	MyScore           int    `xml:"my_score"`
+	MyStatus          string `xml:"my_status"`
+	MyTimesWatched    *int   `xml:"my_times_watched,omitempty"`
+	MyRewatching      *int   `xml:"my_rewatching,omitempty"`
+	MyTimesRead       *int   `xml:"my_times_read,omitempty"`
+	UpdateOnImport    int    `xml:"update_on_import"`
+}
+
+const malNoDate = "0000-00-00"
+
+// MyAnimeList uses zeros for unknown parts of a date
+func malDate(date *anilist.FuzzyDate) string {
+	if date == nil || date.IsZero() {
+		return malNoDate
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", date.Year, date.Month, date.Day)
+}
+
+// Parses a date written by malDate; "0000-00-00" and empty dates are unset and give nil
+func parseMalDate(s string) (*anilist.FuzzyDate, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == malNoDate {
+		return nil, nil
+	}
+	date := anilist.FuzzyDate{}
+	if _, err := fmt.Sscanf(s, "%4d-%2d-%2d", &date.Year, &date.Month, &date.Day); err != nil ||
+		date.Month < 0 || date.Month > 12 || date.Day < 0 || date.Day > 31 {
+		return nil, fmt.Errorf("invalid date %q", s)
+	}
+	return &date, nil
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// Writes the list in MyAnimeList export format. Returns number of entries skipped
+// because they don't have a MyAnimeList counterpart.
+func exportMalXml(w io.Writer, al *AniList) (int, error) {
+	doc := malExport{MyInfo: malExportInfo{UserName: al.User.Name, ExportType: 1}}
+	if al.MediaType == anilist.Manga {
+		doc.MyInfo.ExportType = 2
+	}
+
+	skipped := 0
+	scoreFormat := al.User.MediaListOptions.ScoreFormat
+	for _, entry := range al.List {
+		if entry.IdMal == 0 {
+			skipped++
+			continue
+		}
+		e := malExportEntry{
+			MyStartDate:    malDate(entry.StartedAt),
+			MyFinishDate:   malDate(entry.CompletedAt),
+			MyScore:        int(math.Round(float64(toTenPointScore(entry.Score, scoreFormat)))),
+			MyStatus:       malExportStatus(entry.Status, al.MediaType),
+			UpdateOnImport: 1,
+		}
+		if al.MediaType == anilist.Manga {
+			e.MangaId = entry.IdMal
+			e.MangaTitle = &malCData{entry.Title.UserPreferred}
+			e.MangaVolumes = intPtr(entry.Volumes)
+			e.MangaChapters = intPtr(entry.Chapters)
+			e.MyReadVolumes = intPtr(entry.ProgressVolumes)
+			e.MyReadChapters = intPtr(entry.Progress)
+			e.MyTimesRead = intPtr(entry.Repeat)
+			doc.Manga = append(doc.Manga, e)
+		} else {
+			e.AnimeId = entry.IdMal
+			e.AnimeTitle = &malCData{entry.Title.UserPreferred}
+			e.AnimeType = malSeriesType(entry.Format)
+			e.AnimeEpisodes = intPtr(entry.Episodes)
+			e.MyWatchedEpisodes = intPtr(entry.Progress)
+			e.MyTimesWatched = intPtr(entry.Repeat)
+			rewatching := 0
+			if entry.Status == anilist.Repeating {
+				rewatching = 1
+			}
+			e.MyRewatching = intPtr(rewatching)
+			doc.Anime = append(doc.Anime, e)
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return skipped, err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(doc); err != nil {
+		return skipped, err
+	}
+	_, err := io.WriteString(w, "\n")
+	return skipped, err
+}
+
+func malExportStatus(status anilist.MediaListStatus, mediaType anilist.MediaType) string {
+	switch status {
+	case anilist.Current, anilist.Repeating:
+		if mediaType == anilist.Manga {
+			return "Reading"
+		}
+		return "Watching"
+	case anilist.Completed:
+		return "Completed"
+	case anilist.Paused:
+		return "On-Hold"
+	case anilist.Dropped:
+		return "Dropped"
+	default:
+		if mediaType == anilist.Manga {
+			return "Plan to Read"
+		}
+		return "Plan to Watch"
+	}
+}
+
+// Accepts both textual statuses of the export file and numeric ones used by the old MAL API
+func parseMalExportStatus(status string, repeating bool) (anilist.MediaListStatus, error) {
+	switch strings.ToLower(strings.TrimSpace(status)) {
+	case "watching", "reading", "1":
+		if repeating {
+			return anilist.Repeating, nil
+		}
+		return anilist.Current, nil
+	case "completed", "2":
+		return anilist.Completed, nil
+	case "on-hold", "3":
+		return anilist.Paused, nil
+	case "dropped", "4":
+		return anilist.Dropped, nil
+	case "plan to watch", "plan to read", "6":
+		return anilist.Planning, nil
+	}
+	return anilist.All, fmt.Errorf("unknown status %q", status)
+}
+
+func malSeriesType(format string) string {
+	switch format {
+	case "TV", "TV_SHORT":
+		return "TV"
+	case "MOVIE":
+		return "Movie"
+	case "SPECIAL":
+		return "Special"
+	case "OVA", "ONA":
+		return format
+	case "MUSIC":
+		return "Music"
+	}
+	return "Unknown"
+}
+
+func scoreFormatMax(scoreFormat anilist.ScoreFormat) float64 {
+	switch scoreFormat {
+	case anilist.Point100:
+		return 100
+	case anilist.Point5:
+		return 5
+	case anilist.Point3:
+		return 3
+	default:
+		return 10
+	}
+}
+
+func toTenPointScore(score float32, scoreFormat anilist.ScoreFormat) float32 {
+	return float32(float64(score) * 10 / scoreFormatMax(scoreFormat))
+}
+
+func isScoreFormat(f anilist.ScoreFormat) bool {
+	switch f {
+	case anilist.Point100, anilist.Point10Decimal, anilist.Point10, anilist.Point5, anilist.Point3:
+		return true
+	}
+	return false
+}
+
+// Converts score between formats; unknown source format leaves the score as it is
+func convertScore(score float32, from, to anilist.ScoreFormat) float32 {
+	if from == "" || from == to {
+		return score
+	}
+	return fromTenPointScore(toTenPointScore(score, from), to)
+}
+
+// Converts score from the 0-10 scale to given format. Nonzero scores never round down to 0,
+// since 0 means no score.
+func fromTenPointScore(score float32, scoreFormat anilist.ScoreFormat) float32 {
+	converted := float64(score) * scoreFormatMax(scoreFormat) / 10
+	if scoreFormat == anilist.Point10Decimal {
+		converted = math.Round(converted*10) / 10
+	} else {
+		converted = math.Round(converted)
+	}
+	if score > 0 && converted == 0 {
+		converted = 1
+	}
+	return float32(converted)
+}
+
+var csvHeader = []string{
+	"id", "mal_id", "title", "type", "format", "status", "score", "score_format", "progress",
+	"progress_volumes", "repeat", "updated_at",
+}
+
+// Scores are written in scoreFormat, which is recorded in every row so the file
+// can be imported by an account using a different one
+func exportCsv(w io.Writer, list List, scoreFormat anilist.ScoreFormat) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, entry := range list {
+		err := cw.Write([]string{
+			strconv.Itoa(entry.Id),
+			strconv.Itoa(entry.IdMal),
+			entry.Title.UserPreferred,
+			entry.Type,
+			entry.Format,
+			string(entry.Status),
+			strconv.FormatFloat(float64(entry.Score), 'f', -1, 32),
+			string(scoreFormat),
+			strconv.Itoa(entry.Progress),
+			strconv.Itoa(entry.ProgressVolumes),
+			strconv.Itoa(entry.Repeat),
+			strconv.Itoa(entry.UpdatedAt),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func alImport(ctx *cli.Context) error {
+	path := ctx.Args().First()
+	if path == "" {
+		return fmt.Errorf("usage: mal import [--format mal-xml|csv|json] [--dry-run] <file>")
+	}
+	format, err := exportFormat(ctx, path)
+	if err != nil {
+		return err
+	}
+	al, err := loadAniList(ctx)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var imported []anilist.MediaListEntry
+	switch format {
+	case MalXmlFormat:
+		imported, err = readMalXml(f, al.MediaType, al.User.MediaListOptions.ScoreFormat)
+	case CsvFormat:
+		imported, err = readCsv(f, al.User.MediaListOptions.ScoreFormat)
+	case JsonFormat:
+		imported, err = readJson(f, al.User.MediaListOptions.ScoreFormat)
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s: %v", path, err)
+	}
+	for i := range imported {
+		if t := imported[i].Type; t != "" && anilist.MediaType(t) != al.MediaType {
+			return fmt.Errorf("%s contains %s entries, but you're working with your %s list",
+				path, strings.ToLower(t), strings.ToLower(string(al.MediaType)))
+		}
+		imported[i].Type = string(al.MediaType)
+	}
+
+	imported, unresolved, err := resolveMalIds(al, imported)
+	if err != nil {
+		return err
+	}
+
+	changes := diffImport(al.List, imported)
+	yellow := color.New(color.FgHiYellow).SprintFunc()
+	cyan := color.New(color.FgHiCyan).SprintFunc()
+	red := color.New(color.FgHiRed).SprintFunc()
+
+	for _, entry := range unresolved {
+		fmt.Fprintf(color.Output, "%s %s: not found on AniList (MAL id %d)\n",
+			red("!"), yellow(entry.Title.UserPreferred), entry.IdMal)
+	}
+	for _, change := range changes {
+		fmt.Fprintln(color.Output, change.describe(al.User.MediaListOptions.ScoreFormat))
+	}
+	if len(changes) == 0 {
+		fmt.Println("Your list is already up to date")
+		return nil
+	}
+	if ctx.Bool("dry-run") {
+		fmt.Fprintf(color.Output, "\n%s changes, run without %s to apply them\n",
+			cyan(len(changes)), yellow("--dry-run"))
+		return nil
+	}
+
+	applied := 0
+	for _, change := range changes {
+		entry := change.New
+		err := al.Client().SaveMediaListEntryWaitAnimation(&entry)
+		if err != nil {
+			fmt.Fprintf(color.Output, "%s: %v\n", yellow(entry.Title.UserPreferred), err)
+			if isNetworkError(err) {
+				break
+			}
+			continue
+		}
+		applied++
+	}
+
+	fmt.Fprintf(color.Output, "\nApplied %s of %s changes\n", cyan(applied), cyan(len(changes)))
+	if applied == 0 {
+		return nil
+	}
+	return updateAniListLists(al)
+}
+
+// Parses the MyAnimeList export. Entries carry only the MyAnimeList id, scores are converted
+// to scoreFormat.
+func readMalXml(r io.Reader, mediaType anilist.MediaType, scoreFormat anilist.ScoreFormat) (
+	[]anilist.MediaListEntry, error,
+) {
+	doc := malExport{}
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	entries, fileType := doc.Anime, anilist.Anime
+	if len(doc.Manga) > 0 || doc.MyInfo.ExportType == 2 {
+		entries, fileType = doc.Manga, anilist.Manga
+	}
+	if fileType != mediaType {
+		return nil, fmt.Errorf("file contains a %s list, but you're working with your %s list",
+			strings.ToLower(string(fileType)), strings.ToLower(string(mediaType)))
+	}
+
+	imported := make([]anilist.MediaListEntry, 0, len(entries))
+	for _, e := range entries {
+		entry := anilist.MediaListEntry{Score: fromTenPointScore(float32(e.MyScore), scoreFormat)}
+		repeating := false
+		if fileType == anilist.Manga {
+			entry.IdMal = e.MangaId
+			entry.Title.UserPreferred = cdataValue(e.MangaTitle)
+			entry.Progress = intValue(e.MyReadChapters)
+			entry.ProgressVolumes = intValue(e.MyReadVolumes)
+			entry.Repeat = intValue(e.MyTimesRead)
+		} else {
+			entry.IdMal = e.AnimeId
+			entry.Title.UserPreferred = cdataValue(e.AnimeTitle)
+			entry.Progress = intValue(e.MyWatchedEpisodes)
+			entry.Repeat = intValue(e.MyTimesWatched)
+			repeating = intValue(e.MyRewatching) == 1
+		}
+
+		var err error
+		if entry.Status, err = parseMalExportStatus(e.MyStatus, repeating); err != nil {
+			return nil, fmt.Errorf("%s: %v", entry.Title.UserPreferred, err)
+		}
+		if entry.StartedAt, err = parseMalDate(e.MyStartDate); err != nil {
+			return nil, fmt.Errorf("%s: start date: %v", entry.Title.UserPreferred, err)
+		}
+		if entry.CompletedAt, err = parseMalDate(e.MyFinishDate); err != nil {
+			return nil, fmt.Errorf("%s: finish date: %v", entry.Title.UserPreferred, err)
+		}
+		imported = append(imported, entry)
+	}
+	return imported, nil
+}
+
+func cdataValue(c *malCData) string {
+	if c == nil {
+		return ""
+	}
+	return strings.TrimSpace(c.Value)
+}
+
+func intValue(i *int) int {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
+
+// Parses csv written by exportCsv. Columns are looked up by the header, so the ones
+// not needed for importing may be missing. Scores are converted to scoreFormat; without
+// the score_format column they're assumed to be in it already.
+func readCsv(r io.Reader, scoreFormat anilist.ScoreFormat) ([]anilist.MediaListEntry, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("empty file")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.TrimSpace(name)] = i
+	}
+	if _, ok := columns["id"]; !ok {
+		if _, ok := columns["mal_id"]; !ok {
+			return nil, fmt.Errorf("id or mal_id column required")
+		}
+	}
+
+	imported := make([]anilist.MediaListEntry, 0, len(records)-1)
+	for line, record := range records[1:] {
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		number := func(name string) (int, error) {
+			value := field(name)
+			if value == "" {
+				return 0, nil
+			}
+			return strconv.Atoi(value)
+		}
+
+		entry := anilist.MediaListEntry{}
+		entry.Title.UserPreferred = field("title")
+		entry.Type = field("type")
+		entry.Format = field("format")
+		if entry.Status = anilist.ParseStatus(field("status")); entry.Status == anilist.All {
+			return nil, fmt.Errorf("line %d: invalid status %q", line+2, field("status"))
+		}
+		if score := field("score"); score != "" {
+			parsed, err := strconv.ParseFloat(score, 32)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid score %q", line+2, score)
+			}
+			from := anilist.ScoreFormat(strings.ToUpper(field("score_format")))
+			if from != "" && !isScoreFormat(from) {
+				return nil, fmt.Errorf("line %d: invalid score format %q", line+2, field("score_format"))
+			}
+			entry.Score = convertScore(float32(parsed), from, scoreFormat)
+		}
+		for name, dst := range map[string]*int{
+			"id":               &entry.Id,
+			"mal_id":           &entry.IdMal,
+			"progress":         &entry.Progress,
+			"progress_volumes": &entry.ProgressVolumes,
+			"repeat":           &entry.Repeat,
+		} {
+			if *dst, err = number(name); err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s %q", line+2, name, field(name))
+			}
+		}
+		imported = append(imported, entry)
+	}
+	return imported, nil
+}
+
+// Document written by the json export
+type jsonExport struct {
+	// Format of the entries' scores
+	ScoreFormat anilist.ScoreFormat      `json:"scoreFormat"`
+	Entries     []anilist.MediaListEntry `json:"entries"`
+}
+
+// Parses json written by the json export, converting scores to scoreFormat. Files exported
+// before the score format was recorded hold just the list of entries, their scores are
+// assumed to be in scoreFormat.
+func readJson(r io.Reader, scoreFormat anilist.ScoreFormat) ([]anilist.MediaListEntry, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	doc := jsonExport{}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(data, &doc.Entries)
+	} else {
+		err = json.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range doc.Entries {
+		doc.Entries[i].Score = convertScore(doc.Entries[i].Score, doc.ScoreFormat, scoreFormat)
+	}
+	return doc.Entries, nil
+}
+
+// Fills in AniList ids of entries known only by their MyAnimeList id.
+// Returns the resolved entries and the ones that couldn't be found on AniList.
+func resolveMalIds(al *AniList, imported []anilist.MediaListEntry) (
+	resolved, unresolved []anilist.MediaListEntry, err error,
+) {
+	missing := make([]int, 0)
+	for i := range imported {
+		if imported[i].Id != 0 {
+			continue
+		}
+		if entry := al.GetMediaListByMalId(imported[i].IdMal); entry != nil {
+			imported[i].Id = entry.Id
+		} else if imported[i].IdMal != 0 {
+			missing = append(missing, imported[i].IdMal)
+		}
+	}
+
+	if len(missing) > 0 {
+		media, err := al.Client().QueryMediaByMalIds(missing, al.MediaType)
+		if err != nil {
+			return nil, nil, err
+		}
+		byMalId := make(map[int]anilist.MediaDeficient, len(media))
+		for _, m := range media {
+			byMalId[m.IdMal] = m
+		}
+		for i := range imported {
+			if m, ok := byMalId[imported[i].IdMal]; ok && imported[i].Id == 0 {
+				imported[i].MediaDeficient = m
+			}
+		}
+	}
+
+	resolved = make([]anilist.MediaListEntry, 0, len(imported))
+	unresolved = make([]anilist.MediaListEntry, 0)
+	for _, entry := range imported {
+		if entry.Id == 0 {
+			unresolved = append(unresolved, entry)
+		} else {
+			resolved = append(resolved, entry)
+		}
+	}
+	return resolved, unresolved, nil
+}
+
+type importChange struct {
+	// nil for entries not on the list yet
+	Old *anilist.MediaListEntry
+	New anilist.MediaListEntry
+}
+
+// Unset imported date keeps the current one, the file may come from a format without dates
+func importedDate(current, imported *anilist.FuzzyDate) *anilist.FuzzyDate {
+	if imported == nil || imported.IsZero() {
+		return current
+	}
+	return imported
+}
+
+func sameDate(a, b *anilist.FuzzyDate) bool {
+	if a == nil || b == nil {
+		return (a == nil || a.IsZero()) && (b == nil || b.IsZero())
+	}
+	return *a == *b
+}
+
+// Compares imported entries with the list. Entries missing from the import are left alone.
+func diffImport(list List, imported []anilist.MediaListEntry) []importChange {
+	changes := make([]importChange, 0)
+	for _, entry := range imported {
+		if entry.Id == 0 {
+			continue
+		}
+		old := list.GetMediaListById(entry.Id)
+		if old == nil {
+			entry.ListId = 0
+			// Dates are saved only together, a missing one is sent as an empty date
+			if entry.StartedAt != nil || entry.CompletedAt != nil {
+				entry.StartedAt = importedDate(&anilist.FuzzyDate{}, entry.StartedAt)
+				entry.CompletedAt = importedDate(&anilist.FuzzyDate{}, entry.CompletedAt)
+			}
+			changes = append(changes, importChange{New: entry})
+			continue
+		}
+		startedAt := importedDate(old.StartedAt, entry.StartedAt)
+		completedAt := importedDate(old.CompletedAt, entry.CompletedAt)
+		if old.Status == entry.Status && old.Progress == entry.Progress && old.Score == entry.Score &&
+			old.Repeat == entry.Repeat &&
+			sameDate(old.StartedAt, startedAt) && sameDate(old.CompletedAt, completedAt) &&
+			(anilist.MediaType(old.Type) != anilist.Manga || old.ProgressVolumes == entry.ProgressVolumes) {
+			continue
+		}
+
+		updated := *old
+		updated.Status = entry.Status
+		updated.Progress = entry.Progress
+		updated.ProgressVolumes = entry.ProgressVolumes
+		updated.Score = entry.Score
+		updated.Repeat = entry.Repeat
+		updated.StartedAt = startedAt
+		updated.CompletedAt = completedAt
+		changes = append(changes, importChange{Old: old, New: updated})
+	}
+	return changes
+}
+
+func (c importChange) describe(scoreFormat anilist.ScoreFormat) string {
+	yellow := color.New(color.FgHiYellow).SprintFunc()
+	cyan := color.New(color.FgHiCyan).SprintFunc()
+	green := color.New(color.FgHiGreen).SprintFunc()
+
+	n := c.New
+	mediaType := anilist.MediaType(n.Type)
+	if c.Old == nil {
+		added := fmt.Sprintf("%s %s: %s, %s %s, score %s", green("+"), yellow(n.Title.UserPreferred),
+			cyan(alStatusString(n.Status, mediaType)), strings.ToLower(alProgressUnit(&n)),
+			cyan(n.Progress), cyan(formatScore(n.Score, scoreFormat)))
+		if n.StartedAt != nil && !n.StartedAt.IsZero() {
+			added += fmt.Sprintf(", started %s", cyan(n.StartedAt))
+		}
+		if n.CompletedAt != nil && !n.CompletedAt.IsZero() {
+			added += fmt.Sprintf(", completed %s", cyan(n.CompletedAt))
+		}
+		return added
+	}
+
+	o := c.Old
+	diffs := make([]string, 0)
+	if o.Status != n.Status {
+		diffs = append(diffs, fmt.Sprintf("status %s -> %s",
+			alStatusString(o.Status, mediaType), cyan(alStatusString(n.Status, mediaType))))
+	}
+	if o.Progress != n.Progress {
+		diffs = append(diffs, fmt.Sprintf("%s %d -> %s",
+			strings.ToLower(alProgressUnit(&n)), o.Progress, cyan(n.Progress)))
+	}
+	if mediaType == anilist.Manga && o.ProgressVolumes != n.ProgressVolumes {
+		diffs = append(diffs, fmt.Sprintf("volumes %d -> %s", o.ProgressVolumes, cyan(n.ProgressVolumes)))
+	}
+	if o.Score != n.Score {
+		diffs = append(diffs, fmt.Sprintf("score %s -> %s",
+			formatScore(o.Score, scoreFormat), cyan(formatScore(n.Score, scoreFormat))))
+	}
+	if o.Repeat != n.Repeat {
+		diffs = append(diffs, fmt.Sprintf("repeat %d -> %s", o.Repeat, cyan(n.Repeat)))
+	}
+	if !sameDate(o.StartedAt, n.StartedAt) {
+		diffs = append(diffs, fmt.Sprintf("started %s -> %s",
+			dateString(o.StartedAt), cyan(dateString(n.StartedAt))))
+	}
+	if !sameDate(o.CompletedAt, n.CompletedAt) {
+		diffs = append(diffs, fmt.Sprintf("completed %s -> %s",
+			dateString(o.CompletedAt), cyan(dateString(n.CompletedAt))))
+	}
+	return fmt.Sprintf("%s %s: %s", yellow("~"), yellow(n.Title.UserPreferred), strings.Join(diffs, ", "))
+}
+
+func formatScore(score float32, scoreFormat anilist.ScoreFormat) string {
+	if scoreFormat == anilist.Point10Decimal {
+		return strconv.FormatFloat(float64(score), 'f', 1, 32)
+	}
+	return strconv.Itoa(int(score))
+}
+
+func dateString(date *anilist.FuzzyDate) string {
+	if date == nil || date.IsZero() {
+		return "-"
+	}
+	return date.String()
+}
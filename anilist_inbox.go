@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aqatl/mal/anilist"
+	"github.com/fatih/color"
+	"github.com/urfave/cli"
+)
+
+const defaultInboxPageSize = 25
+
+type notificationGroup struct {
+	Name  string
+	Types []anilist.NotificationType
+}
+
+// Groups of notification types, the ones that can be given to --type
+var notificationGroups = []notificationGroup{
+	{"airing", []anilist.NotificationType{anilist.NotificationAiring}},
+	{"follows", []anilist.NotificationType{anilist.NotificationFollowing}},
+	{"activity", []anilist.NotificationType{
+		anilist.NotificationActivityMessage,
+		anilist.NotificationActivityReply,
+		anilist.NotificationActivityMention,
+		anilist.NotificationActivityReplySubscribed,
+	}},
+	{"likes", []anilist.NotificationType{
+		anilist.NotificationActivityLike,
+		anilist.NotificationActivityReplyLike,
+		anilist.NotificationThreadLike,
+		anilist.NotificationThreadCommentLike,
+	}},
+	{"forum", []anilist.NotificationType{
+		anilist.NotificationThreadCommentMention,
+		anilist.NotificationThreadCommentReply,
+		anilist.NotificationThreadSubscribed,
+	}},
+	{"media", []anilist.NotificationType{
+		anilist.NotificationRelatedMediaAddition,
+		anilist.NotificationMediaDataChange,
+		anilist.NotificationMediaMerge,
+		anilist.NotificationMediaDeletion,
+	}},
+}
+
+func notificationGroupNames() []string {
+	names := make([]string, len(notificationGroups))
+	for i, g := range notificationGroups {
+		names[i] = g.Name
+	}
+	return names
+}
+
+// Parses comma separated group names; empty string means all types
+func parseNotificationTypes(s string) ([]anilist.NotificationType, error) {
+	var types []anilist.NotificationType
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, g := range notificationGroups {
+			if g.Name == name {
+				types = append(types, g.Types...)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid notification type %q; possible values: %s",
+				name, strings.Join(notificationGroupNames(), "|"))
+		}
+	}
+	return types, nil
+}
+
+// Notifications are listed newest first, so the first `unread` of them are the unread ones
+func alUnreadNotificationCount(al *AniList) (int, error) {
+	var user anilist.User
+	if err := al.Client().QueryAuthenticatedUser(&user); err != nil {
+		return 0, err
+	}
+	return user.UnreadNotificationCount, nil
+}
+
+func alInbox(ctx *cli.Context) error {
+	types, err := parseNotificationTypes(ctx.String("type"))
+	if err != nil {
+		return err
+	}
+	al, err := loadAniList(ctx)
+	if err != nil {
+		return err
+	}
+	if ctx.Bool("interactive") {
+		return alInboxCui(ctx, al, types)
+	}
+
+	page := ctx.Int("page")
+	if page < 1 {
+		page = 1
+	}
+	perPage := ctx.Int("per-page")
+	if perPage <= 0 {
+		perPage = defaultInboxPageSize
+	}
+	// Unread count covers all types, so it's meaningful only for the unfiltered first page
+	unread := 0
+	if len(types) == 0 && page == 1 {
+		if unread, err = alUnreadNotificationCount(al); err != nil {
+			return err
+		}
+	}
+
+	notifications, hasNextPage, err := al.Client().QueryNotificationsWaitAnimation(
+		page, perPage, types, ctx.Bool("mark-read"))
+	if err != nil {
+		return err
+	}
+
+	if n := ctx.Int("select"); n != 0 {
+		if n < 1 || n > len(notifications) {
+			return fmt.Errorf("no notification number %d on this page", n)
+		}
+		return alJumpToNotification(ctx, notifications[n-1])
+	}
+
+	if len(notifications) == 0 {
+		fmt.Println("No notifications")
+		return nil
+	}
+	for i, n := range notifications {
+		alPrintNotification(i+1, n, i < unread)
+	}
+	if hasNextPage {
+		faint := color.New(color.Faint).SprintFunc()
+		fmt.Fprintln(color.Output, faint(fmt.Sprintf("More on the next page: mal inbox --page %d", page+1)))
+	}
+	return nil
+}
+
+func alPrintNotification(number int, n anilist.Notification, unread bool) {
+	cyan := color.New(color.FgHiCyan).SprintFunc()
+	yellow := color.New(color.FgHiYellow).SprintFunc()
+	faint := color.New(color.Faint).SprintFunc()
+
+	marker := " "
+	if unread {
+		marker = yellow("*")
+	}
+	t := time.Unix(int64(n.CreatedAt), 0).Format("02-01-2006 15:04")
+	line := fmt.Sprintf("%3d %s [%s] %s", number, marker, cyan(t), n.Text())
+	if n.Reason != "" {
+		line += " " + faint("("+n.Reason+")")
+	}
+	fmt.Fprintln(color.Output, line)
+}
+
+// Selects the list entry of the anime or manga the notification is about
+func alJumpToNotification(ctx *cli.Context, n anilist.Notification) error {
+	if n.Media.Id == 0 {
+		return fmt.Errorf("the notification isn't about an anime or manga")
+	}
+	mediaType := n.Media.Type
+	if mediaType == "" {
+		mediaType = anilist.Anime
+	}
+	al, err := loadAniListOfType(ctx, mediaType)
+	if err != nil {
+		return err
+	}
+	entry := al.GetMediaListById(n.Media.Id)
+	if entry == nil {
+		return fmt.Errorf("%s isn't on your list", n.Media.Title.UserPreferred)
+	}
+	alSaveSelection(LoadConfig(), entry, al.User.MediaListOptions.ScoreFormat)
+	return nil
+}
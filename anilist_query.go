@@ -0,0 +1,461 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aqatl/mal/anilist"
+	"github.com/urfave/cli"
+)
+
+// Field of a MediaListEntry usable in --where expressions and --sort keys.
+// Get returns either a float64 or a string
+type queryField struct {
+	Name    string
+	Numeric bool
+	Get     func(entry *anilist.MediaListEntry) interface{}
+}
+
+// Names follow the json representation of MediaListEntry (see `mal export --format json`)
+var queryFields = []*queryField{
+	{"id", true, func(e *anilist.MediaListEntry) interface{} { return float64(e.Id) }},
+	{"idMal", true, func(e *anilist.MediaListEntry) interface{} { return float64(e.IdMal) }},
+	{"title", false, func(e *anilist.MediaListEntry) interface{} { return e.Title.UserPreferred }},
+	{"status", false, func(e *anilist.MediaListEntry) interface{} { return string(e.Status) }},
+	{"score", true, func(e *anilist.MediaListEntry) interface{} { return float64(e.Score) }},
+	{"progress", true, func(e *anilist.MediaListEntry) interface{} { return float64(e.Progress) }},
+	{"progressVolumes", true, func(e *anilist.MediaListEntry) interface{} { return float64(e.ProgressVolumes) }},
+	{"repeat", true, func(e *anilist.MediaListEntry) interface{} { return float64(e.Repeat) }},
+	{"updatedAt", true, func(e *anilist.MediaListEntry) interface{} { return float64(e.UpdatedAt) }},
+	{"format", false, func(e *anilist.MediaListEntry) interface{} { return e.Format }},
+	{"mediaStatus", false, func(e *anilist.MediaListEntry) interface{} { return e.MediaDeficient.Status }},
+	{"season", false, func(e *anilist.MediaListEntry) interface{} { return e.Season }},
+	{"seasonYear", true, func(e *anilist.MediaListEntry) interface{} { return float64(e.SeasonYear) }},
+	{"episodes", true, func(e *anilist.MediaListEntry) interface{} { return float64(e.Episodes) }},
+	{"duration", true, func(e *anilist.MediaListEntry) interface{} { return float64(e.Duration) }},
+	{"chapters", true, func(e *anilist.MediaListEntry) interface{} { return float64(e.Chapters) }},
+	{"volumes", true, func(e *anilist.MediaListEntry) interface{} { return float64(e.Volumes) }},
+	{"length", true, func(e *anilist.MediaListEntry) interface{} { return float64(e.Length()) }},
+}
+
+var queryFieldAliases = map[string]string{
+	"year":         "seasonYear",
+	"updated":      "updatedAt",
+	"last-updated": "updatedAt",
+}
+
+func findQueryField(name string) *queryField {
+	if alias, ok := queryFieldAliases[strings.ToLower(name)]; ok {
+		name = alias
+	}
+	for _, field := range queryFields {
+		if strings.EqualFold(field.Name, name) {
+			return field
+		}
+	}
+	return nil
+}
+
+func queryFieldNames() string {
+	names := make([]string, len(queryFields))
+	for i, field := range queryFields {
+		names[i] = field.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// Parsed --where expression
+type whereExpr interface {
+	eval(entry *anilist.MediaListEntry) bool
+}
+
+type andExpr struct{ left, right whereExpr }
+
+func (e andExpr) eval(entry *anilist.MediaListEntry) bool {
+	return e.left.eval(entry) && e.right.eval(entry)
+}
+
+type orExpr struct{ left, right whereExpr }
+
+func (e orExpr) eval(entry *anilist.MediaListEntry) bool {
+	return e.left.eval(entry) || e.right.eval(entry)
+}
+
+type notExpr struct{ expr whereExpr }
+
+func (e notExpr) eval(entry *anilist.MediaListEntry) bool {
+	return !e.expr.eval(entry)
+}
+
+// Either a field or a literal
+type queryOperand struct {
+	Field   *queryField
+	Literal interface{}
+}
+
+func (o queryOperand) value(entry *anilist.MediaListEntry) interface{} {
+	if o.Field != nil {
+		return o.Field.Get(entry)
+	}
+	return o.Literal
+}
+
+type compareExpr struct {
+	Left, Right queryOperand
+	Op          string
+	Regexp      *regexp.Regexp
+}
+
+func (e compareExpr) eval(entry *anilist.MediaListEntry) bool {
+	left, right := e.Left.value(entry), e.Right.value(entry)
+
+	if e.Regexp != nil {
+		matched := e.Regexp.MatchString(fmt.Sprint(left))
+		return matched == (e.Op == "~")
+	}
+
+	var cmp int
+	lnum, lok := left.(float64)
+	rnum, rok := right.(float64)
+	if lok && rok {
+		if lnum < rnum {
+			cmp = -1
+		} else if lnum > rnum {
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(strings.ToLower(fmt.Sprint(left)), strings.ToLower(fmt.Sprint(right)))
+	}
+
+	switch e.Op {
+	case "=", "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default: // ">="
+		return cmp >= 0
+	}
+}
+
+type queryToken struct {
+	Text   string
+	Quoted bool
+	Pos    int
+}
+
+var queryOperators = []string{"==", "!=", "<=", ">=", "!~", "&&", "||", "=", "<", ">", "~", "!", "(", ")"}
+
+func tokenizeWhere(expr string) ([]queryToken, error) {
+	tokens := make([]queryToken, 0)
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", i+1)
+			}
+			tokens = append(tokens, queryToken{Text: string(runes[i+1 : end]), Quoted: true, Pos: i})
+			i = end + 1
+		default:
+			op := ""
+			for _, o := range queryOperators {
+				if strings.HasPrefix(string(runes[i:]), o) {
+					op = o
+					break
+				}
+			}
+			if op != "" {
+				tokens = append(tokens, queryToken{Text: op, Pos: i})
+				i += len([]rune(op))
+				continue
+			}
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) &&
+				!strings.ContainsRune("=!<>~&|()'\"", runes[end]) {
+				end++
+			}
+			tokens = append(tokens, queryToken{Text: string(runes[i:end]), Pos: i})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type whereParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+// Parses expressions like `score>=8 and format=TV and progress<episodes`.
+// Comparisons (=, !=, <, <=, >, >=, ~ regex match, !~) can be combined with
+// and, or, not (or &&, ||, !) and grouped with parentheses
+func parseWhere(expr string) (whereExpr, error) {
+	tokens, err := tokenizeWhere(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --where expression: %v", err)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("invalid --where expression: empty")
+	}
+	p := &whereParser{tokens: tokens}
+	parsed, err := p.parseOr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = p.errorf("unexpected %q", p.tokens[p.pos].Text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid --where expression: %v", err)
+	}
+	return parsed, nil
+}
+
+func (p *whereParser) errorf(format string, args ...interface{}) error {
+	if p.pos < len(p.tokens) {
+		return fmt.Errorf(format+" at position %d", append(args, p.tokens[p.pos].Pos+1)...)
+	}
+	return fmt.Errorf(format+" at the end", args...)
+}
+
+// Consumes the next token if it's one of the given keywords/operators
+func (p *whereParser) accept(keywords ...string) bool {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].Quoted {
+		return false
+	}
+	for _, keyword := range keywords {
+		if strings.EqualFold(p.tokens[p.pos].Text, keyword) {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *whereParser) parseOr() (whereExpr, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.accept("or", "||") {
+		var right whereExpr
+		if right, err = p.parseAnd(); err == nil {
+			left = orExpr{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *whereParser) parseAnd() (whereExpr, error) {
+	left, err := p.parseUnary()
+	for err == nil && p.accept("and", "&&") {
+		var right whereExpr
+		if right, err = p.parseUnary(); err == nil {
+			left = andExpr{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *whereParser) parseUnary() (whereExpr, error) {
+	if p.accept("not", "!") {
+		expr, err := p.parseUnary()
+		return notExpr{expr}, err
+	}
+	if p.accept("(") {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("missing )")
+		}
+		return expr, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *whereParser) parseComparison() (whereExpr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos >= len(p.tokens) {
+		return nil, p.errorf("missing comparison operator")
+	}
+	op := p.tokens[p.pos].Text
+	if !p.accept("=", "==", "!=", "<", "<=", ">", ">=", "~", "!~") {
+		return nil, p.errorf("expected comparison operator, got %q", op)
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if left.Field == nil && right.Field == nil {
+		return nil, fmt.Errorf("comparison %v %s %v doesn't reference any field; "+
+			"available fields: %s", left.Literal, op, right.Literal, queryFieldNames())
+	}
+
+	// Allow e.g. status=watching or status=plantowatch
+	for _, pair := range [][2]*queryOperand{{&left, &right}, {&right, &left}} {
+		if pair[0].Field != nil && pair[0].Field.Name == "status" && pair[1].Field == nil {
+			if status := anilist.ParseStatus(fmt.Sprint(pair[1].Literal)); status != anilist.All {
+				pair[1].Literal = string(status)
+			}
+		}
+	}
+
+	cmp := compareExpr{Left: left, Right: right, Op: op}
+	if op == "~" || op == "!~" {
+		if right.Field != nil {
+			return nil, fmt.Errorf("right side of %s must be a regular expression", op)
+		}
+		if cmp.Regexp, err = regexp.Compile("(?i)" + fmt.Sprint(right.Literal)); err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %v", err)
+		}
+	}
+	return cmp, nil
+}
+
+func (p *whereParser) parseOperand() (queryOperand, error) {
+	if p.pos >= len(p.tokens) {
+		return queryOperand{}, p.errorf("missing operand")
+	}
+	token := p.tokens[p.pos]
+	if !token.Quoted && strings.ContainsAny(token.Text, "=!<>~&|()") {
+		return queryOperand{}, p.errorf("expected field or value, got %q", token.Text)
+	}
+	p.pos++
+
+	if token.Quoted {
+		return queryOperand{Literal: token.Text}, nil
+	}
+	if field := findQueryField(token.Text); field != nil {
+		return queryOperand{Field: field}, nil
+	}
+	if num, err := strconv.ParseFloat(token.Text, 64); err == nil {
+		return queryOperand{Literal: num}, nil
+	}
+	return queryOperand{Literal: token.Text}, nil
+}
+
+type sortKey struct {
+	Field *queryField
+	Desc  bool
+}
+
+// Parses comma separated sort keys, e.g. "score,title".
+// Numeric fields are sorted descending and text fields ascending by default;
+// prefix a key with - or + to force descending or ascending order
+func parseSortKeys(keys string) ([]sortKey, error) {
+	parsed := make([]sortKey, 0)
+	for _, key := range strings.Split(keys, ",") {
+		if key = strings.TrimSpace(key); key == "" {
+			continue
+		}
+		var forceDesc, forceAsc bool
+		if strings.HasPrefix(key, "-") {
+			key, forceDesc = key[1:], true
+		} else if strings.HasPrefix(key, "+") {
+			key, forceAsc = key[1:], true
+		}
+		field := findQueryField(key)
+		if field == nil {
+			return nil, fmt.Errorf("invalid sort key %q; available fields: %s", key, queryFieldNames())
+		}
+		parsed = append(parsed, sortKey{Field: field, Desc: (field.Numeric || forceDesc) && !forceAsc})
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("no sort keys given")
+	}
+	return parsed, nil
+}
+
+// Sort keys equivalent to the sorting set with `mal cfg sort`
+func configSortKeys(sorting Sorting) []sortKey {
+	var keys string
+	switch sorting {
+	case ByTitle:
+		keys = "title"
+	case ByWatchedEpisodes:
+		keys = "progress"
+	case ByScore:
+		keys = "score"
+	default:
+		keys = "updatedAt"
+	}
+	parsed, _ := parseSortKeys(keys)
+	return parsed
+}
+
+func sortList(list List, keys []sortKey) {
+	sort.SliceStable(list, func(i, j int) bool {
+		for _, key := range keys {
+			a, b := key.Field.Get(&list[i]), key.Field.Get(&list[j])
+			var cmp int
+			if key.Field.Numeric {
+				if a.(float64) < b.(float64) {
+					cmp = -1
+				} else if a.(float64) > b.(float64) {
+					cmp = 1
+				}
+			} else {
+				cmp = strings.Compare(strings.ToLower(a.(string)), strings.ToLower(b.(string)))
+			}
+			if cmp != 0 {
+				return (cmp < 0) != key.Desc
+			}
+		}
+		return false
+	})
+}
+
+// Flags can be given both to the command and to the app itself
+func queryFlag(ctx *cli.Context, name string) string {
+	if value := ctx.String(name); value != "" {
+		return value
+	}
+	return ctx.GlobalString(name)
+}
+
+// Returns a copy of the list with entries matching --where expression,
+// sorted by --sort keys or by the given default keys
+func alQueryList(ctx *cli.Context, list List, defaultSort []sortKey) (List, error) {
+	queried := make(List, 0, len(list))
+	if where := queryFlag(ctx, "where"); where != "" {
+		expr, err := parseWhere(where)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list {
+			if expr.eval(&list[i]) {
+				queried = append(queried, list[i])
+			}
+		}
+	} else {
+		queried = append(queried, list...)
+	}
+
+	keys := defaultSort
+	if sortFlag := queryFlag(ctx, "sort"); sortFlag != "" {
+		var err error
+		if keys, err = parseSortKeys(sortFlag); err != nil {
+			return nil, err
+		}
+	}
+	if keys != nil {
+		sortList(queried, keys)
+	}
+	return queried, nil
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aqatl/mal/anilist"
+	"github.com/fatih/color"
+	"github.com/urfave/cli"
+)
+
+const reviewTopRated = 10
+
+type reviewEntry struct {
+	Title     string
+	Format    string
+	Score     string
+	Length    int
+	Minutes   int
+	Completed anilist.FuzzyDate
+	Url       string
+	// data: URI of the cached cover, empty if it isn't cached
+	Cover template.URL
+
+	score float32
+}
+
+type reviewBinge struct {
+	Entry    reviewEntry
+	Days     int
+	Episodes int
+}
+
+type reviewData struct {
+	Year        int
+	UserName    string
+	Manga       bool
+	Completions []reviewEntry
+	TopRated    []reviewEntry
+	Total       int
+	Minutes     int
+	MeanScore   string
+	Binge       *reviewBinge
+	Genres      template.HTML
+	Months      template.HTML
+	GeneratedAt string
+}
+
+// Date the entry was completed. Entries cached before completion dates were fetched
+// fall back to the time of their last update
+func entryCompletionDate(entry *anilist.MediaListEntry) anilist.FuzzyDate {
+	if entry.CompletedAt != nil && entry.CompletedAt.Year > 0 {
+		return *entry.CompletedAt
+	}
+	if entry.CompletedAt == nil && entry.Status == anilist.Completed {
+		updated := time.Unix(int64(entry.UpdatedAt), 0)
+		return anilist.FuzzyDate{Year: updated.Year(), Month: int(updated.Month())}
+	}
+	return anilist.FuzzyDate{}
+}
+
+// Entries completed in the year, in order of completion
+func reviewCompletions(list List, year int) List {
+	completions := make(List, 0)
+	for i := range list {
+		entry := &list[i]
+		if entry.Status != anilist.Completed && entry.Status != anilist.Repeating {
+			continue
+		}
+		if entryCompletionDate(entry).Year == year {
+			completions = append(completions, *entry)
+		}
+	}
+	sort.SliceStable(completions, func(i, j int) bool {
+		di, dj := entryCompletionDate(&completions[i]), entryCompletionDate(&completions[j])
+		if di.Month != dj.Month {
+			return di.Month < dj.Month
+		}
+		return di.Day < dj.Day
+	})
+	return completions
+}
+
+// Days between two complete dates, both included. False if one of them is incomplete
+func fuzzyDateSpan(from, to *anilist.FuzzyDate) (int, bool) {
+	if from == nil || to == nil || from.Day == 0 || to.Day == 0 || from.Month == 0 || to.Month == 0 {
+		return 0, false
+	}
+	start := time.Date(from.Year, time.Month(from.Month), from.Day, 0, 0, 0, 0, time.UTC)
+	end := time.Date(to.Year, time.Month(to.Month), to.Day, 0, 0, 0, 0, time.UTC)
+	if end.Before(start) {
+		return 0, false
+	}
+	return int(end.Sub(start).Hours()/24) + 1, true
+}
+
+func buildReview(
+	al *AniList,
+	year int,
+	completions List,
+	metadata map[int]mediaMetadata,
+) reviewData {
+	scoreFormat := al.User.MediaListOptions.ScoreFormat
+	review := reviewData{
+		Year:        year,
+		UserName:    al.User.Name,
+		Manga:       al.MediaType == anilist.Manga,
+		Completions: make([]reviewEntry, 0, len(completions)),
+		GeneratedAt: time.Now().Format("2006-01-02"),
+	}
+
+	genres := newBucketCounter()
+	months := newBucketCounter()
+	for month := time.January; month <= time.December; month++ {
+		months.bucket(month.String()[:3])
+	}
+
+	var scoreSum float32
+	scored := 0
+	bingeRate := 0.0
+	for i := range completions {
+		entry := &completions[i]
+		re := reviewEntry{
+			Title:     entry.Title.UserPreferred,
+			Format:    entry.Format,
+			Length:    entry.Length(),
+			Minutes:   entry.Episodes * entry.Duration,
+			Completed: entryCompletionDate(entry),
+			Url:       fmt.Sprintf("%s/%s/%d", anilist.ALDomain, strings.ToLower(entry.Type), entry.Id),
+			Cover:     cachedCoverDataUri(entry.Id),
+			score:     entry.Score,
+		}
+		if entry.Score > 0 {
+			re.Score = formatScore(entry.Score, scoreFormat)
+			scoreSum += entry.Score
+			scored++
+		}
+		review.Completions = append(review.Completions, re)
+		review.Minutes += re.Minutes
+		if re.Completed.Month > 0 {
+			months.add(time.Month(re.Completed.Month).String()[:3], entry)
+		}
+		for _, genre := range metadata[entry.Id].Genres {
+			genres.add(genre, entry)
+		}
+
+		// The binge is the completion watched at the fastest pace
+		if days, ok := fuzzyDateSpan(entry.StartedAt, entry.CompletedAt); ok && re.Length > 1 {
+			if rate := float64(re.Length) / float64(days); rate > bingeRate {
+				bingeRate = rate
+				review.Binge = &reviewBinge{Entry: re, Days: days, Episodes: re.Length}
+			}
+		}
+	}
+	review.Total = len(review.Completions)
+	if scored > 0 {
+		review.MeanScore = strconv.FormatFloat(float64(scoreSum)/float64(scored), 'f', 1, 32)
+	}
+
+	top := make([]reviewEntry, 0)
+	for _, re := range review.Completions {
+		if re.score > 0 {
+			top = append(top, re)
+		}
+	}
+	sort.SliceStable(top, func(i, j int) bool {
+		return top[i].score > top[j].score
+	})
+	if len(top) > reviewTopRated {
+		top = top[:reviewTopRated]
+	}
+	review.TopRated = top
+
+	review.Genres = svgHorizontalBars(sortBucketsByCount(genres.result()), statsChartRows)
+	review.Months = svgColumns(months.result())
+	return review
+}
+
+const (
+	svgBarHeight  = 22
+	svgLabelWidth = 120
+	svgChartWidth = 560
+	svgAccent     = "#3db4f2"
+)
+
+func svgHorizontalBars(buckets []statsBucket, rows int) template.HTML {
+	if len(buckets) > rows {
+		buckets = buckets[:rows]
+	}
+	maxCount := 0
+	for _, b := range buckets {
+		if b.Count > maxCount {
+			maxCount = b.Count
+		}
+	}
+	if maxCount == 0 {
+		return ""
+	}
+
+	barSpace := svgChartWidth - svgLabelWidth - 40
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" role="img">`,
+		svgChartWidth, len(buckets)*svgBarHeight)
+	for i, b := range buckets {
+		y := i * svgBarHeight
+		width := b.Count * barSpace / maxCount
+		fmt.Fprintf(&svg, `<text x="%d" y="%d" text-anchor="end">%s</text>`,
+			svgLabelWidth-8, y+15, html.EscapeString(b.Label))
+		fmt.Fprintf(&svg, `<rect x="%d" y="%d" width="%d" height="%d" rx="3" fill="%s"/>`,
+			svgLabelWidth, y+3, width, svgBarHeight-6, svgAccent)
+		fmt.Fprintf(&svg, `<text x="%d" y="%d">%d</text>`, svgLabelWidth+width+6, y+15, b.Count)
+	}
+	svg.WriteString(`</svg>`)
+	return template.HTML(svg.String())
+}
+
+func svgColumns(buckets []statsBucket) template.HTML {
+	maxCount := 0
+	for _, b := range buckets {
+		if b.Count > maxCount {
+			maxCount = b.Count
+		}
+	}
+	if maxCount == 0 {
+		return ""
+	}
+
+	const height, chartHeight = 180, 140
+	columnWidth := svgChartWidth / len(buckets)
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" role="img">`,
+		svgChartWidth, height)
+	for i, b := range buckets {
+		x := i * columnWidth
+		columnHeight := b.Count * chartHeight / maxCount
+		fmt.Fprintf(&svg, `<rect x="%d" y="%d" width="%d" height="%d" rx="3" fill="%s"/>`,
+			x+6, chartHeight-columnHeight+16, columnWidth-12, columnHeight, svgAccent)
+		if b.Count > 0 {
+			fmt.Fprintf(&svg, `<text x="%d" y="%d" text-anchor="middle">%d</text>`,
+				x+columnWidth/2, chartHeight-columnHeight+12, b.Count)
+		}
+		fmt.Fprintf(&svg, `<text x="%d" y="%d" text-anchor="middle">%s</text>`,
+			x+columnWidth/2, height-4, html.EscapeString(b.Label))
+	}
+	svg.WriteString(`</svg>`)
+	return template.HTML(svg.String())
+}
+
+func coverCacheFile(mediaId int) string {
+	return filepath.Join(AniListCoversDir, strconv.Itoa(mediaId))
+}
+
+// Cover from the local cache as a data: URI, so the page doesn't depend on AniList
+func cachedCoverDataUri(mediaId int) template.URL {
+	data, err := ioutil.ReadFile(coverCacheFile(mediaId))
+	if err != nil {
+		return ""
+	}
+	return template.URL("data:" + http.DetectContentType(data) + ";base64," +
+		base64.StdEncoding.EncodeToString(data))
+}
+
+// Downloads covers of entries that aren't cached yet
+func cacheCovers(list List, metadata map[int]mediaMetadata) error {
+	if err := os.MkdirAll(AniListCoversDir, os.ModePerm); err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	for _, entry := range list {
+		url := metadata[entry.Id].CoverImage
+		file := coverCacheFile(entry.Id)
+		if _, err := os.Stat(file); url == "" || err == nil {
+			continue
+		}
+		if err := downloadFile(client, url, file); err != nil {
+			return fmt.Errorf("downloading cover of %s: %v", entry.Title.UserPreferred, err)
+		}
+	}
+	return nil
+}
+
+func downloadFile(client *http.Client, url, file string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with %s", path.Base(url), resp.Status)
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(file)
+		return err
+	}
+	return f.Close()
+}
+
+func alReview(ctx *cli.Context) error {
+	year := time.Now().Year()
+	if arg := ctx.Args().First(); arg != "" {
+		var err error
+		if year, err = strconv.Atoi(arg); err != nil || year < 1900 {
+			return fmt.Errorf("invalid year %q", arg)
+		}
+	}
+	al, err := loadAniList(ctx)
+	if err != nil {
+		return err
+	}
+
+	completions := reviewCompletions(al.List, year)
+	if len(completions) == 0 {
+		return fmt.Errorf("nothing completed in %d", year)
+	}
+	metadata, err := loadMediaMetadata(al, completions, false, true)
+	if isNetworkError(err) {
+		fmt.Fprintln(color.Error, "AniList is unreachable, genres are left out")
+	} else if err != nil {
+		return err
+	}
+	if ctx.Bool("covers") {
+		if err := cacheCovers(completions, metadata); err != nil {
+			return err
+		}
+	}
+
+	file := ctx.String("output")
+	if file == "" {
+		file = fmt.Sprintf("review-%d.html", year)
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := reviewTemplate.Execute(f, buildReview(al, year, completions, metadata)); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(color.Output, "Saved review of %s completions to %s\n",
+		color.HiCyanString("%d", len(completions)), color.HiYellowString("%s", file))
+	return nil
+}
+
+var reviewTemplate = template.Must(template.New("review").Funcs(template.FuncMap{
+	"hours": func(minutes int) string {
+		return strconv.FormatFloat(float64(minutes)/60, 'f', 1, 64)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.UserName}}'s {{.Year}} in review</title>
+<style>
+body { font-family: sans-serif; background: #0b1622; color: #c9d7e3; margin: 0 auto; max-width: 900px; padding: 24px; }
+h1 { color: #fff; margin-bottom: 4px; }
+h2 { color: #fff; border-bottom: 1px solid #1f2f3f; padding-bottom: 4px; margin-top: 36px; }
+a { color: #3db4f2; text-decoration: none; }
+.summary { display: flex; gap: 16px; flex-wrap: wrap; }
+.card { background: #152232; border-radius: 6px; padding: 12px 18px; }
+.card b { display: block; font-size: 28px; color: #fff; }
+.entries { display: grid; grid-template-columns: repeat(auto-fill, minmax(200px, 1fr)); gap: 12px; }
+.entry { background: #152232; border-radius: 6px; padding: 8px; display: flex; gap: 8px; }
+.entry img { width: 46px; height: 66px; object-fit: cover; border-radius: 3px; }
+.muted { color: #8ba0b2; font-size: 13px; }
+svg text { fill: #c9d7e3; font-size: 12px; }
+ol li { margin: 4px 0; }
+</style>
+</head>
+<body>
+<h1>{{.UserName}}'s {{.Year}} in review</h1>
+<div class="muted">Generated on {{.GeneratedAt}}</div>
+
+<div class="summary">
+	<div class="card"><b>{{.Total}}</b>{{if .Manga}}manga{{else}}anime{{end}} completed</div>
+	{{if not .Manga}}<div class="card"><b>{{hours .Minutes}}</b>hours watched</div>{{end}}
+	{{if .MeanScore}}<div class="card"><b>{{.MeanScore}}</b>mean score</div>{{end}}
+</div>
+
+{{with .Binge}}
+<h2>Longest binge</h2>
+<p><a href="{{.Entry.Url}}">{{.Entry.Title}}</a>: {{.Episodes}} {{if $.Manga}}chapters{{else}}episodes{{end}} in {{.Days}} {{if eq .Days 1}}day{{else}}days{{end}}</p>
+{{end}}
+
+{{if .TopRated}}
+<h2>Top rated</h2>
+<ol>
+{{range .TopRated}}	<li><a href="{{.Url}}">{{.Title}}</a> <span class="muted">{{.Score}}</span></li>
+{{end}}</ol>
+{{end}}
+
+{{if .Months}}
+<h2>Completions by month</h2>
+{{.Months}}
+{{end}}
+
+{{if .Genres}}
+<h2>Genre mix</h2>
+{{.Genres}}
+{{end}}
+
+<h2>Completed</h2>
+<div class="entries">
+{{range .Completions}}	<div class="entry">
+		{{if .Cover}}<img src="{{.Cover}}" alt="">{{end}}
+		<div>
+			<a href="{{.Url}}">{{.Title}}</a>
+			<div class="muted">{{.Format}}{{if .Score}} · scored {{.Score}}{{end}}</div>
+			<div class="muted">{{.Completed}}</div>
+		</div>
+	</div>
+{{end}}</div>
+</body>
+</html>
+`))
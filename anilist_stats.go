@@ -0,0 +1,389 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aqatl/mal/anilist"
+	"github.com/fatih/color"
+)
+
+const (
+	statsChartWidth = 40
+	statsChartRows  = 10
+	// Tags AniList users rank lower are often barely relevant to the media
+	minStatsTagRank = 60
+)
+
+// Media details not included in the list, cached in AniListMediaMetadataFile
+type mediaMetadata struct {
+	Genres       []string
+	Tags         []string
+	AverageScore int
+	CoverImage   string
+}
+
+func newMediaMetadata(media anilist.MediaFull) mediaMetadata {
+	metadata := mediaMetadata{
+		Genres:       media.Genres,
+		Tags:         make([]string, 0),
+		AverageScore: media.AverageScore,
+		CoverImage:   media.CoverImage.Medium,
+	}
+	for _, tag := range media.Tags {
+		if tag.Rank >= minStatsTagRank && !tag.IsGeneralSpoiler && !tag.IsMediaSpoiler {
+			metadata.Tags = append(metadata.Tags, tag.Name)
+		}
+	}
+	return metadata
+}
+
+// Loads metadata of entries of the list, fetching the ones not cached yet
+func loadMediaMetadata(al *AniList, list List, refresh, animation bool) (map[int]mediaMetadata, error) {
+	metadata := make(map[int]mediaMetadata)
+	if !refresh {
+		LoadJsonFile(AniListMediaMetadataFile, &metadata)
+	}
+
+	missing := make([]int, 0)
+	for _, entry := range list {
+		// Metadata cached before covers were fetched is refreshed too,
+		// AniList has a placeholder cover for media without one
+		if m, ok := metadata[entry.Id]; !ok || m.CoverImage == "" {
+			missing = append(missing, entry.Id)
+		}
+	}
+	if len(missing) == 0 {
+		return metadata, nil
+	}
+
+	query := al.Client().QueryMediaByIds
+	if animation {
+		query = al.Client().QueryMediaByIdsWaitAnimation
+	}
+	media, err := query(missing)
+	if err != nil {
+		return metadata, err
+	}
+	for _, m := range media {
+		metadata[m.Id] = newMediaMetadata(m)
+	}
+	return metadata, SaveJsonFile(AniListMediaMetadataFile, metadata)
+}
+
+type statsBucket struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+	// Mean score of scored entries, in the user's score format
+	MeanScore float64 `json:"meanScore,omitempty"`
+	// Time spent watching, anime only
+	Minutes int `json:"minutes,omitempty"`
+
+	scoreSum, scored float64
+}
+
+type statsReport struct {
+	MediaType   anilist.MediaType   `json:"mediaType"`
+	ScoreFormat anilist.ScoreFormat `json:"scoreFormat"`
+	Statuses    []statsBucket       `json:"statuses"`
+	Scores      []statsBucket       `json:"scores"`
+	MeanScore   float64             `json:"meanScore"`
+	// Your and community mean score of entries with both, out of 100
+	ComparedEntries    int           `json:"comparedEntries"`
+	MeanScore100       float64       `json:"meanScore100"`
+	CommunityMeanScore float64       `json:"communityMeanScore"`
+	Formats            []statsBucket `json:"formats"`
+	Seasons            []statsBucket `json:"seasons"`
+	Genres             []statsBucket `json:"genres"`
+	Tags               []statsBucket `json:"tags"`
+	// Entries completed in each month, oldest first
+	Months []statsBucket `json:"months"`
+}
+
+func maxScore(scoreFormat anilist.ScoreFormat) float32 {
+	switch scoreFormat {
+	case anilist.Point100:
+		return 100
+	case anilist.Point5:
+		return 5
+	case anilist.Point3:
+		return 3
+	}
+	return 10
+}
+
+// Minutes spent watching the entry, rewatches included
+func entryWatchTime(entry *anilist.MediaListEntry) int {
+	return (entry.Progress + entry.Repeat*entry.Episodes) * entry.Duration
+}
+
+// Groups values into buckets, keeping the order in which labels show up
+type bucketCounter struct {
+	buckets []statsBucket
+	index   map[string]int
+}
+
+func newBucketCounter(labels ...string) *bucketCounter {
+	c := &bucketCounter{buckets: make([]statsBucket, 0), index: make(map[string]int)}
+	for _, label := range labels {
+		c.bucket(label)
+	}
+	return c
+}
+
+func (c *bucketCounter) bucket(label string) *statsBucket {
+	i, ok := c.index[label]
+	if !ok {
+		i = len(c.buckets)
+		c.index[label] = i
+		c.buckets = append(c.buckets, statsBucket{Label: label})
+	}
+	return &c.buckets[i]
+}
+
+func (c *bucketCounter) add(label string, entry *anilist.MediaListEntry) {
+	b := c.bucket(label)
+	b.Count++
+	b.Minutes += entryWatchTime(entry)
+	if entry.Score > 0 {
+		b.scoreSum += float64(entry.Score)
+		b.scored++
+	}
+}
+
+func (c *bucketCounter) result() []statsBucket {
+	for i := range c.buckets {
+		if b := &c.buckets[i]; b.scored > 0 {
+			b.MeanScore = math.Round(b.scoreSum/b.scored*10) / 10
+		}
+	}
+	return c.buckets
+}
+
+// The biggest buckets first
+func sortBucketsByCount(buckets []statsBucket) []statsBucket {
+	sort.SliceStable(buckets, func(i, j int) bool {
+		if buckets[i].Count != buckets[j].Count {
+			return buckets[i].Count > buckets[j].Count
+		}
+		return buckets[i].Label < buckets[j].Label
+	})
+	return buckets
+}
+
+func scoreBucketLabels(scoreFormat anilist.ScoreFormat) []string {
+	labels := make([]string, 0)
+	if scoreFormat == anilist.Point100 {
+		for i := 0; i < 10; i++ {
+			labels = append(labels, fmt.Sprintf("%d-%d", i*10+1, i*10+10))
+		}
+		return labels
+	}
+	for i := 1; i <= int(maxScore(scoreFormat)); i++ {
+		labels = append(labels, fmt.Sprint(i))
+	}
+	return labels
+}
+
+func scoreBucketLabel(score float32, scoreFormat anilist.ScoreFormat) string {
+	if scoreFormat == anilist.Point100 {
+		bucket := (int(score) - 1) / 10
+		return fmt.Sprintf("%d-%d", bucket*10+1, bucket*10+10)
+	}
+	// Decimal scores fall into the bucket of their integer part
+	if score < 1 {
+		score = 1
+	}
+	return fmt.Sprint(int(score))
+}
+
+var seasonOrder = map[string]int{"WINTER": 0, "SPRING": 1, "SUMMER": 2, "FALL": 3}
+
+// Statistics of the entries. Metadata may be nil, genres, tags and community scores are left out then
+func buildStatsReport(
+	list List,
+	mediaType anilist.MediaType,
+	scoreFormat anilist.ScoreFormat,
+	metadata map[int]mediaMetadata,
+) statsReport {
+	report := statsReport{MediaType: mediaType, ScoreFormat: scoreFormat}
+
+	statuses := newBucketCounter()
+	scores := newBucketCounter(scoreBucketLabels(scoreFormat)...)
+	formats := newBucketCounter()
+	seasons := newBucketCounter()
+	genres := newBucketCounter()
+	tags := newBucketCounter()
+	months := newBucketCounter()
+
+	var scoreSum, scored, score100Sum, communitySum float64
+	for i := range list {
+		entry := &list[i]
+		statuses.add(alStatusString(entry.Status, mediaType), entry)
+		if entry.Score > 0 {
+			scores.add(scoreBucketLabel(entry.Score, scoreFormat), entry)
+			scoreSum += float64(entry.Score)
+			scored++
+		}
+		// Planned entries say nothing about your taste
+		if entry.Status == anilist.Planning {
+			continue
+		}
+
+		if entry.Format != "" {
+			formats.add(entry.Format, entry)
+		}
+		if entry.Season != "" && entry.SeasonYear > 0 {
+			seasons.add(fmt.Sprintf("%s %d", strings.Title(strings.ToLower(entry.Season)), entry.SeasonYear), entry)
+		}
+		if completed := entry.CompletedAt; completed != nil && completed.Year > 0 && completed.Month > 0 &&
+			(entry.Status == anilist.Completed || entry.Status == anilist.Repeating) {
+			months.add(fmt.Sprintf("%04d-%02d", completed.Year, completed.Month), entry)
+		}
+
+		m, ok := metadata[entry.Id]
+		if !ok {
+			continue
+		}
+		for _, genre := range m.Genres {
+			genres.add(genre, entry)
+		}
+		for _, tag := range m.Tags {
+			tags.add(tag, entry)
+		}
+		if entry.Score > 0 && m.AverageScore > 0 {
+			report.ComparedEntries++
+			score100Sum += float64(entry.Score) * 100 / float64(maxScore(scoreFormat))
+			communitySum += float64(m.AverageScore)
+		}
+	}
+
+	if scored > 0 {
+		report.MeanScore = math.Round(scoreSum/scored*100) / 100
+	}
+	if report.ComparedEntries > 0 {
+		report.MeanScore100 = math.Round(score100Sum/float64(report.ComparedEntries)*10) / 10
+		report.CommunityMeanScore = math.Round(communitySum/float64(report.ComparedEntries)*10) / 10
+	}
+
+	report.Statuses = statuses.result()
+	report.Scores = scores.result()
+	report.Formats = sortBucketsByCount(formats.result())
+	report.Genres = sortBucketsByCount(genres.result())
+	report.Tags = sortBucketsByCount(tags.result())
+
+	report.Seasons = seasons.result()
+	sort.SliceStable(report.Seasons, func(i, j int) bool {
+		var si, sj string
+		var yi, yj int
+		fmt.Sscan(report.Seasons[i].Label, &si, &yi)
+		fmt.Sscan(report.Seasons[j].Label, &sj, &yj)
+		if yi != yj {
+			return yi < yj
+		}
+		return seasonOrder[strings.ToUpper(si)] < seasonOrder[strings.ToUpper(sj)]
+	})
+	report.Months = months.result()
+	sort.SliceStable(report.Months, func(i, j int) bool {
+		return report.Months[i].Label < report.Months[j].Label
+	})
+	return report
+}
+
+func alPrintStatsReport(report statsReport) {
+	yellow := color.New(color.FgHiYellow).SprintFunc()
+	cyan := color.New(color.FgHiCyan).SprintFunc()
+
+	scoreDetail := func(b statsBucket) string {
+		if b.MeanScore == 0 {
+			return ""
+		}
+		return fmt.Sprintf("mean score %.1f", b.MeanScore)
+	}
+	timeDetail := func(b statsBucket) string {
+		if report.MediaType == anilist.Manga || b.Minutes == 0 {
+			return ""
+		}
+		return formatCatchUpTime(b.Minutes)
+	}
+
+	fmt.Println()
+	printStatsChart("Scores", report.Scores, nil)
+	if report.MeanScore > 0 {
+		fmt.Fprintf(color.Output, "Mean score: %s\n", yellow(report.MeanScore))
+	}
+	if report.ComparedEntries > 0 {
+		diff := report.MeanScore100 - report.CommunityMeanScore
+		relation := "above"
+		if diff < 0 {
+			relation = "below"
+		}
+		fmt.Fprintf(color.Output, "You score %s points %s the community on average (%s vs %s of 100, %d entries)\n",
+			cyan(fmt.Sprintf("%.1f", math.Abs(diff))), relation,
+			yellow(report.MeanScore100), yellow(report.CommunityMeanScore), report.ComparedEntries)
+	}
+
+	printStatsChart("Formats", report.Formats, scoreDetail)
+	seasons := report.Seasons
+	if len(seasons) > statsChartRows {
+		seasons = seasons[len(seasons)-statsChartRows:]
+	}
+	printStatsChart("Recent seasons", seasons, scoreDetail)
+	printStatsChart("Genres", report.Genres, scoreDetail)
+	printStatsChart("Tags", report.Tags, scoreDetail)
+
+	months := report.Months
+	if len(months) > 12 {
+		months = months[len(months)-12:]
+	}
+	printStatsChart("Completed per month", months, timeDetail)
+}
+
+func printStatsJson(report statsReport) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	return enc.Encode(report)
+}
+
+// Horizontal bar chart of at most statsChartRows buckets
+func printStatsChart(title string, buckets []statsBucket, detail func(statsBucket) string) {
+	if len(buckets) > statsChartRows {
+		buckets = buckets[:statsChartRows]
+	}
+	maxCount, labelWidth := 0, 0
+	for _, b := range buckets {
+		if b.Count > maxCount {
+			maxCount = b.Count
+		}
+		if len(b.Label) > labelWidth {
+			labelWidth = len(b.Label)
+		}
+	}
+	if maxCount == 0 {
+		return
+	}
+
+	yellow := color.New(color.FgHiYellow).SprintFunc()
+	cyan := color.New(color.FgHiCyan).SprintFunc()
+	faint := color.New(color.Faint).SprintFunc()
+	fmt.Println()
+	fmt.Fprintln(color.Output, yellow(title))
+	for _, b := range buckets {
+		width := b.Count * statsChartWidth / maxCount
+		if width == 0 && b.Count > 0 {
+			width = 1
+		}
+		line := fmt.Sprintf("  %-*s %s %d", labelWidth, b.Label,
+			cyan(strings.Repeat("█", width)), b.Count)
+		if detail != nil {
+			if d := detail(b); d != "" {
+				line += " " + faint("("+d+")")
+			}
+		}
+		fmt.Fprintln(color.Output, line)
+	}
+}
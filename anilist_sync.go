@@ -0,0 +1,281 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/aqatl/mal/anilist"
+	"github.com/fatih/color"
+	"github.com/urfave/cli"
+)
+
+type PendingOperationKind string
+
+const (
+	SaveOperation   PendingOperationKind = "SAVE"
+	DeleteOperation PendingOperationKind = "DELETE"
+)
+
+// Change made while AniList was unreachable, waiting to be pushed with `mal sync`
+type PendingOperation struct {
+	Kind      PendingOperationKind
+	MediaType anilist.MediaType
+	Entry     anilist.MediaListEntry
+
+	// updatedAt of the entry as last seen on the server. If the server version is newer
+	// during sync, the entry has been changed elsewhere and the operation is a conflict
+	BaseUpdatedAt int
+	CreatedAt     time.Time
+}
+
+func loadPendingOperations() []PendingOperation {
+	ops := make([]PendingOperation, 0)
+	LoadJsonFile(AniListPendingOpsFile, &ops)
+	return ops
+}
+
+func savePendingOperations(ops []PendingOperation) error {
+	return SaveJsonFile(AniListPendingOpsFile, ops)
+}
+
+func hasPendingOperations(ops []PendingOperation, listId int) bool {
+	for _, op := range ops {
+		if op.Entry.ListId == listId {
+			return true
+		}
+	}
+	return false
+}
+
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Saves entry on AniList. If AniList is unreachable or the entry already has changes
+// waiting to be synced, the change is recorded in the pending operations journal instead.
+// Returns true if the change was queued.
+func alSaveEntry(al *AniList, entry *anilist.MediaListEntry) (bool, error) {
+	return alSaveEntryWith(al, entry, al.Client().SaveMediaListEntryWaitAnimation, printQueuedMessage)
+}
+
+// Like alSaveEntry, but saves the entry with given function and reports a queued change
+// through onQueued, so it can be used where printing to stdout isn't an option (e.g. in a TUI)
+func alSaveEntryWith(
+	al *AniList,
+	entry *anilist.MediaListEntry,
+	save func(*anilist.MediaListEntry) error,
+	onQueued func(pending int),
+) (bool, error) {
+	ops := loadPendingOperations()
+	if !hasPendingOperations(ops, entry.ListId) {
+		err := save(entry)
+		if err == nil || !isNetworkError(err) {
+			return false, err
+		}
+	}
+	pending, err := queueOperation(ops, SaveOperation, al.MediaType, entry)
+	if err == nil {
+		onQueued(pending)
+	}
+	return true, err
+}
+
+// Deletes entry from AniList, falling back to the pending operations journal
+// the same way alSaveEntry does
+func alRemoveEntry(al *AniList, entry *anilist.MediaListEntry) (bool, error) {
+	return alRemoveEntryWith(al, entry, al.Client().DeleteMediaListEntry, printQueuedMessage)
+}
+
+// Like alRemoveEntry, but deletes the entry with given function and reports a queued change
+// through onQueued
+func alRemoveEntryWith(
+	al *AniList,
+	entry *anilist.MediaListEntry,
+	remove func(*anilist.MediaListEntry) error,
+	onQueued func(pending int),
+) (bool, error) {
+	ops := loadPendingOperations()
+	if !hasPendingOperations(ops, entry.ListId) {
+		err := remove(entry)
+		if err == nil || !isNetworkError(err) {
+			return false, err
+		}
+	}
+	pending, err := queueOperation(ops, DeleteOperation, al.MediaType, entry)
+	if err == nil {
+		onQueued(pending)
+	}
+	return true, err
+}
+
+func printQueuedMessage(pending int) {
+	fmt.Fprintf(color.Output,
+		"AniList is unreachable, change saved locally (%s pending). Run %s when you're online\n",
+		color.HiRedString("%d", pending), color.HiYellowString("mal sync"))
+}
+
+// Appends the operation to the journal and returns the number of pending operations
+func queueOperation(
+	ops []PendingOperation,
+	kind PendingOperationKind,
+	mediaType anilist.MediaType,
+	entry *anilist.MediaListEntry,
+) (int, error) {
+	baseUpdatedAt := entry.UpdatedAt
+	// Earlier queued changes didn't reach the server, so the base is still the same
+	for _, op := range ops {
+		if op.Entry.ListId == entry.ListId {
+			baseUpdatedAt = op.BaseUpdatedAt
+			break
+		}
+	}
+	entry.UpdatedAt = int(time.Now().Unix())
+
+	ops = append(ops, PendingOperation{
+		Kind:          kind,
+		MediaType:     mediaType,
+		Entry:         *entry,
+		BaseUpdatedAt: baseUpdatedAt,
+		CreatedAt:     time.Now(),
+	})
+	return len(ops), savePendingOperations(ops)
+}
+
+func alSync(ctx *cli.Context) error {
+	ops := loadPendingOperations()
+	if len(ops) == 0 {
+		fmt.Println("Nothing to sync")
+		return nil
+	}
+
+	if ctx.Bool("discard") {
+		if err := savePendingOperations(nil); err != nil {
+			return err
+		}
+		fmt.Fprintf(color.Output, "Discarded %s pending operations. Run %s to restore your list\n",
+			color.HiRedString("%d", len(ops)), color.HiYellowString("mal -r"))
+		return nil
+	}
+
+	al, err := loadAniList(ctx)
+	if err != nil {
+		return err
+	}
+	lists := map[anilist.MediaType]*AniList{al.MediaType: al}
+	getList := func(mediaType anilist.MediaType) (*AniList, error) {
+		if l, ok := lists[mediaType]; ok {
+			return l, nil
+		}
+		l := &AniList{Token: al.Token, User: al.User, MediaType: mediaType}
+		if err := loadAniListLists(l); err != nil {
+			return nil, err
+		}
+		lists[mediaType] = l
+		return l, nil
+	}
+
+	force := ctx.Bool("force")
+	yellow := color.New(color.FgHiYellow).SprintFunc()
+	red := color.New(color.FgHiRed).SprintFunc()
+	cyan := color.New(color.FgHiCyan).SprintFunc()
+
+	// updatedAt values returned by the server for entries pushed during this sync
+	pushedUpdatedAt := make(map[int]int)
+	conflicted := make(map[int]bool)
+	remaining := make([]PendingOperation, 0)
+	pushed, conflicts := 0, 0
+
+	for i, op := range ops {
+		entry := op.Entry
+		title := entry.Title.UserPreferred
+
+		if conflicted[entry.ListId] {
+			remaining = append(remaining, op)
+			continue
+		}
+
+		expectedUpdatedAt, ok := pushedUpdatedAt[entry.ListId]
+		if !ok {
+			expectedUpdatedAt = op.BaseUpdatedAt
+		}
+
+		remote, err := al.Client().QueryMediaListEntry(entry.ListId)
+		notFound := errors.Is(err, anilist.NotFound)
+		if notFound {
+			if op.Kind == DeleteOperation {
+				fmt.Fprintf(color.Output, "%s: already deleted\n", yellow(title))
+				continue
+			}
+		} else if err != nil {
+			remaining = append(remaining, ops[i:]...)
+			fmt.Fprintf(color.Output, "%s: %v\n", yellow(title), err)
+			break
+		}
+
+		if !force && (notFound || remote.UpdatedAt > expectedUpdatedAt) {
+			conflicted[entry.ListId] = true
+			remaining = append(remaining, op)
+			conflicts++
+			if notFound {
+				fmt.Fprintf(color.Output, "%s: %s, entry was deleted on AniList\n",
+					yellow(title), red("conflict"))
+			} else {
+				fmt.Fprintf(color.Output, "%s: %s, entry was modified on AniList at %s\n",
+					yellow(title), red("conflict"),
+					cyan(time.Unix(int64(remote.UpdatedAt), 0).Format("15:04 02-01-2006")))
+			}
+			continue
+		}
+
+		switch op.Kind {
+		case SaveOperation:
+			if notFound {
+				entry.ListId = 0
+			}
+			err = al.Client().SaveMediaListEntry(&entry)
+		case DeleteOperation:
+			err = al.Client().DeleteMediaListEntry(&entry)
+		}
+		if err != nil {
+			remaining = append(remaining, ops[i:]...)
+			fmt.Fprintf(color.Output, "%s: %v\n", yellow(title), err)
+			break
+		}
+		pushed++
+		pushedUpdatedAt[entry.ListId] = entry.UpdatedAt
+
+		if op.Kind == SaveOperation {
+			if l, err := getList(op.MediaType); err == nil {
+				if cached := l.GetMediaListById(entry.Id); cached != nil {
+					cached.ListId = entry.ListId
+					cached.UpdatedAt = entry.UpdatedAt
+				}
+			}
+			fmt.Fprintf(color.Output, "%s: pushed (%s, %s %d, score %v)\n",
+				yellow(title), entry.Status.String(), alProgressUnit(&entry), entry.Progress, entry.Score)
+		} else {
+			fmt.Fprintf(color.Output, "%s: deleted\n", yellow(title))
+		}
+	}
+
+	for _, l := range lists {
+		if err := saveAniListLists(l); err != nil {
+			return err
+		}
+	}
+	if err := savePendingOperations(remaining); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(color.Output, "\nPushed: %s, conflicts: %s, still pending: %s\n",
+		cyan(pushed), red(conflicts), red(len(remaining)))
+	if conflicts > 0 {
+		fmt.Fprintf(color.Output,
+			"Use %s to overwrite AniList with your changes or %s to drop them\n",
+			yellow("mal sync --force"), yellow("mal sync --discard"))
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aqatl/mal/anilist"
+)
+
+func TestQueueOperationKeepsBaseUpdatedAt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	opsFile := AniListPendingOpsFile
+	AniListPendingOpsFile = filepath.Join(dir, "ops.json")
+	t.Cleanup(func() { AniListPendingOpsFile = opsFile })
+
+	entry := &anilist.MediaListEntry{ListId: 7, UpdatedAt: 100, Progress: 1}
+	if _, err := queueOperation(loadPendingOperations(), SaveOperation, anilist.Anime, entry); err != nil {
+		t.Fatal(err)
+	}
+	entry.Progress++
+	if _, err := queueOperation(loadPendingOperations(), SaveOperation, anilist.Anime, entry); err != nil {
+		t.Fatal(err)
+	}
+
+	ops := loadPendingOperations()
+	if len(ops) != 2 {
+		t.Fatal("Expected 2 pending operations, got", len(ops))
+	}
+	for _, op := range ops {
+		if op.BaseUpdatedAt != 100 {
+			t.Error("Expected base updatedAt 100, got", op.BaseUpdatedAt)
+		}
+	}
+	if ops[1].Entry.Progress != 2 {
+		t.Error("Expected progress 2 in the last operation, got", ops[1].Entry.Progress)
+	}
+	if !hasPendingOperations(ops, 7) || hasPendingOperations(ops, 8) {
+		t.Error("hasPendingOperations returned wrong result")
+	}
+}
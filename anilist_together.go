@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aqatl/mal/anilist"
+	"github.com/fatih/color"
+	"github.com/urfave/cli"
+)
+
+const defaultTogetherLength = 10
+
+type userList struct {
+	Name string
+	List List
+}
+
+// Anime nobody in the group has completed, planned by some of them
+type groupCandidate struct {
+	Media anilist.MediaDeficient
+	// Names of users planning to watch it
+	Planning     []string
+	AverageScore int
+	// Total length in minutes, 0 if unknown
+	Runtime int
+}
+
+// Finds anime planned by at least minPlanning users of the group that nobody has completed
+func groupWatchCandidates(lists []userList, minPlanning int) []groupCandidate {
+	completed := make(map[int]bool)
+	for _, ul := range lists {
+		for _, entry := range ul.List {
+			if entry.Status == anilist.Completed || entry.Status == anilist.Repeating {
+				completed[entry.Id] = true
+			}
+		}
+	}
+
+	candidates := make([]groupCandidate, 0)
+	byId := make(map[int]int)
+	for _, ul := range lists {
+		for _, entry := range ul.List {
+			if entry.Status != anilist.Planning || completed[entry.Id] {
+				continue
+			}
+			i, ok := byId[entry.Id]
+			if !ok {
+				i = len(candidates)
+				byId[entry.Id] = i
+				candidates = append(candidates, groupCandidate{Media: entry.MediaDeficient})
+			}
+			candidates[i].Planning = append(candidates[i].Planning, ul.Name)
+		}
+	}
+
+	filtered := candidates[:0]
+	for _, c := range candidates {
+		if len(c.Planning) >= minPlanning {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered
+}
+
+// Fills in details of candidates and sorts them, the ones most users plan and then the best scored first
+func rankGroupCandidates(candidates []groupCandidate, media []anilist.MediaFull) {
+	mediaById := make(map[int]anilist.MediaFull, len(media))
+	for _, m := range media {
+		mediaById[m.Id] = m
+	}
+	for i := range candidates {
+		c := &candidates[i]
+		if m, ok := mediaById[c.Media.Id]; ok {
+			c.AverageScore = m.AverageScore
+			if m.Episodes > 0 {
+				c.Media.Episodes = m.Episodes
+			}
+			if m.Duration > 0 {
+				c.Media.Duration = m.Duration
+			}
+		}
+		c.Runtime = c.Media.Episodes * c.Media.Duration
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		ci, cj := candidates[i], candidates[j]
+		if len(ci.Planning) != len(cj.Planning) {
+			return len(ci.Planning) > len(cj.Planning)
+		}
+		if ci.AverageScore != cj.AverageScore {
+			return ci.AverageScore > cj.AverageScore
+		}
+		return ci.Media.Title.UserPreferred < cj.Media.Title.UserPreferred
+	})
+}
+
+func alTogether(ctx *cli.Context) error {
+	names := ctx.Args()
+	if len(names) == 0 {
+		return fmt.Errorf("no user names given")
+	}
+	al, err := loadAniListOfType(ctx, anilist.Anime)
+	if err != nil {
+		return err
+	}
+
+	lists := []userList{{Name: al.User.Name, List: al.List}}
+	for _, name := range names {
+		if strings.EqualFold(name, al.User.Name) {
+			continue
+		}
+		list, err := alFetchUserList(al, name, true)
+		if err != nil {
+			return err
+		}
+		lists = append(lists, userList{Name: name, List: list})
+	}
+	if len(lists) < 2 {
+		return fmt.Errorf("give names of other users to plan a watch session with")
+	}
+
+	minPlanning := ctx.Int("min")
+	if minPlanning < 1 {
+		minPlanning = 2
+	}
+	candidates := groupWatchCandidates(lists, minPlanning)
+	if len(candidates) == 0 {
+		fmt.Printf("Nothing is planned by at least %d of you that nobody has completed\n", minPlanning)
+		return nil
+	}
+
+	ids := make([]int, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.Media.Id
+	}
+	media, err := al.Client().QueryMediaByIdsWaitAnimation(ids)
+	if err != nil {
+		return err
+	}
+	rankGroupCandidates(candidates, media)
+
+	length := ctx.Int("max")
+	if length <= 0 {
+		length = defaultTogetherLength
+	}
+	if len(candidates) > length {
+		candidates = candidates[:length]
+	}
+	alPrintGroupCandidates(candidates, len(lists))
+	return nil
+}
+
+func alPrintGroupCandidates(candidates []groupCandidate, users int) {
+	faint := color.New(color.Faint).SprintFunc()
+
+	fmt.Printf("%3s %-40s%9s%6s%6s%9s\n", "No", "Title", "Planning", "Score", "Eps", "Runtime")
+	fmt.Println(strings.Repeat("=", 74))
+	for i, c := range candidates {
+		score, episodes, runtime := "-", "?", "?"
+		if c.AverageScore > 0 {
+			score = fmt.Sprintf("%d%%", c.AverageScore)
+		}
+		if c.Media.Episodes > 0 {
+			episodes = fmt.Sprint(c.Media.Episodes)
+		}
+		if c.Runtime > 0 {
+			runtime = formatCatchUpTime(c.Runtime)
+		}
+		fmt.Fprintf(color.Output, "%3d %-40.40s%9s%6s%6s%9s %s\n", i+1, c.Media.Title.UserPreferred,
+			fmt.Sprintf("%d/%d", len(c.Planning), users), score, episodes, runtime,
+			faint(strings.Join(c.Planning, ", ")))
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/aqatl/mal/anilist"
+	"github.com/fatih/color"
+	"github.com/urfave/cli"
+)
+
+const defaultCompareLength = 10
+
+// Fetches the public list of another user, with scores in the format of the authenticated user
+func alFetchUserList(al *AniList, userName string, animation bool) (List, error) {
+	query := al.Client().QueryUserListsByName
+	if animation {
+		query = al.Client().QueryUserListsByNameWaitAnimation
+	}
+	groups, err := query(userName, al.MediaType, al.User.MediaListOptions.ScoreFormat)
+	if err != nil {
+		return nil, fmt.Errorf("can't get the list of %s: %v", userName, err)
+	}
+	return flattenListGroups(groups), nil
+}
+
+func userNameArg(ctx *cli.Context) (string, error) {
+	name := ctx.Args().First()
+	if name == "" {
+		return "", fmt.Errorf("no user name given")
+	}
+	return name, nil
+}
+
+func alUser(ctx *cli.Context) error {
+	name, err := userNameArg(ctx)
+	if err != nil {
+		return err
+	}
+	al, err := loadAniList(ctx)
+	if err != nil {
+		return err
+	}
+	list, err := alFetchUserList(al, name, true)
+	if err != nil {
+		return err
+	}
+
+	cfg := LoadConfig()
+	if list, err = alDisplayedList(ctx, cfg, list); err != nil {
+		return err
+	}
+	list = list[:alVisibleEntries(ctx, cfg, len(list))]
+	alPrintListTable(list, cfg, al.MediaType, al.User.MediaListOptions.ScoreFormat, 0, nil)
+	return nil
+}
+
+// Media on both lists
+type sharedEntry struct {
+	Mine   anilist.MediaListEntry
+	Theirs anilist.MediaListEntry
+}
+
+// Positive if they scored it higher
+func (e sharedEntry) ScoreDiff() float32 {
+	return e.Theirs.Score - e.Mine.Score
+}
+
+type listComparison struct {
+	Shared []sharedEntry
+	// Entries scored on both lists, the biggest score difference first
+	Scored []sharedEntry
+	// Pearson correlation of scores of Scored entries, valid only if HasAffinity is set
+	Affinity    float64
+	HasAffinity bool
+	// Their completed entries you plan to watch, the ones they scored best first
+	Suggestions List
+}
+
+func compareLists(mine, theirs List) listComparison {
+	comparison := listComparison{
+		Shared:      make([]sharedEntry, 0),
+		Scored:      make([]sharedEntry, 0),
+		Suggestions: make(List, 0),
+	}
+	for _, their := range theirs {
+		my := mine.GetMediaListById(their.Id)
+		if my == nil {
+			continue
+		}
+		shared := sharedEntry{Mine: *my, Theirs: their}
+		comparison.Shared = append(comparison.Shared, shared)
+		if my.Score > 0 && their.Score > 0 {
+			comparison.Scored = append(comparison.Scored, shared)
+		}
+		if my.Status == anilist.Planning && their.Status == anilist.Completed {
+			comparison.Suggestions = append(comparison.Suggestions, their)
+		}
+	}
+
+	myScores := make([]float64, len(comparison.Scored))
+	theirScores := make([]float64, len(comparison.Scored))
+	for i, shared := range comparison.Scored {
+		myScores[i] = float64(shared.Mine.Score)
+		theirScores[i] = float64(shared.Theirs.Score)
+	}
+	comparison.Affinity, comparison.HasAffinity = pearsonCorrelation(myScores, theirScores)
+
+	sort.SliceStable(comparison.Scored, func(i, j int) bool {
+		return math.Abs(float64(comparison.Scored[i].ScoreDiff())) >
+			math.Abs(float64(comparison.Scored[j].ScoreDiff()))
+	})
+	sort.SliceStable(comparison.Suggestions, func(i, j int) bool {
+		return comparison.Suggestions[i].Score > comparison.Suggestions[j].Score
+	})
+	return comparison
+}
+
+// False if there are less than two values or one of the series is constant
+func pearsonCorrelation(xs, ys []float64) (float64, bool) {
+	n := len(xs)
+	if n < 2 || n != len(ys) {
+		return 0, false
+	}
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= float64(n)
+	meanY /= float64(n)
+
+	var cov, varX, varY float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return 0, false
+	}
+	return cov / math.Sqrt(varX*varY), true
+}
+
+func alCompare(ctx *cli.Context) error {
+	name, err := userNameArg(ctx)
+	if err != nil {
+		return err
+	}
+	al, err := loadAniList(ctx)
+	if err != nil {
+		return err
+	}
+	theirs, err := alFetchUserList(al, name, true)
+	if err != nil {
+		return err
+	}
+	length := ctx.Int("max")
+	if length <= 0 {
+		length = defaultCompareLength
+	}
+	alPrintComparison(compareLists(al.List, theirs), name, al.User.MediaListOptions.ScoreFormat, length)
+	return nil
+}
+
+func alPrintComparison(c listComparison, name string, scoreFormat anilist.ScoreFormat, length int) {
+	yellow := color.New(color.FgHiYellow).SprintFunc()
+	cyan := color.New(color.FgHiCyan).SprintFunc()
+	red := color.New(color.FgHiRed).SprintFunc()
+	green := color.New(color.FgHiGreen).SprintFunc()
+	faint := color.New(color.Faint).SprintFunc()
+
+	fmt.Fprintf(color.Output, "Shared entries: %s, scored by both: %s\n",
+		cyan(len(c.Shared)), cyan(len(c.Scored)))
+	if c.HasAffinity {
+		fmt.Fprintf(color.Output, "Affinity: %s\n", yellow(fmt.Sprintf("%.1f%%", c.Affinity*100)))
+	} else {
+		fmt.Fprintf(color.Output, "Affinity: %s\n", faint("not enough entries scored by both of you"))
+	}
+
+	if len(c.Scored) > 0 {
+		var sum float32
+		for _, shared := range c.Scored {
+			sum += shared.ScoreDiff()
+		}
+		fmt.Fprintf(color.Output, "Mean score difference: %s\n",
+			cyan(fmt.Sprintf("%+.1f", sum/float32(len(c.Scored)))))
+
+		fmt.Println()
+		fmt.Fprintln(color.Output, yellow("Biggest score differences"))
+		fmt.Fprintf(color.Output, "%-50s%6s%6s%6s\n", "Title", "You", "Them", "Diff")
+		fmt.Println(strings.Repeat("=", 68))
+		scored := c.Scored
+		if len(scored) > length {
+			scored = scored[:length]
+		}
+		for _, shared := range scored {
+			diff := formatScore(shared.ScoreDiff(), scoreFormat)
+			if shared.ScoreDiff() < 0 {
+				diff = red(fmt.Sprintf("%6s", diff))
+			} else if shared.ScoreDiff() > 0 {
+				diff = green(fmt.Sprintf("%6s", "+"+diff))
+			} else {
+				diff = fmt.Sprintf("%6s", diff)
+			}
+			fmt.Fprintf(color.Output, "%-50.50s%6s%6s%s\n", shared.Mine.Title.UserPreferred,
+				formatScore(shared.Mine.Score, scoreFormat),
+				formatScore(shared.Theirs.Score, scoreFormat),
+				diff)
+		}
+	}
+
+	if len(c.Suggestions) > 0 {
+		fmt.Println()
+		fmt.Fprintln(color.Output, yellow(fmt.Sprintf("%s completed, you planned", name)))
+		suggestions := c.Suggestions
+		if len(suggestions) > length {
+			suggestions = suggestions[:length]
+		}
+		for _, entry := range suggestions {
+			line := "  " + entry.Title.UserPreferred
+			if entry.Score > 0 {
+				line += " " + faint(fmt.Sprintf("(scored %s)", formatScore(entry.Score, scoreFormat)))
+			}
+			fmt.Fprintln(color.Output, line)
+		}
+	}
+}
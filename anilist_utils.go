@@ -2,22 +2,49 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
 	"github.com/aqatl/mal/anilist"
 	"github.com/aqatl/mal/oauth2"
+	"github.com/fatih/color"
 	"github.com/urfave/cli"
-	"os"
-	"time"
 )
 
 type AniList struct {
-	Token oauth2.OAuthToken
-	User  anilist.User
+	Token     oauth2.OAuthToken
+	User      anilist.User
+	MediaType anilist.MediaType
 	List
+
+	client *anilist.Client
 }
 
 type List []anilist.MediaListEntry
 
+// Endpoint used by AniList clients, replaced in tests
+var aniListEndpoint = anilist.ApiEndpoint
+
+func newAniListClient(token oauth2.OAuthToken) *anilist.Client {
+	client := anilist.NewClient(token)
+	client.Endpoint = aniListEndpoint
+	return client
+}
+
+// Returns client authenticated with al.Token. The client is reused between calls,
+// so it can keep track of the rate limit. Creating it isn't synchronized, so TUIs get
+// the client before starting and use it from their goroutines.
+func (al *AniList) Client() *anilist.Client {
+	if al.client == nil || al.client.Token.Token != al.Token.Token {
+		al.client = newAniListClient(al.Token)
+	}
+	return al.client
+}
+
 func (l List) GetMediaListById(id int) *anilist.MediaListEntry {
 	for i := 0; i < len(l); i++ {
 		if l[i].Id == id {
@@ -68,17 +95,29 @@ func sliceOfEntryTitles(entry *anilist.MediaListEntry) []string {
 	return alts
 }
 
+// Like status.String(), but respects the wording used for manga
+func alStatusString(status anilist.MediaListStatus, mediaType anilist.MediaType) string {
+	if mediaType == anilist.Manga && status == anilist.Current {
+		return "Reading"
+	}
+	return status.String()
+}
+
 func alGetList(al *AniList, status anilist.MediaListStatus) List {
+	return filterListByStatus(al.List, status)
+}
+
+func filterListByStatus(list List, status anilist.MediaListStatus) List {
 	if status == anilist.All {
-		return al.List
+		return list
 	} else {
-		list := make(List, 0)
-		for i := range al.List {
-			if al.List[i].Status == status {
-				list = append(list, al.List[i])
+		filtered := make(List, 0)
+		for i := range list {
+			if list[i].Status == status {
+				filtered = append(filtered, list[i])
 			}
 		}
-		return list
+		return filtered
 	}
 }
 
@@ -88,10 +127,11 @@ func loadAniListFull(ctx *cli.Context) (al *AniList, entry *anilist.MediaListEnt
 		return
 	}
 	cfg = LoadConfig()
-	if cfg.ALSelectedID == 0 {
+	selectedID := cfg.ALSelected(al.MediaType)
+	if selectedID == 0 {
 		fmt.Println("No entry selected")
 	}
-	entry = al.GetMediaListById(cfg.ALSelectedID)
+	entry = al.GetMediaListById(selectedID)
 	if entry == nil {
 		err = fmt.Errorf("no entry found")
 	}
@@ -99,27 +139,90 @@ func loadAniListFull(ctx *cli.Context) (al *AniList, entry *anilist.MediaListEnt
 }
 
 func loadAniList(ctx *cli.Context) (*AniList, error) {
+	return loadAniListOfType(ctx, alMediaType(ctx))
+}
+
+// Loads the list of given media type regardless of --manga flag and configured media type
+func loadAniListOfType(ctx *cli.Context, mediaType anilist.MediaType) (*AniList, error) {
 	token, err := loadOAuthToken()
 	if err != nil {
 		return nil, err
 	}
-	al := &AniList{Token: token}
+	al := &AniList{Token: token, MediaType: mediaType}
 
-	if err := loadAniListUser(al); err != nil {
+	if err := loadAniListUser(al, ctx.Bool("refresh")); err != nil {
 		return nil, err
 	}
 	if ctx.Bool("refresh") {
-		err = fetchAniListAnimeLists(al)
-	} else {
-		err = loadAniListAnimeLists(al)
+		return al, fetchAniListLists(al)
+	}
+	if err := loadAniListLists(al); err != nil {
+		return al, err
+	}
+	if ctx.Bool("update") {
+		return al, updateAniListLists(al)
+	}
+	if alAutoRefreshDue(LoadConfig(), al.MediaType) {
+		if err := updateAniListLists(al); err != nil {
+			fmt.Fprintln(color.Output, color.HiRedString("Auto refresh failed:"), err)
+		}
+	}
+	return al, nil
+}
+
+func alAutoRefreshDue(cfg *Config, mediaType anilist.MediaType) bool {
+	return cfg.ALAutoRefresh > 0 && time.Since(cfg.ALLastUpdate(mediaType)) > cfg.ALAutoRefresh
+}
+
+// Media type chosen with the global --manga flag or the one from config
+func alMediaType(ctx *cli.Context) anilist.MediaType {
+	if ctx.GlobalBool("manga") {
+		return anilist.Manga
+	}
+	if mediaType := LoadConfig().ALMediaType; mediaType != "" {
+		return mediaType
+	}
+	return anilist.Anime
+}
+
+func alCacheFile(mediaType anilist.MediaType) string {
+	if mediaType == anilist.Manga {
+		return AniListMangaCacheFile
 	}
-	return al, err
+	return AniListCacheFile
 }
 
+// Variable, so tests can point it to a local server
+var aniListTokenUrl = "https://anilist.co/api/v2/oauth/token"
+
+const (
+	aniListAuthUrl      = "https://anilist.co/api/v2/oauth/authorize"
+	aniListClientID     = 743
+	aniListRedirectPort = 42505
+
+	// Tokens with refresh token are refreshed when they're about to expire within this period
+	tokenRefreshMargin = 24 * time.Hour
+)
+
 func loadOAuthToken() (oauth2.OAuthToken, error) {
 	token, err := loadCachedOAuthToken()
+	if token.RefreshToken != "" && time.Now().Add(tokenRefreshMargin).After(token.ExpireDate) {
+		codeCfg := alAuthCodeConfig(LoadConfig())
+		// Refresh token works only with the client it was issued to, the configured one may differ
+		if codeCfg.ClientID != token.ClientID {
+			codeCfg.ClientID, codeCfg.ClientSecret = token.ClientID, ""
+		}
+		refreshed, refreshErr := oauth2.RefreshOAuthToken(codeCfg, token)
+		if refreshErr == nil {
+			return refreshed, saveOAuthToken(refreshed)
+		}
+		// A token that hasn't expired yet still works, otherwise the user has to log in again
+		if err != nil {
+			fmt.Fprintln(color.Error, "Refreshing AniList token failed:", refreshErr)
+		}
+	}
 	if err != nil {
-		if err == anilist.InvalidToken || os.IsNotExist(err) {
+		if errors.Is(err, anilist.InvalidToken) || os.IsNotExist(err) {
 			token, err = requestAniListToken()
 		}
 	}
@@ -146,12 +249,32 @@ func saveOAuthToken(token oauth2.OAuthToken) error {
 }
 
 func requestAniListToken() (token oauth2.OAuthToken, err error) {
-	token, err = oauth2.OAuthImplicitGrantAuth(
-		"https://anilist.co/api/v2/oauth/authorize",
-		LoadConfig().BrowserPath,
-		743,
-		42505,
-	)
+	return requestAniListTokenWith(LoadConfig(), isHeadless())
+}
+
+// Uses the authorization code grant if the user configured their own client
+// and the implicit grant otherwise. In headless mode the user is asked
+// to paste the redirect url instead of the browser being opened.
+func requestAniListTokenWith(cfg *Config, headless bool) (token oauth2.OAuthToken, err error) {
+	var prompt oauth2.Prompt
+	if headless {
+		prompt = oauth2.ReaderPrompt(os.Stdin, os.Stdout)
+	}
+
+	if cfg.ALClientID != 0 {
+		codeCfg := alAuthCodeConfig(cfg)
+		codeCfg.Prompt = prompt
+		token, err = oauth2.OAuthAuthorizationCodeAuth(codeCfg)
+	} else if headless {
+		token, err = oauth2.OAuthImplicitGrantAuthManual(aniListAuthUrl, aniListClientID, prompt)
+	} else {
+		token, err = oauth2.OAuthImplicitGrantAuth(
+			aniListAuthUrl,
+			cfg.BrowserPath,
+			aniListClientID,
+			aniListRedirectPort,
+		)
+	}
 	if err != nil {
 		return
 	}
@@ -159,16 +282,60 @@ func requestAniListToken() (token oauth2.OAuthToken, err error) {
 	return
 }
 
-func loadAniListUser(al *AniList) error {
-	if LoadJsonFile(AniListUserFile, &al.User) {
+func alAuthCodeConfig(cfg *Config) oauth2.AuthCodeConfig {
+	addr := "localhost:" + strconv.Itoa(aniListRedirectPort)
+	return oauth2.AuthCodeConfig{
+		AuthUrl:      aniListAuthUrl,
+		TokenUrl:     aniListTokenUrl,
+		ClientID:     cfg.ALClientID,
+		ClientSecret: cfg.ALClientSecret,
+		ListenAddr:   addr,
+		RedirectUri:  "http://" + addr + "/oauth2",
+		BrowserPath:  cfg.BrowserPath,
+	}
+}
+
+// Guesses whether there's no way to open a browser, e.g. in a SSH session
+func isHeadless() bool {
+	if os.Getenv("SSH_CONNECTION") == "" && os.Getenv("SSH_TTY") == "" {
+		return false
+	}
+	return runtime.GOOS != "windows" && runtime.GOOS != "darwin" &&
+		os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == ""
+}
+
+func alLogin(ctx *cli.Context) error {
+	cfg := LoadConfig()
+	headless := ctx.Bool("headless") || isHeadless() && !ctx.Bool("browser")
+
+	token, err := requestAniListTokenWith(cfg, headless)
+	if err != nil {
+		return err
+	}
+
+	user := anilist.User{}
+	if err := newAniListClient(token).QueryAuthenticatedUser(&user); err != nil {
+		return err
+	}
+	if err := saveAniListUser(&user); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(color.Output, "Logged in as %s\n", color.HiYellowString("%s", user.Name))
+	return nil
+}
+
+// Refresh fetches the user again, e.g. to get changed list settings
+func loadAniListUser(al *AniList, refresh bool) error {
+	if !refresh && LoadJsonFile(AniListUserFile, &al.User) {
 		return nil
 	}
-	err := anilist.QueryAuthenticatedUser(&al.User, al.Token)
-	if err == anilist.InvalidToken {
+	err := al.Client().QueryAuthenticatedUser(&al.User)
+	if errors.Is(err, anilist.InvalidToken) {
 		if al.Token, err = requestAniListToken(); err != nil {
 			return err
 		}
-		err = anilist.QueryAuthenticatedUser(&al.User, al.Token)
+		err = al.Client().QueryAuthenticatedUser(&al.User)
 	}
 	if err == nil {
 		err = saveAniListUser(&al.User)
@@ -186,8 +353,8 @@ func saveAniListUser(user *anilist.User) error {
 	return err
 }
 
-func loadAniListAnimeLists(al *AniList) error {
-	f, err := os.Open(AniListCacheFile)
+func loadAniListLists(al *AniList) error {
+	f, err := os.Open(alCacheFile(al.MediaType))
 	defer f.Close()
 	if err == nil {
 		err = json.NewDecoder(f).Decode(&al.List)
@@ -196,43 +363,179 @@ func loadAniListAnimeLists(al *AniList) error {
 	if !os.IsNotExist(err) {
 		return err
 	}
-	return fetchAniListAnimeLists(al)
+	return fetchAniListLists(al)
 }
 
-func fetchAniListAnimeLists(al *AniList) error {
-	lists, err := anilist.QueryUserListsWaitAnimation(al.User.Id, al.User.MediaListOptions.ScoreFormat, al.Token)
+// Entries of all lists, custom lists repeat entries of status lists so duplicates are skipped
+func flattenListGroups(groups []anilist.MediaListGroup) List {
+	list := make(List, 0)
 	entryIds := make(map[int]bool)
-	for i := range lists {
-		for _, entry := range lists[i].Entries {
+	for i := range groups {
+		for _, entry := range groups[i].Entries {
 			if !entryIds[entry.Id] {
-				al.List = append(al.List, entry)
+				list = append(list, entry)
 				entryIds[entry.Id] = true
 			}
 		}
 	}
-	if err == anilist.InvalidToken {
+	return list
+}
+
+func fetchAniListLists(al *AniList) error {
+	refreshTime := time.Now()
+	lists, err := al.Client().QueryUserListsWaitAnimation(al.User.Id, al.MediaType, al.User.MediaListOptions.ScoreFormat)
+	if errors.Is(err, anilist.InvalidToken) {
+		if al.Token, err = requestAniListToken(); err != nil {
+			return err
+		}
+		lists, err = al.Client().QueryUserListsWaitAnimation(al.User.Id, al.MediaType, al.User.MediaListOptions.ScoreFormat)
+	}
+	if err != nil {
+		return err
+	}
+
+	ops := loadPendingOperations()
+	local := al.List
+	if len(ops) > 0 && len(local) == 0 {
+		// A full refresh doesn't load the cache, which holds local versions of pending entries
+		LoadJsonFile(alCacheFile(al.MediaType), &local)
+	}
+	al.List = keepPendingEntries(flattenListGroups(lists), local, ops)
+	if err := saveAniListLists(al); err != nil {
+		return err
+	}
+	setAniListLastUpdate(al.MediaType, refreshTime)
+	return nil
+}
+
+// Keeps the local version of entries with pending operations in a freshly fetched list,
+// the same way mergeAniListChanges does for incremental refreshes
+func keepPendingEntries(fetched, local List, ops []PendingOperation) List {
+	if len(ops) == 0 {
+		return fetched
+	}
+	localByListId := make(map[int]anilist.MediaListEntry, len(local))
+	for _, entry := range local {
+		localByListId[entry.ListId] = entry
+	}
+
+	kept := make(List, 0, len(fetched))
+	fetchedIds := make(map[int]bool, len(fetched))
+	for _, entry := range fetched {
+		fetchedIds[entry.ListId] = true
+		if !hasPendingOperations(ops, entry.ListId) {
+			kept = append(kept, entry)
+		} else if localEntry, ok := localByListId[entry.ListId]; ok {
+			kept = append(kept, localEntry)
+		}
+		// Otherwise the entry was deleted locally and stays deleted until synced
+	}
+	for _, entry := range local {
+		if !fetchedIds[entry.ListId] && hasPendingOperations(ops, entry.ListId) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// Entries updated up to this long before the last refresh are fetched again,
+// in case the local clock is ahead of AniList's
+const incrementalRefreshMargin = 5 * time.Minute
+
+// Fetches only entries changed since the last refresh and merges them into the loaded list.
+// Falls back to fetching the whole list if the time of the last refresh is unknown.
+func updateAniListLists(al *AniList) error {
+	lastUpdate := LoadConfig().ALLastUpdate(al.MediaType)
+	if lastUpdate.IsZero() {
+		return fetchAniListLists(al)
+	}
+	since := int(lastUpdate.Add(-incrementalRefreshMargin).Unix())
+
+	refreshTime := time.Now()
+	changes, ids, err := al.Client().QueryUserListChangesWaitAnimation(
+		al.User.Id, al.MediaType, al.User.MediaListOptions.ScoreFormat, since)
+	if errors.Is(err, anilist.InvalidToken) {
 		if al.Token, err = requestAniListToken(); err != nil {
 			return err
 		}
-		lists, err = anilist.QueryUserListsWaitAnimation(al.User.Id, al.User.MediaListOptions.ScoreFormat, al.Token)
-		entryIds := make(map[int]bool)
-		for i := range lists {
-			for _, entry := range lists[i].Entries {
-				if !entryIds[entry.Id] {
-					al.List = append(al.List, entry)
-					entryIds[entry.Id] = true
-				}
+		changes, ids, err = al.Client().QueryUserListChangesWaitAnimation(
+			al.User.Id, al.MediaType, al.User.MediaListOptions.ScoreFormat, since)
+	}
+	if err != nil {
+		return err
+	}
+
+	var updated, added, removed int
+	al.List, updated, added, removed = mergeAniListChanges(al.List, changes, ids, loadPendingOperations())
+	if err := saveAniListLists(al); err != nil {
+		return err
+	}
+	setAniListLastUpdate(al.MediaType, refreshTime)
+
+	if updated+added+removed > 0 {
+		cyan := color.New(color.FgHiCyan).SprintFunc()
+		fmt.Fprintf(color.Output, "List refreshed: %s updated, %s added, %s removed\n",
+			cyan(updated), cyan(added), cyan(removed))
+	}
+	return nil
+}
+
+// Applies changes fetched from AniList to the cached list. Cached entries with list ids missing
+// from ids were deleted on AniList. Entries with pending operations keep their local version
+// until they're synced.
+func mergeAniListChanges(list List, changes []anilist.MediaListEntry, ids []int, ops []PendingOperation) (
+	merged List, updated, added, removed int,
+) {
+	onServer := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		onServer[id] = true
+	}
+	changed := make(map[int]anilist.MediaListEntry, len(changes))
+	for _, entry := range changes {
+		changed[entry.ListId] = entry
+	}
+
+	merged = make(List, 0, len(list))
+	for _, entry := range list {
+		if hasPendingOperations(ops, entry.ListId) {
+			merged = append(merged, entry)
+			delete(changed, entry.ListId)
+			continue
+		}
+		if entry.ListId != 0 && !onServer[entry.ListId] {
+			removed++
+			continue
+		}
+		if newEntry, ok := changed[entry.ListId]; ok {
+			if newEntry.UpdatedAt != entry.UpdatedAt {
+				updated++
 			}
+			merged = append(merged, newEntry)
+			delete(changed, entry.ListId)
+			continue
 		}
+		merged = append(merged, entry)
 	}
-	if err == nil {
-		err = saveAniListAnimeLists(al)
+
+	// What's left are entries added since the last refresh, appended in the server's order
+	for _, entry := range changes {
+		if _, ok := changed[entry.ListId]; ok && !hasPendingOperations(ops, entry.ListId) {
+			merged = append(merged, entry)
+			delete(changed, entry.ListId)
+			added++
+		}
 	}
-	return err
+	return
+}
+
+func setAniListLastUpdate(mediaType anilist.MediaType, t time.Time) {
+	cfg := LoadConfig()
+	cfg.SetALLastUpdate(mediaType, t)
+	cfg.Save()
 }
 
-func saveAniListAnimeLists(al *AniList) error {
-	f, err := os.Create(AniListCacheFile)
+func saveAniListLists(al *AniList) error {
+	f, err := os.Create(alCacheFile(al.MediaType))
 	defer f.Close()
 	if err != nil {
 		return err
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aqatl/mal/anilist"
+	"github.com/fatih/color"
+	"github.com/urfave/cli"
+)
+
+const (
+	// Only the most recent ids are kept, older notifications don't show up on the first page anyway
+	maxSeenNotifications = 500
+
+	minWatchInterval  = time.Minute
+	actionTimeout     = 30 * time.Second
+	notificationsPage = 50
+)
+
+// Airing notification passed to actions. Fields are available in --exec templates, e.g. {{.Title}}
+type airingEvent struct {
+	Id      int       `json:"id"`
+	AnimeId int       `json:"animeId"`
+	Episode int       `json:"episode"`
+	Title   string    `json:"title"`
+	AiredAt time.Time `json:"airedAt"`
+	Url     string    `json:"url"`
+	Message string    `json:"message"`
+}
+
+func newAiringEvent(n anilist.AiringNotification) airingEvent {
+	return airingEvent{
+		Id:      n.Id,
+		AnimeId: n.AnimeId,
+		Episode: n.Episode,
+		Title:   n.Title.UserPreferred,
+		AiredAt: time.Unix(int64(n.CreatedAt), 0),
+		Url:     fmt.Sprintf(aniListAnimeUrl, n.AnimeId),
+		Message: fmt.Sprintf("Episode %d of %s aired", n.Episode, n.Title.UserPreferred),
+	}
+}
+
+type airingAction struct {
+	Name string
+	Run  func(airingEvent) error
+}
+
+func airingActions(ctx *cli.Context) ([]airingAction, error) {
+	actions := make([]airingAction, 0)
+	if ctx.Bool("notify") {
+		actions = append(actions, notifySendAction())
+	}
+	for _, command := range ctx.StringSlice("exec") {
+		action, err := commandAction(command)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+	}
+	for _, url := range ctx.StringSlice("webhook") {
+		actions = append(actions, webhookAction(url))
+	}
+	return actions, nil
+}
+
+func notifySendAction() airingAction {
+	return airingAction{
+		Name: "notify-send",
+		Run: func(ev airingEvent) error {
+			return exec.Command("notify-send", "--app-name=mal", ev.Title,
+				fmt.Sprintf("Episode %d aired", ev.Episode)).Run()
+		},
+	}
+}
+
+// The command is split on spaces before the arguments are filled in,
+// so a title with spaces stays a single argument
+func commandAction(command string) (airingAction, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return airingAction{}, fmt.Errorf("empty --exec command")
+	}
+	args := make([]*template.Template, len(fields))
+	for i, field := range fields {
+		tmpl, err := template.New("arg").Option("missingkey=error").Parse(field)
+		if err != nil {
+			return airingAction{}, fmt.Errorf("invalid --exec command %q: %v", command, err)
+		}
+		args[i] = tmpl
+	}
+
+	return airingAction{
+		Name: fields[0],
+		Run: func(ev airingEvent) error {
+			argv := make([]string, len(args))
+			for i, tmpl := range args {
+				buf := new(strings.Builder)
+				if err := tmpl.Execute(buf, ev); err != nil {
+					return err
+				}
+				argv[i] = buf.String()
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), actionTimeout)
+			defer cancel()
+			cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			return cmd.Run()
+		},
+	}, nil
+}
+
+// POSTs the event as json
+func webhookAction(url string) airingAction {
+	client := &http.Client{Timeout: actionTimeout}
+	return airingAction{
+		Name: url,
+		Run: func(ev airingEvent) error {
+			body, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+				return fmt.Errorf("webhook responded with %s", resp.Status)
+			}
+			return nil
+		},
+	}
+}
+
+// Returns ids of notifications already handled and whether the watcher has run before
+func loadSeenNotifications() ([]int, bool) {
+	ids := make([]int, 0)
+	return ids, LoadJsonFile(AniListSeenNotificationsFile, &ids)
+}
+
+func saveSeenNotifications(ids []int) error {
+	if len(ids) > maxSeenNotifications {
+		ids = ids[len(ids)-maxSeenNotifications:]
+	}
+	return SaveJsonFile(AniListSeenNotificationsFile, ids)
+}
+
+func alWatchAiring(ctx *cli.Context) error {
+	once := ctx.Bool("once")
+	interval := ctx.Duration("interval")
+	if !once && interval < minWatchInterval {
+		return fmt.Errorf("--interval must be at least %v", minWatchInterval)
+	}
+	actions, err := airingActions(ctx)
+	if err != nil {
+		return err
+	}
+	al, err := loadAniList(ctx)
+	if err != nil {
+		return err
+	}
+
+	seen, initialized := loadSeenNotifications()
+	if !once {
+		fmt.Fprintf(color.Output, "Checking airing notifications every %s, press Ctrl+C to stop\n",
+			color.HiCyanString("%v", interval))
+	}
+	for {
+		seen, err = alPollAiringNotifications(al, seen, initialized, actions)
+		if once {
+			return err
+		}
+		if err != nil {
+			fmt.Fprintf(color.Error, "[%s] %v\n", time.Now().Format("15:04"), err)
+		} else {
+			initialized = true
+		}
+		time.Sleep(interval)
+	}
+}
+
+// Runs actions for notifications that weren't seen before. On the first run existing
+// notifications are only marked as seen, so the backlog doesn't trigger a burst of actions
+func alPollAiringNotifications(
+	al *AniList,
+	seen []int,
+	initialized bool,
+	actions []airingAction,
+) ([]int, error) {
+	if initialized {
+		// A cheap check before asking for the notifications themselves
+		var user anilist.User
+		if err := al.Client().QueryAuthenticatedUser(&user); err != nil {
+			return seen, err
+		}
+		if user.UnreadNotificationCount == 0 {
+			return seen, nil
+		}
+	}
+
+	notifications, err := al.Client().QueryAiringNotifications(1, notificationsPage, false)
+	if err != nil {
+		return seen, err
+	}
+	sort.SliceStable(notifications, func(i, j int) bool {
+		return notifications[i].CreatedAt < notifications[j].CreatedAt
+	})
+
+	isSeen := make(map[int]bool, len(seen))
+	for _, id := range seen {
+		isSeen[id] = true
+	}
+	newCount := 0
+	for _, n := range notifications {
+		if n.Id == 0 || isSeen[n.Id] {
+			continue
+		}
+		seen = append(seen, n.Id)
+		newCount++
+		if !initialized {
+			continue
+		}
+
+		alPrintAiringNotification(n)
+		ev := newAiringEvent(n)
+		for _, action := range actions {
+			if err := action.Run(ev); err != nil {
+				fmt.Fprintf(color.Error, "%s failed: %v\n", action.Name, err)
+			}
+		}
+	}
+	if !initialized {
+		fmt.Fprintf(color.Output, "Marked %s existing notifications as seen\n",
+			color.HiCyanString("%d", newCount))
+	}
+	return seen, saveSeenNotifications(seen)
+}
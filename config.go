@@ -32,8 +32,19 @@ type Config struct {
 	SelectedID int
 	Status     mal.MyStatus
 
-	ALSelectedID int
-	ALStatus     anilist.MediaListStatus
+	ALSelectedID      int
+	ALMangaSelectedID int
+	ALStatus          anilist.MediaListStatus
+	ALMediaType       anilist.MediaType
+
+	// Time of the last manga list refresh (LastUpdate is used for the anime list)
+	ALMangaLastUpdate time.Time
+	// Cached list older than this is refreshed incrementally when loaded. 0 disables it
+	ALAutoRefresh time.Duration
+
+	// Own AniList API client used for the authorization code grant
+	ALClientID     uint
+	ALClientSecret string
 
 	NyaaAlts []NyaaAlt
 }
@@ -51,7 +62,40 @@ func NewConfig() *Config {
 
 		Status: mal.All,
 
-		ALStatus: anilist.Current,
+		ALStatus:    anilist.Current,
+		ALMediaType: anilist.Anime,
+	}
+}
+
+// Returns id of the selected entry from the list of given media type
+func (cfg *Config) ALSelected(mediaType anilist.MediaType) int {
+	if mediaType == anilist.Manga {
+		return cfg.ALMangaSelectedID
+	}
+	return cfg.ALSelectedID
+}
+
+func (cfg *Config) SetALSelected(mediaType anilist.MediaType, id int) {
+	if mediaType == anilist.Manga {
+		cfg.ALMangaSelectedID = id
+	} else {
+		cfg.ALSelectedID = id
+	}
+}
+
+// Returns time of the last refresh of the list of given media type
+func (cfg *Config) ALLastUpdate(mediaType anilist.MediaType) time.Time {
+	if mediaType == anilist.Manga {
+		return cfg.ALMangaLastUpdate
+	}
+	return cfg.LastUpdate
+}
+
+func (cfg *Config) SetALLastUpdate(mediaType anilist.MediaType, t time.Time) {
+	if mediaType == anilist.Manga {
+		cfg.ALMangaLastUpdate = t
+	} else {
+		cfg.LastUpdate = t
 	}
 }
 
@@ -174,6 +218,69 @@ func configChangeAlStatus(ctx *cli.Context) error {
 	return nil
 }
 
+func configChangeAlMediaType(ctx *cli.Context) error {
+	mediaType, err := anilist.ParseMediaType(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	cfg := LoadConfig()
+	cfg.ALMediaType = mediaType
+	cfg.Save()
+
+	fmt.Println("New media type:", strings.ToLower(string(mediaType)))
+	return nil
+}
+
+func configChangeOAuthClient(ctx *cli.Context) error {
+	cfg := LoadConfig()
+
+	if ctx.Bool("clear") {
+		cfg.ALClientID = 0
+		cfg.ALClientSecret = ""
+		cfg.Save()
+
+		fmt.Println("OAuth client cleared, the default client will be used")
+		return nil
+	}
+
+	clientID, err := strconv.ParseUint(ctx.Args().First(), 10, 32)
+	if err != nil || clientID == 0 {
+		return fmt.Errorf("usage: mal cfg oauth-client <client id> [client secret]")
+	}
+
+	cfg.ALClientID = uint(clientID)
+	cfg.ALClientSecret = ctx.Args().Get(1)
+	cfg.Save()
+
+	fmt.Fprintf(color.Output, "New OAuth client: %s. Run %s to log in with it\n",
+		color.HiYellowString("%d", clientID), color.HiYellowString("mal login"))
+	return nil
+}
+
+func configChangeAutoRefresh(ctx *cli.Context) error {
+	arg := ctx.Args().First()
+	var interval time.Duration
+	if arg != "off" && arg != "0" {
+		var err error
+		interval, err = time.ParseDuration(arg)
+		if err != nil || interval < 0 {
+			return fmt.Errorf("invalid interval %q, use e.g. 30m, 6h or off", arg)
+		}
+	}
+
+	cfg := LoadConfig()
+	cfg.ALAutoRefresh = interval
+	cfg.Save()
+
+	if interval == 0 {
+		fmt.Println("Auto refresh disabled")
+	} else {
+		fmt.Println("Cached lists older than", interval, "will be refreshed automatically")
+	}
+	return nil
+}
+
 func configChangeAutoUpdateMode(ctx *cli.Context) error {
 	arg := strings.ToLower(ctx.Args().First())
 	var mode StatusAutoUpdateMode
@@ -0,0 +1,63 @@
+package dialog
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jroimartin/gocui"
+)
+
+const inputDialogViewName = "inputDialogViewName"
+
+// Displays a single line text input with given initial value.
+// Entered text is sent on enter, the channel is closed without a value on esc / ctrl+q.
+func InputDialog(gui *gocui.Gui, title, initial string) (<-chan string, CleanUpFunc, error) {
+	cleanUp := cleanUpFunc(gui, inputDialogViewName)
+
+	w, h := gui.Size()
+	vw := len(title) + 4
+	if vw < 20 {
+		vw = 20
+	}
+	x0, y0 := w/2-vw/2, h/2-1
+	v, err := gui.SetView(inputDialogViewName, x0, y0, x0+vw, y0+2)
+	if err == gocui.ErrUnknownView {
+		err = nil
+	} else if err != nil {
+		return nil, cleanUp, err
+	}
+
+	input := make(chan string, 1)
+	chanClosed := false
+
+	v.Title = title
+	v.Editable = true
+	v.Editor = gocui.EditorFunc(func(v *gocui.View, key gocui.Key, ch rune, mod gocui.Modifier) {
+		switch {
+		case key == gocui.KeyEnter:
+			if !chanClosed {
+				input <- strings.TrimSpace(v.Buffer())
+				close(input)
+				chanClosed = true
+			}
+		case key == gocui.KeyCtrlQ || key == gocui.KeyEsc:
+			if !chanClosed {
+				close(input)
+				chanClosed = true
+			}
+		default:
+			gocui.DefaultEditor.Edit(v, key, ch, mod)
+		}
+	})
+	fmt.Fprint(v, initial)
+	v.SetCursor(len(initial), 0)
+
+	gui.Cursor = true
+	gui.SetCurrentView(inputDialogViewName)
+	gui.SetViewOnTop(inputDialogViewName)
+
+	return input, func(gui *gocui.Gui) error {
+		gui.Cursor = false
+		return cleanUp(gui)
+	}, err
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aqatl/mal/anilist"
+	"github.com/aqatl/mal/dialog"
+	"github.com/jroimartin/gocui"
+	"github.com/skratchdot/open-golang/open"
+	"github.com/urfave/cli"
+)
+
+func alInboxCui(ctx *cli.Context, al *AniList, types []anilist.NotificationType) error {
+	unread, err := alUnreadNotificationCount(al)
+	if err != nil {
+		return err
+	}
+	client := al.Client()
+	notifications, hasNextPage, err := client.QueryNotificationsWaitAnimation(
+		1, defaultInboxPageSize, types, false)
+	if err != nil {
+		return err
+	}
+
+	gui, err := gocui.NewGui(gocui.Output256)
+	if err != nil {
+		return fmt.Errorf("gocui error: %v", err)
+	}
+
+	ic := &inboxCui{
+		Al:            al,
+		Client:        client,
+		Gui:           gui,
+		Types:         types,
+		Notifications: notifications,
+		Page:          1,
+		HasNextPage:   hasNextPage,
+		Unread:        unread,
+	}
+	if len(types) > 0 {
+		ic.Filter = -1
+	}
+	ic.listLayout = listLayout{
+		HeaderView:    inboxHeaderView,
+		ListView:      inboxListView,
+		DetailsView:   inboxDetailsView,
+		ShortcutsView: inboxShortcutsView,
+		Editor:        gocui.EditorFunc(ic.listEditor),
+		Shortcuts:     inboxShortcuts,
+		Redraw:        ic.redraw,
+	}
+
+	gui.SetManager(ic)
+	gui.SetKeybinding("", gocui.KeyCtrlC, gocui.ModNone, quitGocui)
+
+	gui.Cursor = false
+	gui.Mouse = false
+	gui.Highlight = true
+	gui.SelFgColor = gocui.ColorGreen
+
+	err = gui.MainLoop()
+	gui.Close()
+	if err != nil && err != gocui.ErrQuit {
+		return err
+	}
+	if ic.Jump != nil {
+		return alJumpToNotification(ctx, *ic.Jump)
+	}
+	return nil
+}
+
+const (
+	inboxHeaderView    = "inboxHeaderView"
+	inboxListView      = "inboxListView"
+	inboxDetailsView   = "inboxDetailsView"
+	inboxShortcutsView = "inboxShortcutsView"
+)
+
+var inboxShortcuts = []string{
+	"enter", "select entry",
+	"o", "open in browser",
+	"t", "filter type",
+	"r", "mark all read",
+	"q", "quit",
+}
+
+type inboxCui struct {
+	listLayout
+
+	Al     *AniList
+	Client *anilist.Client
+	Gui    *gocui.Gui
+
+	Types []anilist.NotificationType
+	// Index of notificationGroups shown, 0 for all types, -1 for types given with --type
+	Filter        int
+	Notifications []anilist.Notification
+	Page          int
+	HasNextPage   bool
+	// The first Unread notifications are unread; valid only for all types
+	Unread int
+
+	SelIdx int
+	// Notification whose entry gets selected after closing the view
+	Jump *anilist.Notification
+
+	Message string
+	Busy    bool
+}
+
+func (ic *inboxCui) selected() *anilist.Notification {
+	if ic.SelIdx < 0 || ic.SelIdx >= len(ic.Notifications) {
+		return nil
+	}
+	return &ic.Notifications[ic.SelIdx]
+}
+
+// Unread markers are shown only for all types, the unread count doesn't tell which types they are
+func (ic *inboxCui) isUnread(idx int) bool {
+	return len(ic.Types) == 0 && idx < ic.Unread
+}
+
+func (ic *inboxCui) filterName() string {
+	switch {
+	case ic.Filter < 0:
+		return "custom"
+	case ic.Filter == 0:
+		return "all"
+	}
+	return notificationGroups[ic.Filter-1].Name
+}
+
+func (ic *inboxCui) redraw() {
+	ic.drawHeader()
+	ic.drawList()
+	ic.drawDetails()
+}
+
+func (ic *inboxCui) drawHeader() {
+	v, err := ic.Gui.View(inboxHeaderView)
+	if err != nil {
+		return
+	}
+	v.Clear()
+
+	fmt.Fprintf(v, " %s | %s loaded | type: %s", boldYellow("Inbox"),
+		cyan(len(ic.Notifications)), cyan(ic.filterName()))
+	if len(ic.Types) == 0 {
+		fmt.Fprint(v, " | unread: ", cyan(ic.Unread))
+	}
+	if ic.Message != "" {
+		fmt.Fprint(v, " | ", boldYellow(ic.Message))
+	}
+}
+
+func (ic *inboxCui) drawList() {
+	v, err := ic.Gui.View(inboxListView)
+	if err != nil {
+		return
+	}
+	v.Clear()
+
+	for i, n := range ic.Notifications {
+		marker := " "
+		if ic.isUnread(i) {
+			marker = "*"
+		}
+		t := time.Unix(int64(n.CreatedAt), 0).Format("02-01 15:04")
+		fmt.Fprintf(v, "%s %s %s\n", marker, t, n.Text())
+	}
+	if ic.HasNextPage {
+		fmt.Fprintln(v, "  ...")
+	}
+
+	scrollToSelection(v, ic.SelIdx)
+}
+
+func (ic *inboxCui) drawDetails() {
+	v, err := ic.Gui.View(inboxDetailsView)
+	if err != nil {
+		return
+	}
+	v.Clear()
+
+	n := ic.selected()
+	if n == nil {
+		fmt.Fprintln(v, "Nothing to show")
+		return
+	}
+
+	fmt.Fprintln(v, boldYellow(n.Text()))
+	fmt.Fprintln(v)
+	fmt.Fprintln(v, "Type:", cyan(n.Type))
+	fmt.Fprintln(v, "Received:", time.Unix(int64(n.CreatedAt), 0).Format("02-01-2006 15:04"))
+	if ic.isUnread(ic.SelIdx) {
+		fmt.Fprintln(v, "Unread:", cyan("yes"))
+	}
+	if n.User.Name != "" {
+		fmt.Fprintln(v, "User:", n.User.Name)
+	}
+	if n.Thread.Title != "" {
+		fmt.Fprintln(v, "Thread:", n.Thread.Title)
+	}
+	if n.Media.Id != 0 {
+		mediaType := n.Media.Type
+		if mediaType == "" {
+			mediaType = anilist.Anime
+		}
+		onList := "no"
+		if ic.Al.MediaType == mediaType {
+			if entry := ic.Al.GetMediaListById(n.Media.Id); entry != nil {
+				onList = alStatusString(entry.Status, mediaType)
+			}
+		} else {
+			onList = "?"
+		}
+		fmt.Fprintln(v, "Title:", n.Media.Title.UserPreferred)
+		fmt.Fprintln(v, "On your list:", cyan(onList))
+	}
+	if n.Reason != "" {
+		fmt.Fprintln(v, "Reason:", n.Reason)
+	}
+	if url := n.Url(); url != "" {
+		fmt.Fprintln(v)
+		fmt.Fprintln(v, url)
+	}
+}
+
+func (ic *inboxCui) listEditor(v *gocui.View, key gocui.Key, ch rune, mod gocui.Modifier) {
+	if delta, ok := listNavigation(v, key, ch, len(ic.Notifications)); ok {
+		ic.moveSelection(delta)
+		return
+	}
+	switch {
+	case ch == 't':
+		if ic.Busy {
+			return
+		}
+		ic.Filter = (ic.Filter + 1) % (len(notificationGroups) + 1)
+		ic.Types = nil
+		if ic.Filter > 0 {
+			ic.Types = notificationGroups[ic.Filter-1].Types
+		}
+		ic.Notifications, ic.Page, ic.HasNextPage, ic.SelIdx = nil, 0, true, 0
+		ic.loadNextPage()
+	case ch == 'r':
+		ic.markRead()
+	case ch == 'o':
+		ic.openInBrowser()
+	case key == gocui.KeyEnter:
+		ic.jump()
+	case ch == 'q':
+		ic.Gui.Update(func(gui *gocui.Gui) error {
+			return gocui.ErrQuit
+		})
+	}
+}
+
+func (ic *inboxCui) moveSelection(delta int) {
+	if len(ic.Notifications) == 0 {
+		return
+	}
+	ic.SelIdx = moveListSelection(ic.SelIdx, delta, len(ic.Notifications))
+	ic.drawList()
+	ic.drawDetails()
+	if ic.SelIdx == len(ic.Notifications)-1 {
+		ic.loadNextPage()
+	}
+}
+
+// Fetches the next page when there is one; called when the selection reaches the end of the list
+func (ic *inboxCui) loadNextPage() {
+	if !ic.HasNextPage || ic.Busy {
+		return
+	}
+	ic.Busy = true
+	ic.Message = "Loading"
+	ic.redraw()
+
+	page, types := ic.Page+1, ic.Types
+	go func() {
+		notifications, hasNextPage, err := ic.Client.QueryNotifications(
+			page, defaultInboxPageSize, types, false)
+		ic.Gui.Update(func(gui *gocui.Gui) error {
+			ic.Busy = false
+			ic.Message = ""
+			if err != nil {
+				ic.drawHeader()
+				dialog.JustShowOkDialog(gui, "Error", err.Error())
+				return nil
+			}
+			ic.Notifications = append(ic.Notifications, notifications...)
+			ic.Page, ic.HasNextPage = page, hasNextPage
+			ic.redraw()
+			return nil
+		})
+	}()
+}
+
+func (ic *inboxCui) markRead() {
+	if ic.Busy {
+		return
+	}
+	ic.Busy = true
+	ic.Message = "Marking as read"
+	ic.drawHeader()
+
+	go func() {
+		err := ic.Client.ResetNotificationCount()
+		ic.Gui.Update(func(gui *gocui.Gui) error {
+			ic.Busy = false
+			ic.Message = ""
+			if err != nil {
+				ic.drawHeader()
+				dialog.JustShowOkDialog(gui, "Error", err.Error())
+				return nil
+			}
+			ic.Unread = 0
+			ic.Message = "Marked all as read"
+			ic.redraw()
+			return nil
+		})
+	}()
+}
+
+func (ic *inboxCui) openInBrowser() {
+	n := ic.selected()
+	if n == nil || n.Url() == "" {
+		return
+	}
+	if path := LoadConfig().BrowserPath; path == "" {
+		open.Start(n.Url())
+	} else {
+		open.StartWith(n.Url(), path)
+	}
+}
+
+func (ic *inboxCui) jump() {
+	n := ic.selected()
+	if n == nil {
+		return
+	}
+	if n.Media.Id == 0 {
+		dialog.JustShowOkDialog(ic.Gui, "Select entry", "The notification isn't about an anime or manga")
+		return
+	}
+	ic.Jump = n
+	ic.Gui.Update(func(gui *gocui.Gui) error {
+		return gocui.ErrQuit
+	})
+}
@@ -0,0 +1,580 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aqatl/mal/anilist"
+	"github.com/aqatl/mal/dialog"
+	"github.com/fatih/color"
+	"github.com/jroimartin/gocui"
+	"github.com/urfave/cli"
+)
+
+func alListCui(ctx *cli.Context) error {
+	al, err := loadAniList(ctx)
+	if err != nil {
+		return err
+	}
+	cfg := LoadConfig()
+
+	lc := &listCui{Al: al, Client: al.Client(), Cfg: cfg, Sorting: cfg.Sorting}
+	lc.listLayout = listLayout{
+		HeaderView:    lcTabsView,
+		ListView:      lcListView,
+		DetailsView:   lcDetailsView,
+		ShortcutsView: lcShortcutsView,
+		Editor:        gocui.EditorFunc(lc.listEditor),
+		Shortcuts:     lcShortcuts,
+		Redraw:        lc.redraw,
+	}
+	for i, status := range lcTabs {
+		if status == cfg.ALStatus {
+			lc.Tab = i
+		}
+	}
+	lc.updateDisplayed()
+
+	// Other TUIs (nyaa) can't run inside this one, so the gui is closed for the time they run
+	for {
+		next, err := lc.run()
+		if err != nil || next == nil {
+			return err
+		}
+		if err := next(); err != nil {
+			return err
+		}
+	}
+}
+
+const (
+	lcTabsView      = "lcTabsView"
+	lcListView      = "lcListView"
+	lcDetailsView   = "lcDetailsView"
+	lcShortcutsView = "lcShortcutsView"
+)
+
+var lcTabs = []anilist.MediaListStatus{
+	anilist.All,
+	anilist.Current,
+	anilist.Planning,
+	anilist.Completed,
+	anilist.Paused,
+	anilist.Dropped,
+	anilist.Repeating,
+}
+
+var lcShortcuts = []string{
+	"h/l", "tab",
+	"o", "sort",
+	"+/-", "progress",
+	"s", "score",
+	"t", "status",
+	"D", "delete",
+	"enter", "select",
+	"n", "nyaa",
+	"q", "quit",
+}
+
+var lcSortings = []Sorting{ByLastUpdated, ByTitle, ByWatchedEpisodes, ByScore}
+
+var lcSortingNames = map[Sorting]string{
+	ByLastUpdated:     "last updated",
+	ByTitle:           "title",
+	ByWatchedEpisodes: "progress",
+	ByScore:           "score",
+}
+
+type listCui struct {
+	listLayout
+
+	Al     *AniList
+	Client *anilist.Client
+	Cfg    *Config
+	Gui    *gocui.Gui
+
+	Tab       int
+	Sorting   Sorting
+	Displayed []*anilist.MediaListEntry
+	SelIdx    int
+
+	// Shown in the tabs bar, e.g. result of the last change
+	Message string
+	// Set while a change is being sent to AniList, edits are ignored until it's done
+	Busy bool
+
+	// Run after the gui is closed, then the gui is started again
+	next func() error
+}
+
+func (lc *listCui) run() (func() error, error) {
+	gui, err := gocui.NewGui(gocui.Output256)
+	if err != nil {
+		return nil, fmt.Errorf("gocui error: %v", err)
+	}
+	defer gui.Close()
+
+	lc.Gui = gui
+	lc.next = nil
+	lc.width, lc.height = 0, 0
+
+	gui.SetManager(lc)
+	gui.SetKeybinding("", gocui.KeyCtrlC, gocui.ModNone, quitGocui)
+
+	gui.Cursor = false
+	gui.Mouse = false
+	gui.Highlight = true
+	gui.SelFgColor = gocui.ColorGreen
+
+	if err := gui.MainLoop(); err != nil && err != gocui.ErrQuit {
+		return nil, err
+	}
+	return lc.next, nil
+}
+
+// Recomputes entries of the current tab, keeping the highlighted entry if it's still there
+func (lc *listCui) updateDisplayed() {
+	selectedListId := 0
+	if entry := lc.selected(); entry != nil {
+		selectedListId = entry.ListId
+	}
+
+	status := lcTabs[lc.Tab]
+	lc.Displayed = make([]*anilist.MediaListEntry, 0, len(lc.Al.List))
+	for i := range lc.Al.List {
+		if status == anilist.All || lc.Al.List[i].Status == status {
+			lc.Displayed = append(lc.Displayed, &lc.Al.List[i])
+		}
+	}
+
+	d := lc.Displayed
+	sort.SliceStable(d, func(i, j int) bool {
+		switch lc.Sorting {
+		case ByTitle:
+			return strings.ToLower(d[i].Title.UserPreferred) < strings.ToLower(d[j].Title.UserPreferred)
+		case ByWatchedEpisodes:
+			return d[i].Progress > d[j].Progress
+		case ByScore:
+			return d[i].Score > d[j].Score
+		default:
+			return d[i].UpdatedAt > d[j].UpdatedAt
+		}
+	})
+
+	lc.SelIdx = 0
+	for i, entry := range d {
+		if entry.ListId == selectedListId {
+			lc.SelIdx = i
+			break
+		}
+	}
+}
+
+func (lc *listCui) selected() *anilist.MediaListEntry {
+	if lc.SelIdx < 0 || lc.SelIdx >= len(lc.Displayed) {
+		return nil
+	}
+	return lc.Displayed[lc.SelIdx]
+}
+
+func (lc *listCui) redraw() {
+	lc.drawTabs()
+	lc.drawList()
+	lc.drawDetails()
+}
+
+func (lc *listCui) drawTabs() {
+	v, err := lc.Gui.View(lcTabsView)
+	if err != nil {
+		return
+	}
+	v.Clear()
+
+	counts := make(map[anilist.MediaListStatus]int)
+	for _, entry := range lc.Al.List {
+		counts[entry.Status]++
+	}
+	counts[anilist.All] = len(lc.Al.List)
+
+	for i, status := range lcTabs {
+		name := "All"
+		if status != anilist.All {
+			name = alStatusString(status, lc.Al.MediaType)
+		}
+		tab := fmt.Sprintf(" %s (%d) ", name, counts[status])
+		if i == lc.Tab {
+			tab = color.New(color.FgBlack, color.BgYellow).Sprint(tab)
+		}
+		fmt.Fprint(v, tab, " ")
+	}
+	fmt.Fprint(v, "| sort: ", cyan(lcSortingNames[lc.Sorting]))
+	if lc.Message != "" {
+		fmt.Fprint(v, " | ", boldYellow(lc.Message))
+	}
+}
+
+func (lc *listCui) drawList() {
+	v, err := lc.Gui.View(lcListView)
+	if err != nil {
+		return
+	}
+	v.Clear()
+
+	w, _ := v.Size()
+	titleW := w - 1 - 10 - 6
+	selectedID := lc.Cfg.ALSelected(lc.Al.MediaType)
+	for _, entry := range lc.Displayed {
+		marker := " "
+		if entry.Id == selectedID {
+			marker = "*"
+		}
+		fmt.Fprintf(v, "%s%-*.*s%10s%6s\n", marker, titleW, titleW, entry.Title.UserPreferred,
+			fmt.Sprintf("%d/%d", entry.Progress, entry.Length()),
+			formatScore(entry.Score, lc.Al.User.MediaListOptions.ScoreFormat))
+	}
+
+	scrollToSelection(v, lc.SelIdx)
+}
+
+func (lc *listCui) drawDetails() {
+	v, err := lc.Gui.View(lcDetailsView)
+	if err != nil {
+		return
+	}
+	v.Clear()
+
+	entry := lc.selected()
+	if entry == nil {
+		fmt.Fprintln(v, "No entries")
+		return
+	}
+	mediaType := anilist.MediaType(entry.Type)
+	scoreFormat := lc.Al.User.MediaListOptions.ScoreFormat
+
+	fmt.Fprintln(v, boldYellow(entry.Title.UserPreferred))
+	for _, title := range []string{entry.Title.English, entry.Title.Native} {
+		if title != "" && title != entry.Title.UserPreferred {
+			fmt.Fprintln(v, title)
+		}
+	}
+	fmt.Fprintln(v)
+	fmt.Fprintln(v, "Status:", cyan(alStatusString(entry.Status, mediaType)))
+	fmt.Fprintf(v, "%s: %s\n", alProgressUnit(entry), cyan(fmt.Sprintf("%d/%d", entry.Progress, entry.Length())))
+	if mediaType == anilist.Manga {
+		fmt.Fprintln(v, "Volumes:", cyan(fmt.Sprintf("%d/%d", entry.ProgressVolumes, entry.Volumes)))
+	}
+	fmt.Fprintln(v, "Score:", cyan(formatScore(entry.Score, scoreFormat)))
+	if advanced := formatAdvancedScores(entry.AdvancedScores, scoreFormat); advanced != "" {
+		fmt.Fprintln(v, "  "+advanced)
+	}
+	if entry.Repeat > 0 {
+		fmt.Fprintln(v, "Repeat:", cyan(entry.Repeat))
+	}
+	fmt.Fprintln(v)
+	fmt.Fprintln(v, "Format:", strings.Replace(entry.Format, "_", " ", -1))
+	fmt.Fprintln(v, "Airing status:", strings.Replace(entry.MediaDeficient.Status, "_", " ", -1))
+	if entry.Season != "" {
+		fmt.Fprintln(v, "Season:", strings.TrimSpace(entry.Season+" "+yearString(entry.SeasonYear)))
+	}
+	if entry.StartedAt != nil && !entry.StartedAt.IsZero() {
+		fmt.Fprintln(v, "Started:", entry.StartedAt)
+	}
+	if entry.CompletedAt != nil && !entry.CompletedAt.IsZero() {
+		fmt.Fprintln(v, "Completed:", entry.CompletedAt)
+	}
+	if entry.Private {
+		fmt.Fprintln(v, "Private")
+	}
+	if customLists := entry.CustomListNames(); len(customLists) > 0 {
+		fmt.Fprintln(v, "Custom lists:", cyan(strings.Join(customLists, ", ")))
+	}
+	if entry.Notes != "" {
+		fmt.Fprintln(v)
+		fmt.Fprintln(v, entry.Notes)
+	}
+	fmt.Fprintln(v, "Last updated:", time.Unix(int64(entry.UpdatedAt), 0).Format("15:04 02-01-2006"))
+	fmt.Fprintln(v, "AniList id:", entry.Id)
+}
+
+func yearString(year int) string {
+	if year == 0 {
+		return ""
+	}
+	return strconv.Itoa(year)
+}
+
+func (lc *listCui) listEditor(v *gocui.View, key gocui.Key, ch rune, mod gocui.Modifier) {
+	if delta, ok := listNavigation(v, key, ch, len(lc.Displayed)); ok {
+		lc.moveSelection(delta)
+		return
+	}
+	switch {
+	case key == gocui.KeyArrowRight || key == gocui.KeyTab || ch == 'l':
+		lc.switchTab(1)
+	case key == gocui.KeyArrowLeft || ch == 'h':
+		lc.switchTab(-1)
+	case ch == 'o':
+		lc.cycleSorting()
+	case ch == '+' || ch == '=':
+		lc.changeProgress(1)
+	case ch == '-':
+		lc.changeProgress(-1)
+	case ch == 's':
+		lc.chooseScore()
+	case ch == 't':
+		lc.chooseStatus()
+	case ch == 'D':
+		lc.confirmDelete()
+	case key == gocui.KeyEnter:
+		lc.selectEntry()
+	case ch == 'n':
+		lc.openNyaa()
+	case ch == 'q':
+		lc.Gui.Update(func(gui *gocui.Gui) error {
+			return gocui.ErrQuit
+		})
+	}
+}
+
+func (lc *listCui) moveSelection(delta int) {
+	lc.SelIdx = moveListSelection(lc.SelIdx, delta, len(lc.Displayed))
+	lc.drawList()
+	lc.drawDetails()
+}
+
+func (lc *listCui) switchTab(delta int) {
+	lc.Tab = (lc.Tab + delta + len(lcTabs)) % len(lcTabs)
+	lc.updateDisplayed()
+	lc.redraw()
+}
+
+func (lc *listCui) cycleSorting() {
+	for i, sorting := range lcSortings {
+		if sorting == lc.Sorting {
+			lc.Sorting = lcSortings[(i+1)%len(lcSortings)]
+			break
+		}
+	}
+	lc.updateDisplayed()
+	lc.redraw()
+}
+
+func (lc *listCui) changeProgress(delta int) {
+	entry := lc.selected()
+	if entry == nil || lc.Busy {
+		return
+	}
+	updated := *entry
+	updated.Progress += delta
+	if length := updated.Length(); length > 0 && updated.Progress > length || updated.Progress < 0 {
+		return
+	}
+	if delta > 0 {
+		alStatusAutoUpdate(lc.Cfg, &updated)
+	}
+	lc.save(updated)
+}
+
+func (lc *listCui) chooseScore() {
+	entry := lc.selected()
+	if entry == nil || lc.Busy {
+		return
+	}
+	scoreFormat := lc.Al.User.MediaListOptions.ScoreFormat
+
+	input, cleanUp, err := dialog.InputDialog(lc.Gui,
+		fmt.Sprintf("Score (0-%v)", scoreFormatMax(scoreFormat)),
+		formatScore(entry.Score, scoreFormat))
+	if err != nil {
+		gocuiReturnError(lc.Gui, err)
+		return
+	}
+	go func() {
+		text, ok := <-input
+		lc.Gui.Update(cleanUp)
+		if !ok {
+			return
+		}
+		score, err := parseScore(text, scoreFormat)
+		if err != nil {
+			dialog.JustShowOkDialog(lc.Gui, "Error", err.Error())
+			return
+		}
+		lc.Gui.Update(func(gui *gocui.Gui) error {
+			updated := *entry
+			updated.Score = score
+			lc.save(updated)
+			return nil
+		})
+	}()
+}
+
+func (lc *listCui) chooseStatus() {
+	entry := lc.selected()
+	if entry == nil || lc.Busy {
+		return
+	}
+	statuses := lcTabs[1:]
+	names := make([]string, len(statuses))
+	for i, status := range statuses {
+		names[i] = alStatusString(status, lc.Al.MediaType)
+	}
+
+	selIdxChan, cleanUp, err := dialog.ListSelect(lc.Gui, "Status", names, false)
+	if err != nil {
+		gocuiReturnError(lc.Gui, err)
+		return
+	}
+	go func() {
+		idxs, ok := <-selIdxChan
+		lc.Gui.Update(cleanUp)
+		if !ok {
+			return
+		}
+		lc.Gui.Update(func(gui *gocui.Gui) error {
+			updated := *entry
+			updated.Status = statuses[idxs[0]]
+			alAutoFillDates(&updated)
+			lc.save(updated)
+			return nil
+		})
+	}()
+}
+
+// Sends the updated entry to AniList in the background and puts it in place of the cached one
+func (lc *listCui) save(updated anilist.MediaListEntry) {
+	lc.Busy = true
+	lc.Message = "Saving " + updated.Title.UserPreferred
+	lc.drawTabs()
+
+	go func() {
+		pending := 0
+		queued, err := alSaveEntryWith(lc.Al, &updated, lc.Client.SaveMediaListEntry,
+			func(p int) { pending = p })
+
+		lc.Gui.Update(func(gui *gocui.Gui) error {
+			lc.Busy = false
+			if err != nil {
+				lc.Message = ""
+				lc.drawTabs()
+				dialog.JustShowOkDialog(gui, "Error", err.Error())
+				return nil
+			}
+			if entry := alGetEntryByListId(lc.Al.List, updated.ListId); entry != nil {
+				*entry = updated
+			}
+			if err := saveAniListLists(lc.Al); err != nil {
+				return err
+			}
+
+			if queued {
+				lc.Message = fmt.Sprintf("AniList unreachable, saved locally (%d pending)", pending)
+			} else {
+				lc.Message = "Saved " + updated.Title.UserPreferred
+			}
+			lc.updateDisplayed()
+			lc.redraw()
+			return nil
+		})
+	}()
+}
+
+func (lc *listCui) confirmDelete() {
+	entry := lc.selected()
+	if entry == nil || lc.Busy {
+		return
+	}
+	selIdxChan, cleanUp, err := dialog.ListSelect(lc.Gui, "Delete "+entry.Title.UserPreferred+"?",
+		[]string{"No", "Yes"}, false)
+	if err != nil {
+		gocuiReturnError(lc.Gui, err)
+		return
+	}
+	go func() {
+		idxs, ok := <-selIdxChan
+		lc.Gui.Update(cleanUp)
+		if ok && idxs[0] == 1 {
+			lc.Gui.Update(func(gui *gocui.Gui) error {
+				lc.delete(*entry)
+				return nil
+			})
+		}
+	}()
+}
+
+func (lc *listCui) delete(entry anilist.MediaListEntry) {
+	lc.Busy = true
+	lc.Message = "Deleting " + entry.Title.UserPreferred
+	lc.drawTabs()
+
+	go func() {
+		pending := 0
+		queued, err := alRemoveEntryWith(lc.Al, &entry, lc.Client.DeleteMediaListEntry,
+			func(p int) { pending = p })
+
+		lc.Gui.Update(func(gui *gocui.Gui) error {
+			lc.Busy = false
+			if err != nil {
+				lc.Message = ""
+				lc.drawTabs()
+				dialog.JustShowOkDialog(gui, "Error", err.Error())
+				return nil
+			}
+			// Pointers to the list entries are invalidated by the deletion
+			lc.Displayed = nil
+			lc.Al.List = lc.Al.List.DeleteById(entry.ListId)
+			if err := saveAniListLists(lc.Al); err != nil {
+				return err
+			}
+
+			if queued {
+				lc.Message = fmt.Sprintf("AniList unreachable, deleted locally (%d pending)", pending)
+			} else {
+				lc.Message = "Deleted " + entry.Title.UserPreferred
+			}
+			selIdx := lc.SelIdx
+			lc.updateDisplayed()
+			if lc.SelIdx = selIdx; lc.SelIdx >= len(lc.Displayed) {
+				lc.SelIdx = len(lc.Displayed) - 1
+			}
+			if lc.SelIdx < 0 {
+				lc.SelIdx = 0
+			}
+			lc.redraw()
+			return nil
+		})
+	}()
+}
+
+func (lc *listCui) selectEntry() {
+	entry := lc.selected()
+	if entry == nil {
+		return
+	}
+	lc.Cfg.SetALSelected(lc.Al.MediaType, entry.Id)
+	lc.Cfg.Save()
+	lc.Message = "Selected " + entry.Title.UserPreferred
+	lc.redraw()
+}
+
+func (lc *listCui) openNyaa() {
+	entry := lc.selected()
+	if entry == nil {
+		return
+	}
+	searchTerm := entry.Title.UserPreferred
+	if alt := findCustomAlt(lc.Cfg, entry.Id); alt != nil {
+		searchTerm = *alt
+	}
+	displayedInfo := fmt.Sprintf("%s %d/%d", searchTerm, entry.Progress, entry.Length())
+
+	lc.next = func() error {
+		return startNyaaCui(lc.Cfg, searchTerm, displayedInfo, lc.Cfg.NyaaQuality)
+	}
+	lc.Gui.Update(func(gui *gocui.Gui) error {
+		return gocui.ErrQuit
+	})
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/jroimartin/gocui"
+)
+
+// Layout shared by TUIs browsing a list: header bar on top, the list with details of
+// the selected item next to it and shortcuts at the bottom
+type listLayout struct {
+	HeaderView    string
+	ListView      string
+	DetailsView   string
+	ShortcutsView string
+
+	// Handles keys pressed in the list view
+	Editor gocui.Editor
+	// Keys followed by their descriptions
+	Shortcuts []string
+	// Called on the first layout and whenever the terminal is resized
+	Redraw func()
+
+	width  int
+	height int
+}
+
+func (ll *listLayout) Layout(gui *gocui.Gui) error {
+	w, h := gui.Size()
+	listW := w * 3 / 5
+
+	if _, err := gui.SetView(ll.HeaderView, 0, 0, w-1, 2); err != nil && err != gocui.ErrUnknownView {
+		return err
+	}
+	if v, err := gui.SetView(ll.ListView, 0, 3, listW-1, h-4); err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		v.SelBgColor = gocui.ColorGreen
+		v.SelFgColor = gocui.ColorBlack
+		v.Highlight = true
+		v.Editable = true
+		v.Editor = ll.Editor
+		gui.SetCurrentView(ll.ListView)
+	}
+	if v, err := gui.SetView(ll.DetailsView, listW, 3, w-1, h-4); err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		v.Title = "Details"
+		v.Wrap = true
+	}
+	if v, err := gui.SetView(ll.ShortcutsView, 0, h-3, w-1, h-1); err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		v.Title = "Shortcuts"
+
+		c := color.New(color.FgCyan).SprintFunc()
+		shortcuts := make([]interface{}, len(ll.Shortcuts))
+		for i, s := range ll.Shortcuts {
+			if i%2 == 0 {
+				shortcuts[i] = c(s)
+			} else {
+				shortcuts[i] = s
+			}
+		}
+		fmt.Fprintln(v, shortcuts...)
+	}
+
+	if w != ll.width || h != ll.height {
+		ll.width, ll.height = w, h
+		ll.Redraw()
+	}
+	return nil
+}
+
+// Returns how far a navigation key moves the selection in a list of count items,
+// ok is false for other keys
+func listNavigation(v *gocui.View, key gocui.Key, ch rune, count int) (delta int, ok bool) {
+	_, h := v.Size()
+	switch {
+	case key == gocui.KeyArrowDown || ch == 'j':
+		return 1, true
+	case key == gocui.KeyArrowUp || ch == 'k':
+		return -1, true
+	case key == gocui.KeyPgdn:
+		return h, true
+	case key == gocui.KeyPgup:
+		return -h, true
+	case ch == 'g':
+		return -count, true
+	case ch == 'G':
+		return count, true
+	}
+	return 0, false
+}
+
+// Moves the selection by delta, keeping it within count items
+func moveListSelection(selIdx, delta, count int) int {
+	if count == 0 {
+		return selIdx
+	}
+	selIdx += delta
+	if selIdx < 0 {
+		return 0
+	} else if selIdx >= count {
+		return count - 1
+	}
+	return selIdx
+}
+
+// Scrolls the list view to the selected line and puts the cursor on it
+func scrollToSelection(v *gocui.View, selIdx int) {
+	_, h := v.Size()
+	_, oy := v.Origin()
+	if selIdx < oy {
+		oy = selIdx
+	} else if selIdx >= oy+h {
+		oy = selIdx - h + 1
+	}
+	v.SetOrigin(0, oy)
+	v.SetCursor(0, selIdx-oy)
+}
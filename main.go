@@ -18,9 +18,16 @@ var (
 	MalStatsCacheFile  = filepath.Join(dataDir, "malStats.xml")
 	MalConfigFile      = filepath.Join(dataDir, "malConfig.json")
 
-	AniListCredsFile = filepath.Join(dataDir, "aniListCreds.json")
-	AniListUserFile  = filepath.Join(dataDir, "aniListUser.json")
-	AniListCacheFile = filepath.Join(dataDir, "aniListCache.json")
+	AniListCredsFile      = filepath.Join(dataDir, "aniListCreds.json")
+	AniListUserFile       = filepath.Join(dataDir, "aniListUser.json")
+	AniListCacheFile      = filepath.Join(dataDir, "aniListCache.json")
+	AniListPendingOpsFile = filepath.Join(dataDir, "aniListPendingOps.json")
+
+	AniListSeenNotificationsFile = filepath.Join(dataDir, "aniListSeenNotifications.json")
+	AniListMediaMetadataFile     = filepath.Join(dataDir, "aniListMediaMetadata.json")
+	AniListCoversDir             = filepath.Join(dataDir, "covers")
+
+	AniListMangaCacheFile = filepath.Join(dataDir, "aniListMangaCache.json")
 )
 
 type Mode uint
@@ -31,14 +38,17 @@ const (
 )
 
 type AppConfig struct {
-	Mode Mode
+	Mode    Mode
+	Profile string
 }
 
 func main() {
 	checkDataDir()
 
-	appCfg := AppConfig{Mode: AniListMode}
-	LoadJsonFile(AppConfigFile, &appCfg)
+	appCfg, err := loadAppConfig()
+	if err != nil {
+		exitWithError(err)
+	}
 
 	app := cli.NewApp()
 	app.Name = "mal"
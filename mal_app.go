@@ -55,6 +55,7 @@ func MalApp(app *cli.App) *cli.App {
 			Name:  "reversed",
 			Usage: "reversed list order",
 		},
+		profileFlag,
 	}
 
 	app.Commands = []cli.Command{
@@ -276,8 +277,10 @@ func MalApp(app *cli.App) *cli.App {
 				},
 			},
 		},
+		profileCommand,
 	}
 
+	app.Before = selectProfile
 	app.Action = cli.ActionFunc(malDefaultAction)
 
 	return app
@@ -49,23 +49,17 @@ func alNyaaCui(ctx *cli.Context) error {
 	}
 	cfg := LoadConfig()
 
-	entry := al.GetMediaListById(cfg.ALSelectedID)
+	entry := al.GetMediaListById(cfg.ALSelected(al.MediaType))
 	if entry == nil {
 		return fmt.Errorf("no entry found")
 	}
 
 	if alt := ctx.String("custom"); alt != "" {
-		addCustomAlt(alt+" "+strings.Join(ctx.Args(), " "), cfg)
+		addCustomAlt(alt+" "+strings.Join(ctx.Args(), " "), entry.Id, cfg)
 		return nil
 	}
 
-	var customAlt *string = nil
-	for _, alt := range cfg.NyaaAlts {
-		if alt.Id == entry.Id {
-			customAlt = &alt.Query
-			break
-		}
-	}
+	customAlt := findCustomAlt(cfg, entry.Id)
 
 	searchTerm := entry.Title.UserPreferred
 	if ctx.Bool("alt") {
@@ -96,18 +90,28 @@ func alNyaaCui(ctx *cli.Context) error {
 	return nil
 }
 
-func addCustomAlt(newAlt string, cfg *Config) {
+// Returns custom nyaa search query set for the entry with given id, nil if there's none
+func findCustomAlt(cfg *Config, id int) *string {
+	for i := range cfg.NyaaAlts {
+		if cfg.NyaaAlts[i].Id == id {
+			return &cfg.NyaaAlts[i].Query
+		}
+	}
+	return nil
+}
+
+func addCustomAlt(newAlt string, id int, cfg *Config) {
 	// Assumes the entry ID is valid
 	defer cfg.Save()
 	for i, alt := range cfg.NyaaAlts {
-		if alt.Id == cfg.ALSelectedID {
+		if alt.Id == id {
 			cfg.NyaaAlts[i].Query = newAlt
 			return
 		}
 	}
 
 	cfg.NyaaAlts = append(cfg.NyaaAlts, NyaaAlt{
-		Id:    cfg.ALSelectedID,
+		Id:    id,
 		Query: newAlt,
 	})
 }
@@ -0,0 +1,260 @@
+package oauth2
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Shows the authorization url to the user and returns whatever url the user pasted back
+type Prompt func(authUrl string) (string, error)
+
+// ReaderPrompt returns a Prompt that prints instructions to out and reads the answer from in
+func ReaderPrompt(in io.Reader, out io.Writer) Prompt {
+	return func(authUrl string) (string, error) {
+		fmt.Fprintf(out, "Open the following url in a browser on any device and authorize the app:\n\n%s\n\n", authUrl)
+		fmt.Fprint(out, "Paste the url you were redirected to (even if the page failed to load): ")
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && line == "" {
+			return "", errors.Wrap(err, "reading redirect url")
+		}
+		return strings.TrimSpace(line), nil
+	}
+}
+
+type AuthCodeConfig struct {
+	AuthUrl      string
+	TokenUrl     string
+	ClientID     uint
+	ClientSecret string
+
+	// Address for the local server receiving the redirect, e.g. "localhost:42505".
+	// Port 0 picks a random free port.
+	ListenAddr string
+	// Redirect uri registered for the client. Defaults to http://<ListenAddr>/oauth2
+	RedirectUri string
+
+	BrowserPath string
+	// Used instead of starting the browser when set
+	OpenBrowser func(url string) error
+
+	// Used when set instead of the local server (for sessions where no browser can be opened)
+	Prompt Prompt
+
+	HttpClient *http.Client
+}
+
+func (cfg *AuthCodeConfig) httpClient() *http.Client {
+	if cfg.HttpClient != nil {
+		return cfg.HttpClient
+	}
+	return http.DefaultClient
+}
+
+// Obtains a token using the authorization code grant with PKCE (RFC 7636).
+// Unless cfg.Prompt is set, a local server is started to receive the redirect.
+func OAuthAuthorizationCodeAuth(cfg AuthCodeConfig) (OAuthToken, error) {
+	verifier, err := randomString(64)
+	if err != nil {
+		return OAuthToken{}, err
+	}
+	state, err := randomString(16)
+	if err != nil {
+		return OAuthToken{}, err
+	}
+
+	if cfg.Prompt != nil {
+		if cfg.RedirectUri == "" {
+			cfg.RedirectUri = "http://" + cfg.ListenAddr + "/oauth2"
+		}
+		redirected, err := cfg.Prompt(authCodeUrl(&cfg, state, verifier))
+		if err != nil {
+			return OAuthToken{}, err
+		}
+		u, err := url.Parse(redirected)
+		if err != nil {
+			return OAuthToken{}, errors.Wrap(err, "invalid url")
+		}
+		code, err := authorizationCode(u.Query(), state)
+		if err != nil {
+			return OAuthToken{}, err
+		}
+		return exchangeCode(&cfg, code, verifier)
+	}
+
+	listener, err := net.Listen("tcp", cfg.ListenAddr)
+	if err != nil {
+		return OAuthToken{}, errors.Wrap(err, "HTTP server error")
+	}
+	if cfg.RedirectUri == "" {
+		cfg.RedirectUri = "http://localhost:" +
+			strconv.Itoa(listener.Addr().(*net.TCPAddr).Port) + "/oauth2"
+	}
+
+	codeC := make(chan string, 1)
+	errC := make(chan error, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth2", func(w http.ResponseWriter, r *http.Request) {
+		code, err := authorizationCode(r.URL.Query(), state)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Write([]byte("Authorized successfully, you can close this page"))
+		select {
+		case codeC <- code:
+		default:
+		}
+	})
+	srv := http.Server{Handler: mux}
+	go func() {
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			errC <- err
+		}
+	}()
+	defer srv.Shutdown(context.Background())
+
+	authUrl := authCodeUrl(&cfg, state, verifier)
+	if cfg.OpenBrowser != nil {
+		err = cfg.OpenBrowser(authUrl)
+	} else {
+		err = openBrowser(authUrl, cfg.BrowserPath)
+	}
+	if err != nil {
+		return OAuthToken{}, err
+	}
+
+	select {
+	case code := <-codeC:
+		return exchangeCode(&cfg, code, verifier)
+	case err := <-errC:
+		return OAuthToken{}, errors.Wrap(err, "HTTP server error")
+	}
+}
+
+// Exchanges a refresh token for a new access token
+func RefreshOAuthToken(cfg AuthCodeConfig, token OAuthToken) (OAuthToken, error) {
+	if token.RefreshToken == "" {
+		return token, errors.New("no refresh token")
+	}
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", token.RefreshToken)
+	newToken, err := requestToken(&cfg, form)
+	if err == nil && newToken.RefreshToken == "" {
+		// The server may keep the old refresh token valid without issuing a new one
+		newToken.RefreshToken = token.RefreshToken
+	}
+	return newToken, err
+}
+
+func authCodeUrl(cfg *AuthCodeConfig, state, verifier string) string {
+	challenge := sha256.Sum256([]byte(verifier))
+
+	params := url.Values{}
+	params.Set("client_id", strconv.FormatUint(uint64(cfg.ClientID), 10))
+	params.Set("redirect_uri", cfg.RedirectUri)
+	params.Set("response_type", "code")
+	params.Set("state", state)
+	params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(cfg.AuthUrl, "?") {
+		separator = "&"
+	}
+	return cfg.AuthUrl + separator + params.Encode()
+}
+
+func authorizationCode(query url.Values, state string) (string, error) {
+	if e := query.Get("error"); e != "" {
+		return "", fmt.Errorf("authorization denied: %s %s", e, query.Get("error_description"))
+	}
+	if query.Get("state") != state {
+		return "", errors.New("state mismatch")
+	}
+	code := query.Get("code")
+	if code == "" {
+		return "", errors.New("no authorization code received")
+	}
+	return code, nil
+}
+
+func exchangeCode(cfg *AuthCodeConfig, code, verifier string) (OAuthToken, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", cfg.RedirectUri)
+	form.Set("code_verifier", verifier)
+	return requestToken(cfg, form)
+}
+
+func requestToken(cfg *AuthCodeConfig, form url.Values) (OAuthToken, error) {
+	form.Set("client_id", strconv.FormatUint(uint64(cfg.ClientID), 10))
+	if cfg.ClientSecret != "" {
+		form.Set("client_secret", cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, cfg.TokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return OAuthToken{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := cfg.httpClient().Do(req)
+	if err != nil {
+		return OAuthToken{}, err
+	}
+	defer resp.Body.Close()
+
+	respData := struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		ExpiresIn        int    `json:"expires_in"`
+		RefreshToken     string `json:"refresh_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&respData); err != nil {
+		return OAuthToken{}, errors.Wrapf(err, "token endpoint error (%s)", resp.Status)
+	}
+	if respData.Error != "" {
+		return OAuthToken{}, fmt.Errorf("token endpoint error: %s %s",
+			respData.Error, respData.ErrorDescription)
+	}
+	if respData.AccessToken == "" {
+		return OAuthToken{}, fmt.Errorf("no token received (%s)", resp.Status)
+	}
+
+	return OAuthToken{
+		ClientID:     cfg.ClientID,
+		Token:        respData.AccessToken,
+		Type:         respData.TokenType,
+		ExpireDate:   time.Now().Add(time.Duration(respData.ExpiresIn) * time.Second),
+		RefreshToken: respData.RefreshToken,
+	}, nil
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b)[:n], nil
+}
@@ -0,0 +1,130 @@
+package oauth2
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// Minimal authorization server issuing a single code and validating PKCE
+func newTestAuthServer(t *testing.T) *httptest.Server {
+	var challenge, redirectUri string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+			t.Errorf("unexpected authorize request: %v", q)
+		}
+		challenge = q.Get("code_challenge")
+		redirectUri = q.Get("redirect_uri")
+		http.Redirect(w, r, redirectUri+"?code=test-code&state="+url.QueryEscape(q.Get("state")),
+			http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		resp := map[string]interface{}{"token_type": "Bearer", "expires_in": 3600}
+		switch r.Form.Get("grant_type") {
+		case "authorization_code":
+			sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+			if r.Form.Get("code") != "test-code" ||
+				base64.RawURLEncoding.EncodeToString(sum[:]) != challenge ||
+				r.Form.Get("redirect_uri") != redirectUri {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+				return
+			}
+			resp["access_token"] = "access-1"
+			resp["refresh_token"] = "refresh-1"
+		case "refresh_token":
+			if r.Form.Get("refresh_token") != "refresh-1" {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+				return
+			}
+			resp["access_token"] = "access-2"
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestAuthorizationCodeWithLocalServer(t *testing.T) {
+	srv := newTestAuthServer(t)
+	defer srv.Close()
+
+	token, err := OAuthAuthorizationCodeAuth(AuthCodeConfig{
+		AuthUrl:    srv.URL + "/authorize",
+		TokenUrl:   srv.URL + "/token",
+		ClientID:   1,
+		ListenAddr: "localhost:0",
+		OpenBrowser: func(authUrl string) error {
+			go func() {
+				resp, err := http.Get(authUrl)
+				if err == nil {
+					resp.Body.Close()
+				}
+			}()
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Token != "access-1" || token.RefreshToken != "refresh-1" {
+		t.Errorf("unexpected token: %+v", token)
+	}
+
+	refreshed, err := RefreshOAuthToken(AuthCodeConfig{TokenUrl: srv.URL + "/token", ClientID: 1}, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.Token != "access-2" || refreshed.RefreshToken != "refresh-1" {
+		t.Errorf("unexpected refreshed token: %+v", refreshed)
+	}
+}
+
+func TestAuthorizationCodeWithPrompt(t *testing.T) {
+	srv := newTestAuthServer(t)
+	defer srv.Close()
+
+	noRedirects := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	token, err := OAuthAuthorizationCodeAuth(AuthCodeConfig{
+		AuthUrl:     srv.URL + "/authorize",
+		TokenUrl:    srv.URL + "/token",
+		ClientID:    1,
+		RedirectUri: "http://localhost:42505/oauth2",
+		Prompt: func(authUrl string) (string, error) {
+			resp, err := noRedirects.Get(authUrl)
+			if err != nil {
+				return "", err
+			}
+			resp.Body.Close()
+			return resp.Header.Get("Location"), nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Token != "access-1" {
+		t.Errorf("unexpected token: %+v", token)
+	}
+}
+
+func TestImplicitGrantManual(t *testing.T) {
+	prompt := func(string) (string, error) {
+		return "http://localhost:42505/oauth2#access_token=abc&token_type=Bearer&expires_in=60", nil
+	}
+	token, err := OAuthImplicitGrantAuthManual("http://localhost/authorize", 1, prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Token != "abc" || token.Type != "Bearer" {
+		t.Errorf("unexpected token: %+v", token)
+	}
+}
@@ -3,27 +3,31 @@ package oauth2
 import (
 	"context"
 	"fmt"
-	"github.com/skratchdot/open-golang/open"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
+
 	"github.com/pkg/errors"
+	"github.com/skratchdot/open-golang/open"
 )
 
 type OAuthToken struct {
 	ClientID uint
 
-	Token      string
-	Type       string
-	ExpireDate time.Time
+	Token        string
+	Type         string
+	ExpireDate   time.Time
+	RefreshToken string `json:",omitempty"`
 }
 
 func OAuthImplicitGrantAuth(url, browserPath string, clientID uint, listenPort int) (OAuthToken, error) {
 	tokenC := make(chan OAuthToken)
 
 	listenPortStr := strconv.Itoa(listenPort)
-	srv := http.Server{Addr: ":" + listenPortStr}
-	http.HandleFunc("/oauth2", func(w http.ResponseWriter, r *http.Request) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth2", func(w http.ResponseWriter, r *http.Request) {
 		website := `
 <!DOCTYPE html>
 <html>
@@ -43,46 +47,44 @@ func OAuthImplicitGrantAuth(url, browserPath string, clientID uint, listenPort i
 `
 		w.Write([]byte(website))
 	})
-	http.HandleFunc("/oauth2parsed", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/oauth2parsed", func(w http.ResponseWriter, r *http.Request) {
 		if err := r.ParseForm(); err != nil {
 			w.Write([]byte(err.Error()))
 			return
 		}
-		token := OAuthToken{}
-		token.ClientID = clientID
-		token.Token = r.Form.Get("access_token")
-		token.Type = r.Form.Get("token_type")
-
-		expiresIn, _ := time.ParseDuration(r.Form.Get("expires_in") + "s")
-		token.ExpireDate = time.Now().Add(expiresIn)
-
-		if token.Token == "" {
-			w.Write([]byte("No token received"))
+		token, err := implicitGrantToken(r.Form, clientID)
+		if err != nil {
+			w.Write([]byte(err.Error()))
 			return
 		}
 		w.Write([]byte("Token retrieved successfully"))
 		tokenC <- token
 	})
 
+	listener, err := net.Listen("tcp", ":"+listenPortStr)
+	if err != nil {
+		return OAuthToken{}, errors.Wrap(err, "HTTP server error")
+	}
+	srv := http.Server{Handler: mux}
+	srvErr := make(chan error, 1)
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			panic(fmt.Errorf("HTTP server error: %v\n", err))
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			srvErr <- err
 		}
 	}()
 
 	authUrl := fmt.Sprintf("%s?client_id=%d&response_type=token", url, clientID)
-
-	if browserPath == "" {
-		if err := open.Start(authUrl); err != nil {
-			return OAuthToken{}, errors.Wrap(err, "opening browser error")
-		}
-	} else {
-		if err := open.StartWith(authUrl, browserPath); err != nil {
-			return OAuthToken{}, errors.Wrap(err, "opening browser error")
-		}
+	if err := openBrowser(authUrl, browserPath); err != nil {
+		srv.Close()
+		return OAuthToken{}, err
 	}
 
-	token := <-tokenC
+	var token OAuthToken
+	select {
+	case token = <-tokenC:
+	case err := <-srvErr:
+		return OAuthToken{}, errors.Wrap(err, "HTTP server error")
+	}
 
 	if err := srv.Shutdown(context.Background()); err != nil {
 		return token, err
@@ -90,3 +92,50 @@ func OAuthImplicitGrantAuth(url, browserPath string, clientID uint, listenPort i
 
 	return token, nil
 }
+
+// Headless variant of OAuthImplicitGrantAuth. Instead of listening for the redirect,
+// it asks the user to open the authorization page on any device and paste back the
+// url they got redirected to.
+func OAuthImplicitGrantAuthManual(authUrl string, clientID uint, prompt Prompt) (OAuthToken, error) {
+	redirected, err := prompt(fmt.Sprintf("%s?client_id=%d&response_type=token", authUrl, clientID))
+	if err != nil {
+		return OAuthToken{}, err
+	}
+	u, err := url.Parse(redirected)
+	if err != nil {
+		return OAuthToken{}, errors.Wrap(err, "invalid url")
+	}
+	values, err := url.ParseQuery(u.Fragment)
+	if err != nil {
+		return OAuthToken{}, errors.Wrap(err, "invalid url")
+	}
+	return implicitGrantToken(values, clientID)
+}
+
+func implicitGrantToken(values url.Values, clientID uint) (OAuthToken, error) {
+	token := OAuthToken{}
+	token.ClientID = clientID
+	token.Token = values.Get("access_token")
+	token.Type = values.Get("token_type")
+
+	expiresIn, _ := time.ParseDuration(values.Get("expires_in") + "s")
+	token.ExpireDate = time.Now().Add(expiresIn)
+
+	if token.Token == "" {
+		return token, errors.New("No token received")
+	}
+	return token, nil
+}
+
+func openBrowser(url, browserPath string) error {
+	if browserPath == "" {
+		if err := open.Start(url); err != nil {
+			return errors.Wrap(err, "opening browser error")
+		}
+	} else {
+		if err := open.StartWith(url, browserPath); err != nil {
+			return errors.Wrap(err, "opening browser error")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/aqatl/mal/anilist"
+	"github.com/fatih/color"
+	"github.com/urfave/cli"
+)
+
+const defaultProfile = "default"
+
+var ProfilesDir = filepath.Join(dataDir, "profiles")
+
+var profileNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Files owned by an account; each profile keeps its own copy of them
+func profileDataFiles() []*string {
+	return []*string{
+		&MalCredentialsFile,
+		&MalCacheFile,
+		&MalStatsCacheFile,
+		&MalConfigFile,
+		&AniListCredsFile,
+		&AniListUserFile,
+		&AniListCacheFile,
+		&AniListPendingOpsFile,
+		&AniListSeenNotificationsFile,
+		&AniListMediaMetadataFile,
+		&AniListCoversDir,
+		&AniListMangaCacheFile,
+	}
+}
+
+func profileDir(name string) string {
+	return filepath.Join(ProfilesDir, name)
+}
+
+func profileExists(name string) bool {
+	info, err := os.Stat(profileDir(name))
+	return err == nil && info.IsDir()
+}
+
+// Points account data files to the directory of the given profile
+func useProfile(name string) error {
+	if !profileExists(name) {
+		return fmt.Errorf("profile %s doesn't exist; create it with `mal profile add %s`", name, name)
+	}
+	dir := profileDir(name)
+	for _, f := range profileDataFiles() {
+		*f = filepath.Join(dir, filepath.Base(*f))
+	}
+	return nil
+}
+
+// Moves account data files kept directly in the data directory to the default profile
+func migrateToProfiles(appCfg *AppConfig) error {
+	dir := profileDir(defaultProfile)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("error creating profile directory: %v", err)
+	}
+	for _, f := range profileDataFiles() {
+		if _, err := os.Stat(*f); err != nil {
+			continue
+		}
+		if err := os.Rename(*f, filepath.Join(dir, filepath.Base(*f))); err != nil {
+			return fmt.Errorf("error moving %s to the default profile: %v", *f, err)
+		}
+	}
+	appCfg.Profile = defaultProfile
+	return SaveJsonFile(AppConfigFile, appCfg)
+}
+
+// Loads app config and switches to its profile, migrating old data files if needed.
+// Falls back to the default profile when the current one was removed by hand.
+func loadAppConfig() (AppConfig, error) {
+	appCfg := AppConfig{Mode: AniListMode}
+	LoadJsonFile(AppConfigFile, &appCfg)
+	if appCfg.Profile == "" {
+		if err := migrateToProfiles(&appCfg); err != nil {
+			return appCfg, err
+		}
+	}
+	if !profileExists(appCfg.Profile) {
+		fmt.Fprintf(color.Error, "Profile %s doesn't exist, switching to the %s profile\n",
+			appCfg.Profile, defaultProfile)
+		if err := os.MkdirAll(profileDir(defaultProfile), os.ModePerm); err != nil {
+			return appCfg, fmt.Errorf("error creating profile directory: %v", err)
+		}
+		appCfg.Profile = defaultProfile
+		if err := SaveJsonFile(AppConfigFile, &appCfg); err != nil {
+			return appCfg, err
+		}
+	}
+	return appCfg, useProfile(appCfg.Profile)
+}
+
+var profileFlag = cli.StringFlag{
+	Name:   "profile",
+	Usage:  "use the given account profile instead of the current one",
+	EnvVar: "MAL_PROFILE",
+}
+
+// Switches to the profile given with --profile for this run only
+func selectProfile(ctx *cli.Context) error {
+	if name := ctx.GlobalString("profile"); name != "" {
+		return useProfile(name)
+	}
+	return nil
+}
+
+var profileCommand = cli.Command{
+	Name:     "profile",
+	Category: "Config",
+	Usage:    "Manage account profiles, each with its own login, cache and config",
+	Action:   listProfiles,
+	Subcommands: cli.Commands{
+		cli.Command{
+			Name:      "list",
+			Aliases:   []string{"ls"},
+			Usage:     "List profiles, marking the current one",
+			UsageText: "mal profile list",
+			Action:    listProfiles,
+		},
+		cli.Command{
+			Name:      "add",
+			Usage:     "Create a new profile",
+			UsageText: "mal profile add [--use] [name]",
+			Action:    addProfile,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "use",
+					Usage: "switch to the new profile",
+				},
+			},
+		},
+		cli.Command{
+			Name:      "use",
+			Aliases:   []string{"switch"},
+			Usage:     "Switch the current profile",
+			UsageText: "mal profile use [name]",
+			Action:    switchProfile,
+		},
+		cli.Command{
+			Name:      "remove",
+			Aliases:   []string{"rm"},
+			Usage:     "Remove a profile with its login, cache and config",
+			UsageText: "mal profile remove [--yes] [name]",
+			Action:    removeProfile,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "yes, y",
+					Usage: "don't ask for confirmation",
+				},
+			},
+		},
+	},
+}
+
+func profileNameArg(ctx *cli.Context) (string, error) {
+	name := ctx.Args().First()
+	if name == "" {
+		return "", fmt.Errorf("no profile name given")
+	}
+	if !profileNameRegexp.MatchString(name) {
+		return "", fmt.Errorf("invalid profile name %s; use only letters, digits, - and _", name)
+	}
+	return name, nil
+}
+
+func listProfiles(ctx *cli.Context) error {
+	appCfg := AppConfig{}
+	LoadJsonFile(AppConfigFile, &appCfg)
+
+	dirs, err := ioutil.ReadDir(ProfilesDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	faint := color.New(color.Faint).SprintFunc()
+	current := color.New(color.FgHiYellow).SprintFunc()
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		name := dir.Name()
+		user := anilist.User{}
+		LoadJsonFile(filepath.Join(profileDir(name), filepath.Base(AniListUserFile)), &user)
+		line := "  " + name
+		if name == appCfg.Profile {
+			line = current("* " + name)
+		}
+		if user.Name != "" {
+			line += " " + faint("("+user.Name+")")
+		}
+		fmt.Fprintln(color.Output, line)
+	}
+	return nil
+}
+
+func addProfile(ctx *cli.Context) error {
+	name, err := profileNameArg(ctx)
+	if err != nil {
+		return err
+	}
+	if profileExists(name) {
+		return fmt.Errorf("profile %s already exists", name)
+	}
+	if err := os.MkdirAll(profileDir(name), os.ModePerm); err != nil {
+		return fmt.Errorf("error creating profile directory: %v", err)
+	}
+	fmt.Printf("Created profile %s\n", name)
+	if ctx.Bool("use") {
+		return switchProfile(ctx)
+	}
+	return nil
+}
+
+func switchProfile(ctx *cli.Context) error {
+	name, err := profileNameArg(ctx)
+	if err != nil {
+		return err
+	}
+	if !profileExists(name) {
+		return fmt.Errorf("profile %s doesn't exist", name)
+	}
+	appCfg := AppConfig{}
+	LoadJsonFile(AppConfigFile, &appCfg)
+	appCfg.Profile = name
+	if err := SaveJsonFile(AppConfigFile, &appCfg); err != nil {
+		return err
+	}
+	fmt.Printf("Switched to profile %s\n", name)
+	return nil
+}
+
+func removeProfile(ctx *cli.Context) error {
+	name, err := profileNameArg(ctx)
+	if err != nil {
+		return err
+	}
+	if !profileExists(name) {
+		return fmt.Errorf("profile %s doesn't exist", name)
+	}
+	appCfg := AppConfig{}
+	LoadJsonFile(AppConfigFile, &appCfg)
+	if name == appCfg.Profile {
+		return fmt.Errorf("can't remove the current profile; switch to another one first")
+	}
+	if !ctx.Bool("yes") && !confirm(fmt.Sprintf("Remove profile %s with its login and cache?", name)) {
+		return nil
+	}
+	if err := os.RemoveAll(profileDir(name)); err != nil {
+		return err
+	}
+	fmt.Printf("Removed profile %s\n", name)
+	return nil
+}
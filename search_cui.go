@@ -19,15 +19,13 @@ func alSearch(ctx *cli.Context) error {
 	}
 
 	searchQuery := strings.TrimSpace(strings.Join(ctx.Args(), " "))
-	results, err := anilist.Search(searchQuery, 1, 50, anilist.Anime, al.Token)
+	client := al.Client()
+	results, hasNextPage, err := client.SearchFiltered(
+		searchQuery, anilist.SearchFilters{}, 1, searchPageSize, al.MediaType)
 	if err != nil {
 		return err
 	}
-
-	descriptionReplacer := strings.NewReplacer("<br>", "")
-	for i := range results {
-		results[i].Description = descriptionReplacer.Replace(results[i].Description)
-	}
+	cleanSearchResults(results)
 
 	gui, err := gocui.NewGui(gocui.OutputNormal)
 	defer gui.Close()
@@ -37,9 +35,12 @@ func alSearch(ctx *cli.Context) error {
 
 	sc := &searchCui{
 		Al:          al,
+		Client:      client,
 		Gui:         gui,
 		SearchQuery: searchQuery,
 		Results:     results,
+		Page:        1,
+		HasNextPage: hasNextPage,
 		Mode:        scListView,
 	}
 
@@ -71,11 +72,20 @@ const (
 )
 
 type searchCui struct {
-	Al  *AniList
-	Gui *gocui.Gui
+	Al     *AniList
+	Client *anilist.Client
+	Gui    *gocui.Gui
 
 	SearchQuery string
+	Filters     anilist.SearchFilters
 	Results     []anilist.MediaFull
+	Page        int
+	HasNextPage bool
+	Loading     bool
+	// Incremented on every reload, so pages of an outdated search are dropped
+	generation int
+	// Genre collection, fetched when choosing genres for the first time
+	Genres []string
 
 	Mode   searchCuiMode
 	SelIdx int
@@ -86,21 +96,69 @@ var searchResultHighlight = color.New(color.FgBlack, color.BgYellow)
 var yellowC = color.New(color.FgYellow, color.Bold)
 var cyanC = color.New(color.FgCyan, color.Bold)
 
+const searchPageSize = 50
+
+// Next page is loaded when the selection gets this close to the end of the results
+const searchPrefetchDistance = 5
+
+var searchDescriptionReplacer = strings.NewReplacer("<br>", "")
+
+func cleanSearchResults(results []anilist.MediaFull) {
+	for i := range results {
+		results[i].Description = searchDescriptionReplacer.Replace(results[i].Description)
+	}
+}
+
 // Safe to call from another goroutine
 func (sc *searchCui) reload() {
-	results, err := anilist.Search(sc.SearchQuery, 1, 50, anilist.Anime, sc.Al.Token)
+	results, hasNextPage, err := sc.Client.SearchFiltered(
+		sc.SearchQuery, sc.Filters, 1, searchPageSize, sc.Al.MediaType)
 	if err != nil {
 		dialog.JustShowOkDialog(sc.Gui, "Error",
 			strings.TrimSpace(strings.Replace(err.Error(), "\n", " ", -1)))
 	}
+	cleanSearchResults(results)
 	sc.Gui.Update(func(gui *gocui.Gui) error {
-		sc.Results = results
+		sc.generation++
+		sc.Results, sc.Page, sc.HasNextPage = results, 1, hasNextPage
+		sc.SelIdx, sc.Origin = 0, 0
 		sc.Gui.SetManager(sc)
 		sc.setGuiKeyBindings(sc.Gui)
 		return nil
 	})
 }
 
+// Fetches the next page of results in the background, if there is one
+func (sc *searchCui) loadNextPage() {
+	if !sc.HasNextPage || sc.Loading {
+		return
+	}
+	sc.Loading = true
+	page, generation := sc.Page+1, sc.generation
+	query, filters := sc.SearchQuery, sc.Filters
+
+	go func() {
+		results, hasNextPage, err := sc.Client.SearchFiltered(
+			query, filters, page, searchPageSize, sc.Al.MediaType)
+		cleanSearchResults(results)
+		sc.Gui.Update(func(gui *gocui.Gui) error {
+			sc.Loading = false
+			if generation != sc.generation {
+				return nil
+			}
+			if err != nil {
+				dialog.JustShowOkDialog(gui, "Error", err.Error())
+				return nil
+			}
+			sc.Results = append(sc.Results, results...)
+			sc.Page, sc.HasNextPage = page, hasNextPage
+			// Recreated with the new result count by the next layout
+			gui.DeleteView(scFiltersView)
+			return nil
+		})
+	}()
+}
+
 func (sc *searchCui) setGuiKeyBindings(gui *gocui.Gui) {
 	gui.SetKeybinding("", gocui.KeyCtrlC, gocui.ModNone, quitGocui)
 }
@@ -147,11 +205,16 @@ func (sc *searchCui) listLayout() error {
 			} else {
 				yellowC.Fprintf(v, "%s (%s)\n", result.Title.Romaji, result.Title.English)
 			}
+			length, unit := result.Episodes, "eps"
+			if result.Type == anilist.Manga {
+				length, unit = result.Chapters, "chs"
+			}
 			cyanC.Fprint(v, strings.ToLower(
-				fmt.Sprintf("%s | %s | %d eps | %s %d | %d%% | %v\n",
+				fmt.Sprintf("%s | %s | %d %s | %s %d | %d%% | %v\n",
 					result.Format,
 					result.Status,
-					result.Episodes,
+					length,
+					unit,
 					result.Season,
 					result.StartDate.Year,
 					result.AverageScore,
@@ -163,13 +226,28 @@ func (sc *searchCui) listLayout() error {
 		y += 6
 	}
 
-	if len(sc.Results) > 0 {
+	if len(sc.Results) > 0 && !sc.dialogFocused() {
 		sc.Gui.SetCurrentView(strconv.Itoa(sc.Results[0].Id))
 	}
 
 	return nil
 }
 
+// Dialogs keep the focus until they are closed
+func (sc *searchCui) dialogFocused() bool {
+	v := sc.Gui.CurrentView()
+	if v == nil {
+		return false
+	}
+	if _, err := sc.Gui.View(v.Name()); err != nil {
+		return false
+	}
+	if _, err := strconv.Atoi(v.Name()); err == nil {
+		return false
+	}
+	return v.Name() != scFiltersView && v.Name() != scSearchView
+}
+
 func (sc *searchCui) fullDetailsLayout() error {
 	w, h := sc.Gui.Size()
 
@@ -204,13 +282,53 @@ func (sc *searchCui) filtersView() error {
 
 		v.Editor = sc
 
-		fmt.Fprintln(v, "Search:", sc.SearchQuery)
-		fmt.Fprintln(v, "Results:", len(sc.Results))
+		results := strconv.Itoa(len(sc.Results))
+		if sc.HasNextPage {
+			results += "+"
+		}
+		fmt.Fprintln(v, "Search:", sc.SearchQuery, "| Results:", results)
+		fmt.Fprintln(v, sc.filtersSummary())
+		c := color.New(color.FgCyan).SprintFunc()
+		fmt.Fprintln(v,
+			c("g"), "genres",
+			c("t"), "tags",
+			c("y"), "year/season",
+			c("f"), "format",
+			c("s"), "status",
+			c("r"), "score",
+			c("x"), "adult",
+			c("o"), "sort",
+			c("c"), "clear filters",
+		)
 	}
 
 	return nil
 }
 
+func (sc *searchCui) filtersSummary() string {
+	f := sc.Filters
+	orAny := func(s string) string {
+		if s == "" {
+			return "any"
+		}
+		return strings.ToLower(strings.Replace(s, "_", " ", -1))
+	}
+
+	season := orAny(f.Season)
+	if f.Year > 0 {
+		season = strings.TrimSpace(strings.ToLower(f.Season) + " " + strconv.Itoa(f.Year))
+	}
+	adult := "any"
+	if f.IsAdult != nil && *f.IsAdult {
+		adult = "only"
+	} else if f.IsAdult != nil {
+		adult = "no"
+	}
+	return fmt.Sprintf("Genres: %s | Tags: %s | Season: %s | Format: %s | Status: %s | Score: %s | Adult: %s | Sort: %s",
+		orAny(strings.Join(f.Genres, ", ")), orAny(strings.Join(f.Tags, ", ")), season, orAny(f.Format),
+		orAny(f.Status), orAny(sc.scoreFilterText()), adult, orAny(f.Sort))
+}
+
 func (sc *searchCui) searchView() error {
 	w, h := sc.Gui.Size()
 	v, err := sc.Gui.SetView(scSearchView, 0, h-3, w-1, h-1)
@@ -252,6 +370,45 @@ func (sc *searchCui) Edit(v *gocui.View, key gocui.Key, ch rune, mod gocui.Modif
 			for _, result := range sc.Results {
 				sc.Gui.DeleteView(strconv.Itoa(result.Id))
 			}
+		case ch == 'g':
+			sc.chooseGenres()
+		case ch == 't':
+			sc.inputFilter("Tags (comma separated)", strings.Join(sc.Filters.Tags, ", "), func(text string) error {
+				sc.Filters.Tags = splitFilterList(text)
+				return nil
+			})
+		case ch == 'y':
+			sc.inputFilter("Year and season (e.g. 2019 fall)", sc.seasonFilterText(), sc.setSeasonFilter)
+		case ch == 'f':
+			sc.chooseOption("Format", searchFormats, func(format string) {
+				sc.Filters.Format = format
+			})
+		case ch == 's':
+			sc.chooseOption("Airing status", searchStatuses, func(status string) {
+				sc.Filters.Status = status
+			})
+		case ch == 'r':
+			sc.inputFilter("Average score (e.g. 70-90)", sc.scoreFilterText(), sc.setScoreFilter)
+		case ch == 'x':
+			// any -> no adult media -> only adult media
+			switch {
+			case sc.Filters.IsAdult == nil:
+				adult := false
+				sc.Filters.IsAdult = &adult
+			case !*sc.Filters.IsAdult:
+				adult := true
+				sc.Filters.IsAdult = &adult
+			default:
+				sc.Filters.IsAdult = nil
+			}
+			go sc.reload()
+		case ch == 'o':
+			sc.chooseOption("Sort by", searchSorts, func(sort string) {
+				sc.Filters.Sort = sort
+			})
+		case ch == 'c':
+			sc.Filters = anilist.SearchFilters{}
+			go sc.reload()
 		}
 	case scFullDetailsView:
 		switch {
@@ -280,6 +437,9 @@ func (sc *searchCui) nextResult() {
 		sc.Gui.DeleteView(strconv.Itoa(sc.Results[sc.SelIdx].Id))
 		sc.Gui.DeleteView(strconv.Itoa(sc.Results[sc.SelIdx-1].Id))
 	}
+	if sc.SelIdx >= len(sc.Results)-searchPrefetchDistance {
+		sc.loadNextPage()
+	}
 }
 
 func (sc *searchCui) previousResult() {
@@ -300,7 +460,7 @@ func (sc *searchCui) addEntry() {
 		return
 	}
 
-	entry, err := anilist.AddMediaListEntry(sc.Results[sc.SelIdx].Id, anilist.Planning, sc.Al.Token)
+	entry, err := sc.Client.AddMediaListEntry(sc.Results[sc.SelIdx].Id, anilist.Planning)
 	if err != nil {
 		dialog.JustShowOkDialog(sc.Gui, "Error", err.Error())
 		return
@@ -309,6 +469,188 @@ func (sc *searchCui) addEntry() {
 
 	sc.Al.List = append(sc.Al.List, entry)
 	sc.Gui.Update(func(gui *gocui.Gui) error {
-		return saveAniListAnimeLists(sc.Al)
+		return saveAniListLists(sc.Al)
 	})
 }
+
+var searchFormats = []string{"TV", "TV_SHORT", "MOVIE", "SPECIAL", "OVA", "ONA", "MUSIC", "MANGA", "NOVEL", "ONE_SHOT"}
+var searchStatuses = []string{"RELEASING", "FINISHED", "NOT_YET_RELEASED", "CANCELLED", "HIATUS"}
+var searchSorts = []string{"SEARCH_MATCH", "POPULARITY_DESC", "SCORE_DESC", "TRENDING_DESC", "FAVOURITES_DESC",
+	"START_DATE_DESC", "START_DATE", "TITLE_ROMAJI"}
+
+func splitFilterList(text string) []string {
+	var values []string
+	for _, value := range strings.Split(text, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Lets the user pick one of the options or "Any"; set is called with empty string for "Any"
+func (sc *searchCui) chooseOption(title string, options []string, set func(string)) {
+	names := make([]string, len(options)+1)
+	names[0] = "Any"
+	for i, option := range options {
+		names[i+1] = strings.ToLower(strings.Replace(option, "_", " ", -1))
+	}
+
+	selIdxChan, cleanUp, err := dialog.ListSelect(sc.Gui, title, names, false)
+	if err != nil {
+		gocuiReturnError(sc.Gui, err)
+		return
+	}
+	go func() {
+		idxs, ok := <-selIdxChan
+		// Cleaned up in the same update, so a dialog shown afterwards keeps the focus
+		sc.Gui.Update(func(gui *gocui.Gui) error {
+			if err := cleanUp(gui); err != nil || !ok {
+				return err
+			}
+			if idxs[0] == 0 {
+				set("")
+			} else {
+				set(options[idxs[0]-1])
+			}
+			go sc.reload()
+			return nil
+		})
+	}()
+}
+
+// Asks for a filter value; an error returned by set is shown and the search isn't reloaded
+func (sc *searchCui) inputFilter(title, initial string, set func(string) error) {
+	input, cleanUp, err := dialog.InputDialog(sc.Gui, title, initial)
+	if err != nil {
+		gocuiReturnError(sc.Gui, err)
+		return
+	}
+	go func() {
+		text, ok := <-input
+		sc.Gui.Update(func(gui *gocui.Gui) error {
+			if err := cleanUp(gui); err != nil || !ok {
+				return err
+			}
+			if err := set(text); err != nil {
+				dialog.JustShowOkDialog(gui, "Error", err.Error())
+				return nil
+			}
+			go sc.reload()
+			return nil
+		})
+	}()
+}
+
+// Multiple genres can be selected with space
+func (sc *searchCui) chooseGenres() {
+	if sc.Genres == nil {
+		go func() {
+			genres, err := sc.Client.QueryGenres()
+			sc.Gui.Update(func(gui *gocui.Gui) error {
+				if err != nil {
+					dialog.JustShowOkDialog(gui, "Error", err.Error())
+					return nil
+				}
+				sc.Genres = genres
+				sc.chooseGenres()
+				return nil
+			})
+		}()
+		return
+	}
+
+	names := append([]string{"Any"}, sc.Genres...)
+	selIdxChan, cleanUp, err := dialog.ListSelect(sc.Gui, "Genres", names, true)
+	if err != nil {
+		gocuiReturnError(sc.Gui, err)
+		return
+	}
+	go func() {
+		idxs, ok := <-selIdxChan
+		// Cleaned up in the same update, so a dialog shown afterwards keeps the focus
+		sc.Gui.Update(func(gui *gocui.Gui) error {
+			if err := cleanUp(gui); err != nil || !ok {
+				return err
+			}
+			sc.Filters.Genres = nil
+			for _, idx := range idxs {
+				if idx == 0 {
+					sc.Filters.Genres = nil
+					break
+				}
+				sc.Filters.Genres = append(sc.Filters.Genres, names[idx])
+			}
+			go sc.reload()
+			return nil
+		})
+	}()
+}
+
+func (sc *searchCui) seasonFilterText() string {
+	parts := make([]string, 0, 2)
+	if sc.Filters.Year > 0 {
+		parts = append(parts, strconv.Itoa(sc.Filters.Year))
+	}
+	if sc.Filters.Season != "" {
+		parts = append(parts, strings.ToLower(sc.Filters.Season))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Accepts a year, a season or both in any order; empty text clears the filter
+func (sc *searchCui) setSeasonFilter(text string) error {
+	season, year := "", 0
+	for _, field := range strings.Fields(text) {
+		if y, err := strconv.Atoi(field); err == nil {
+			year = y
+			continue
+		}
+		s, valid := seasonByName(field)
+		if !valid {
+			return fmt.Errorf("invalid season %q; possible values: winter|spring|summer|fall", field)
+		}
+		season = s
+	}
+	sc.Filters.Season, sc.Filters.Year = season, year
+	return nil
+}
+
+func (sc *searchCui) scoreFilterText() string {
+	f := sc.Filters
+	if f.MinScore == 0 && f.MaxScore == 0 {
+		return ""
+	}
+	text := ""
+	if f.MinScore > 0 {
+		text = strconv.Itoa(f.MinScore)
+	}
+	text += "-"
+	if f.MaxScore > 0 {
+		text += strconv.Itoa(f.MaxScore)
+	}
+	return text
+}
+
+// Accepts "70", "70-90", "70-" or "-50"; empty text clears the filter
+func (sc *searchCui) setScoreFilter(text string) error {
+	if text == "" {
+		sc.Filters.MinScore, sc.Filters.MaxScore = 0, 0
+		return nil
+	}
+	min, max, err := parseScoreRange(text)
+	if err != nil {
+		return err
+	}
+	if min > 100 || max > 100 {
+		return fmt.Errorf("average score must be between 0 and 100")
+	}
+	sc.Filters.MinScore, sc.Filters.MaxScore = 0, 0
+	if min > 0 {
+		sc.Filters.MinScore = int(min)
+	}
+	if max > 0 {
+		sc.Filters.MaxScore = int(max)
+	}
+	return nil
+}
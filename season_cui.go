@@ -0,0 +1,410 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aqatl/mal/anilist"
+	"github.com/aqatl/mal/dialog"
+	"github.com/hako/durafmt"
+	"github.com/jroimartin/gocui"
+	"github.com/urfave/cli"
+)
+
+var seasons = []string{"WINTER", "SPRING", "SUMMER", "FALL"}
+
+// AniList seasons start in January, April, July and October
+func currentSeason(now time.Time) (string, int) {
+	return seasons[(int(now.Month())-1)/3], now.Year()
+}
+
+// Parses optional season name and year in any order; missing ones are taken from the current season
+func parseSeasonArgs(args []string, now time.Time) (string, int, error) {
+	season, year := currentSeason(now)
+	for _, arg := range args {
+		if y, err := strconv.Atoi(arg); err == nil {
+			year = y
+			continue
+		}
+		s, valid := seasonByName(arg)
+		if !valid {
+			return "", 0, fmt.Errorf("invalid season %q; possible values: winter|spring|summer|fall", arg)
+		}
+		season = s
+	}
+	return season, year, nil
+}
+
+// Returns AniList season of given case insensitive name; autumn is accepted as fall
+func seasonByName(name string) (string, bool) {
+	for _, s := range seasons {
+		if strings.EqualFold(name, s) || (strings.EqualFold(name, "autumn") && s == "FALL") {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+func alSeason(ctx *cli.Context) error {
+	season, year, err := parseSeasonArgs(ctx.Args(), time.Now())
+	if err != nil {
+		return err
+	}
+	al, err := loadAniListOfType(ctx, anilist.Anime)
+	if err != nil {
+		return err
+	}
+
+	media, err := al.Client().QuerySeasonWaitAnimation(season, year)
+	if err != nil {
+		return err
+	}
+	if len(media) == 0 {
+		fmt.Printf("No anime found for %s %d\n", strings.ToLower(season), year)
+		return nil
+	}
+
+	gui, err := gocui.NewGui(gocui.Output256)
+	if err != nil {
+		return fmt.Errorf("gocui error: %v", err)
+	}
+	defer gui.Close()
+
+	sc := &seasonCui{Al: al, Client: al.Client(), Gui: gui, Season: season, Year: year, Media: media}
+	sc.listLayout = listLayout{
+		HeaderView:    seasonHeaderView,
+		ListView:      seasonListView,
+		DetailsView:   seasonDetailsView,
+		ShortcutsView: seasonShortcutsView,
+		Editor:        gocui.EditorFunc(sc.listEditor),
+		Shortcuts:     seasonShortcuts,
+		Redraw:        sc.redraw,
+	}
+	sc.updateDisplayed()
+
+	gui.SetManager(sc)
+	gui.SetKeybinding("", gocui.KeyCtrlC, gocui.ModNone, quitGocui)
+
+	gui.Cursor = false
+	gui.Mouse = false
+	gui.Highlight = true
+	gui.SelFgColor = gocui.ColorGreen
+
+	if err = gui.MainLoop(); err != nil && err != gocui.ErrQuit {
+		return err
+	}
+	return nil
+}
+
+const (
+	seasonHeaderView    = "seasonHeaderView"
+	seasonListView      = "seasonListView"
+	seasonDetailsView   = "seasonDetailsView"
+	seasonShortcutsView = "seasonShortcutsView"
+)
+
+var seasonShortcuts = []string{
+	"p", "add to planning",
+	"w", "add to watching",
+	"o", "sort",
+	"f", "hide added",
+	"q", "quit",
+}
+
+type seasonSorting int
+
+const (
+	seasonByPopularity seasonSorting = iota
+	seasonByScore
+	seasonByTitle
+	seasonByStartDate
+)
+
+var seasonSortingNames = []string{"popularity", "score", "title", "start date"}
+
+type seasonCui struct {
+	listLayout
+
+	Al     *AniList
+	Client *anilist.Client
+	Gui    *gocui.Gui
+
+	Season string
+	Year   int
+	Media  []anilist.MediaFull
+
+	Displayed []*anilist.MediaFull
+	SelIdx    int
+	Sorting   seasonSorting
+	// Hides anime already on the list
+	HideAdded bool
+
+	Message string
+	Busy    bool
+}
+
+func (sc *seasonCui) updateDisplayed() {
+	selectedId := 0
+	if media := sc.selected(); media != nil {
+		selectedId = media.Id
+	}
+
+	sc.Displayed = make([]*anilist.MediaFull, 0, len(sc.Media))
+	for i := range sc.Media {
+		if !sc.HideAdded || sc.Al.GetMediaListById(sc.Media[i].Id) == nil {
+			sc.Displayed = append(sc.Displayed, &sc.Media[i])
+		}
+	}
+
+	d := sc.Displayed
+	sort.SliceStable(d, func(i, j int) bool {
+		switch sc.Sorting {
+		case seasonByScore:
+			return d[i].AverageScore > d[j].AverageScore
+		case seasonByTitle:
+			return strings.ToLower(d[i].Title.UserPreferred) < strings.ToLower(d[j].Title.UserPreferred)
+		case seasonByStartDate:
+			a, b := d[i].StartDate, d[j].StartDate
+			// Winter seasons include anime started in December of the previous year
+			if a.Year != b.Year {
+				return a.Year < b.Year
+			}
+			if a.Month != b.Month {
+				return a.Month < b.Month
+			}
+			return a.Day < b.Day
+		default:
+			return d[i].Popularity > d[j].Popularity
+		}
+	})
+
+	sc.SelIdx = 0
+	for i, media := range d {
+		if media.Id == selectedId {
+			sc.SelIdx = i
+			break
+		}
+	}
+}
+
+func (sc *seasonCui) selected() *anilist.MediaFull {
+	if sc.SelIdx < 0 || sc.SelIdx >= len(sc.Displayed) {
+		return nil
+	}
+	return sc.Displayed[sc.SelIdx]
+}
+
+func (sc *seasonCui) redraw() {
+	sc.drawHeader()
+	sc.drawList()
+	sc.drawDetails()
+}
+
+func (sc *seasonCui) drawHeader() {
+	v, err := sc.Gui.View(seasonHeaderView)
+	if err != nil {
+		return
+	}
+	v.Clear()
+
+	onList := 0
+	for i := range sc.Media {
+		if sc.Al.GetMediaListById(sc.Media[i].Id) != nil {
+			onList++
+		}
+	}
+	fmt.Fprintf(v, " %s | %s anime, %s on your list | sort: %s",
+		boldYellow(strings.Title(strings.ToLower(sc.Season)), " ", sc.Year),
+		cyan(len(sc.Media)), cyan(onList), cyan(seasonSortingNames[sc.Sorting]))
+	if sc.HideAdded {
+		fmt.Fprint(v, " | ", cyan("added hidden"))
+	}
+	if sc.Message != "" {
+		fmt.Fprint(v, " | ", boldYellow(sc.Message))
+	}
+}
+
+func (sc *seasonCui) drawList() {
+	v, err := sc.Gui.View(seasonListView)
+	if err != nil {
+		return
+	}
+	v.Clear()
+
+	w, _ := v.Size()
+	titleW := w - 1 - 10 - 8 - 12
+	if titleW < 10 {
+		titleW = 10
+	}
+	for _, media := range sc.Displayed {
+		status := ""
+		if entry := sc.Al.GetMediaListById(media.Id); entry != nil {
+			status = alStatusString(entry.Status, anilist.Anime)
+		}
+		episodes := "?"
+		if media.Episodes > 0 {
+			episodes = strconv.Itoa(media.Episodes)
+		}
+		fmt.Fprintf(v, " %-*.*s%-10.10s%8s %11.11s\n", titleW, titleW, media.Title.UserPreferred,
+			strings.Replace(media.Format, "_", " ", -1), episodes+" eps", status)
+	}
+
+	scrollToSelection(v, sc.SelIdx)
+}
+
+var descriptionTagsReplacer = strings.NewReplacer("<br>", "", "<br />", "", "<i>", "", "</i>", "",
+	"<b>", "", "</b>", "")
+
+func (sc *seasonCui) drawDetails() {
+	v, err := sc.Gui.View(seasonDetailsView)
+	if err != nil {
+		return
+	}
+	v.Clear()
+
+	media := sc.selected()
+	if media == nil {
+		fmt.Fprintln(v, "Nothing to show")
+		return
+	}
+
+	fmt.Fprintln(v, boldYellow(media.Title.UserPreferred))
+	for _, title := range []string{media.Title.English, media.Title.Native} {
+		if title != "" && title != media.Title.UserPreferred {
+			fmt.Fprintln(v, title)
+		}
+	}
+	fmt.Fprintln(v)
+	if entry := sc.Al.GetMediaListById(media.Id); entry != nil {
+		fmt.Fprintln(v, "On your list:", cyan(alStatusString(entry.Status, anilist.Anime)))
+	} else {
+		fmt.Fprintln(v, "On your list:", cyan("no"))
+	}
+	fmt.Fprintln(v, "Format:", strings.Replace(media.Format, "_", " ", -1))
+	if media.Episodes > 0 {
+		fmt.Fprintf(v, "Episodes: %d x %d min\n", media.Episodes, media.Duration)
+	}
+	studios := make([]string, len(media.Studios.Nodes))
+	for i, studio := range media.Studios.Nodes {
+		studios[i] = studio.Name
+	}
+	if len(studios) > 0 {
+		fmt.Fprintln(v, "Studio:", strings.Join(studios, ", "))
+	}
+	if media.Source != "" {
+		fmt.Fprintln(v, "Source:", strings.Replace(media.Source, "_", " ", -1))
+	}
+	if len(media.Genres) > 0 {
+		fmt.Fprintln(v, "Genres:", strings.Join(media.Genres, ", "))
+	}
+	if media.AverageScore > 0 {
+		fmt.Fprintf(v, "Average score: %s\n", cyan(media.AverageScore, "%"))
+	}
+	fmt.Fprintln(v, "Airing status:", strings.Replace(media.Status, "_", " ", -1))
+	if date := media.StartDate.String(); date != "" {
+		fmt.Fprintln(v, "Start date:", date)
+	}
+	if next := media.NextAiringEpisode; next.Episode > 0 {
+		fmt.Fprintf(v, "Next episode: %d in %s\n", next.Episode,
+			durafmt.Parse(time.Duration(next.TimeUntilAiring)*time.Second).LimitFirstN(2))
+	}
+	if media.Description != "" {
+		fmt.Fprintln(v)
+		fmt.Fprintln(v, descriptionTagsReplacer.Replace(media.Description))
+	}
+}
+
+func (sc *seasonCui) listEditor(v *gocui.View, key gocui.Key, ch rune, mod gocui.Modifier) {
+	if delta, ok := listNavigation(v, key, ch, len(sc.Displayed)); ok {
+		sc.moveSelection(delta)
+		return
+	}
+	switch {
+	case ch == 'o':
+		sc.Sorting = (sc.Sorting + 1) % seasonSorting(len(seasonSortingNames))
+		sc.updateDisplayed()
+		sc.redraw()
+	case ch == 'f':
+		sc.HideAdded = !sc.HideAdded
+		sc.updateDisplayed()
+		sc.redraw()
+	case ch == 'p':
+		sc.add(anilist.Planning)
+	case ch == 'w':
+		sc.add(anilist.Current)
+	case ch == 'q':
+		sc.Gui.Update(func(gui *gocui.Gui) error {
+			return gocui.ErrQuit
+		})
+	}
+}
+
+func (sc *seasonCui) moveSelection(delta int) {
+	sc.SelIdx = moveListSelection(sc.SelIdx, delta, len(sc.Displayed))
+	sc.drawList()
+	sc.drawDetails()
+}
+
+func (sc *seasonCui) add(status anilist.MediaListStatus) {
+	media := sc.selected()
+	if media == nil || sc.Busy {
+		return
+	}
+	if entry := sc.Al.GetMediaListById(media.Id); entry != nil {
+		dialog.JustShowOkDialog(sc.Gui, "Add entry",
+			"Already on your list ("+alStatusString(entry.Status, anilist.Anime)+")")
+		return
+	}
+
+	sc.Busy = true
+	sc.Message = "Adding " + media.Title.UserPreferred
+	sc.drawHeader()
+
+	go func() {
+		entry, err := sc.Client.AddMediaListEntry(media.Id, status)
+		var dateErr error
+		if err == nil {
+			// AniList leaves the start date of anime added as watching empty;
+			// alAutoFillDates replaces the date, so a different pointer means it was filled in
+			startedAt := entry.StartedAt
+			alAutoFillDates(&entry)
+			if entry.StartedAt != startedAt {
+				if dateErr = sc.Client.SaveMediaListEntry(&entry); dateErr != nil {
+					entry.StartedAt = startedAt
+				}
+			}
+		}
+		sc.Gui.Update(func(gui *gocui.Gui) error {
+			sc.Busy = false
+			if err != nil {
+				sc.Message = ""
+				sc.drawHeader()
+				dialog.JustShowOkDialog(gui, "Error", err.Error())
+				return nil
+			}
+			sc.Al.List = append(sc.Al.List, entry)
+			if err := saveAniListLists(sc.Al); err != nil {
+				return err
+			}
+
+			sc.Message = fmt.Sprintf("Added %s to %s", entry.Title.UserPreferred,
+				alStatusString(status, anilist.Anime))
+			selIdx := sc.SelIdx
+			sc.updateDisplayed()
+			if sc.HideAdded {
+				// The added anime disappeared, stay at the same position
+				if sc.SelIdx = selIdx; sc.SelIdx >= len(sc.Displayed) {
+					sc.SelIdx = len(sc.Displayed) - 1
+				}
+			}
+			sc.redraw()
+			if dateErr != nil {
+				dialog.JustShowOkDialog(gui, "Error", "Start date not saved: "+dateErr.Error())
+			}
+			return nil
+		})
+	}()
+}
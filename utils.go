@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/aqatl/mal/anilist"
@@ -74,7 +75,7 @@ func chooseStrFromSlice(alts []string) string {
 	return alts[idx-1]
 }
 
-func printEntryDetails(title, status string, watchedEps, eps int, score float32, scoreFormat anilist.ScoreFormat, lastUpdated time.Time) {
+func printEntryDetails(title, status, unit string, watchedEps, eps int, score float32, scoreFormat anilist.ScoreFormat, lastUpdated time.Time) {
 	var scorePattern string
 	if scoreFormat == anilist.Point10Decimal {
 		scorePattern = "%.1f"
@@ -91,11 +92,12 @@ func printEntryDetails(title, status string, watchedEps, eps int, score float32,
 	fmt.Fprintf(
 		color.Output,
 		"Title: %s\n"+
-			"Episodes: %s\n"+
+			"%s: %s\n"+
 			"Score: %s\n"+
 			"Status: %v\n"+
 			"Last updated: %v\n",
 		titleStr,
+		unit,
 		episodesStr,
 		scoreStr,
 		statusStr,
@@ -103,7 +105,7 @@ func printEntryDetails(title, status string, watchedEps, eps int, score float32,
 	)
 }
 
-func printEntryDetailsAfterUpdatedEpisodes(title, status string, epsBefore, epsNow, eps int, score float32, scoreFormat anilist.ScoreFormat, lastUpdated time.Time) {
+func printEntryDetailsAfterUpdatedEpisodes(title, status, unit string, epsBefore, epsNow, eps int, score float32, scoreFormat anilist.ScoreFormat, lastUpdated time.Time) {
 	var scorePattern string
 	if scoreFormat == anilist.Point10Decimal {
 		scorePattern = "%.1f"
@@ -121,11 +123,12 @@ func printEntryDetailsAfterUpdatedEpisodes(title, status string, epsBefore, epsN
 	fmt.Fprintf(
 		color.Output,
 		"Title: %s\n"+
-			"Episodes: %s -> %s\n"+
+			"%s: %s -> %s\n"+
 			"Score: %s\n"+
 			"Status: %v\n"+
 			"Last updated: %v\n",
 		titleStr,
+		unit,
 		episodesBeforeStr,
 		episodesAfterStr,
 		scoreStr,
@@ -138,6 +141,7 @@ func malPrintEntryDetails(entry *mal.Anime) {
 	printEntryDetails(
 		entry.Title,
 		entry.MyStatus.String(),
+		"Episodes",
 		entry.WatchedEpisodes,
 		entry.Episodes,
 		float32(int(entry.MyScore)),
@@ -149,6 +153,7 @@ func malPrintEntryDetailsAfterUpdatedEpisodes(entry *mal.Anime, epsBefore int) {
 	printEntryDetailsAfterUpdatedEpisodes(
 		entry.Title,
 		entry.MyStatus.String(),
+		"Episodes",
 		epsBefore,
 		entry.WatchedEpisodes,
 		entry.Episodes,
@@ -157,23 +162,59 @@ func malPrintEntryDetailsAfterUpdatedEpisodes(entry *mal.Anime, epsBefore int) {
 		time.Unix(entry.LastUpdated, 0))
 }
 
+func alProgressUnit(entry *anilist.MediaListEntry) string {
+	if anilist.MediaType(entry.Type) == anilist.Manga {
+		return "Chapters"
+	}
+	return "Episodes"
+}
+
 func alPrintEntryDetails(entry *anilist.MediaListEntry, scoreFormat anilist.ScoreFormat) {
+	mediaType := anilist.MediaType(entry.Type)
 	printEntryDetails(entry.Title.UserPreferred,
-		entry.Status.String(),
+		alStatusString(entry.Status, mediaType),
+		alProgressUnit(entry),
 		entry.Progress,
-		entry.Episodes,
+		entry.Length(),
 		entry.Score,
 		scoreFormat,
 		time.Unix(int64(entry.UpdatedAt), 0))
+	if mediaType == anilist.Manga {
+		fmt.Fprintf(color.Output, "Volumes: %s\n",
+			color.HiRedString("%d/%d", entry.ProgressVolumes, entry.Volumes))
+	}
+	if advanced := formatAdvancedScores(entry.AdvancedScores, scoreFormat); advanced != "" {
+		fmt.Fprintf(color.Output, "Advanced scores: %s\n", color.HiRedString("%s", advanced))
+	}
+	if entry.StartedAt != nil && !entry.StartedAt.IsZero() {
+		fmt.Fprintf(color.Output, "Started: %s\n", color.HiRedString(entry.StartedAt.String()))
+	}
+	if entry.CompletedAt != nil && !entry.CompletedAt.IsZero() {
+		fmt.Fprintf(color.Output, "Completed: %s\n", color.HiRedString(entry.CompletedAt.String()))
+	}
+	if entry.Repeat > 0 {
+		fmt.Fprintf(color.Output, "Repeat: %s\n", color.HiRedString("%d", entry.Repeat))
+	}
+	if entry.Private {
+		fmt.Fprintf(color.Output, "Private: %s\n", color.HiRedString("yes"))
+	}
+	if customLists := entry.CustomListNames(); len(customLists) > 0 {
+		fmt.Fprintf(color.Output, "Custom lists: %s\n",
+			color.HiCyanString("%s", strings.Join(customLists, ", ")))
+	}
+	if entry.Notes != "" {
+		fmt.Fprintf(color.Output, "Notes: %s\n", entry.Notes)
+	}
 }
 
 func alPrintEntryDetailsAfterUpdatedEpisodes(entry *anilist.MediaListEntry, epsBefore int, scoreFormat anilist.ScoreFormat) {
 	printEntryDetailsAfterUpdatedEpisodes(
 		entry.Title.UserPreferred,
-		entry.Status.String(),
+		alStatusString(entry.Status, anilist.MediaType(entry.Type)),
+		alProgressUnit(entry),
 		epsBefore,
 		entry.Progress,
-		entry.Episodes,
+		entry.Length(),
 		entry.Score,
 		scoreFormat,
 		time.Unix(int64(entry.UpdatedAt), 0))